@@ -11,6 +11,9 @@ type SourceFile struct {
 	Path       string
 	Statements []Statement
 	Symbols    *SymbolTable
+
+	// a declaration follows the last statement
+	declTrailing bool
 }
 
 func MakeSourceFile(parent *SymbolTable, path string, token token.Token) *SourceFile {
@@ -28,15 +31,29 @@ func (sf *SourceFile) Add(globalStmt Statement) {
 	if globalStmt == nil {
 		panic("compiler-bug: nil statement")
 	}
-	if decl, ok := globalStmt.(Decl); ok {
-		if sym, ok := sf.Symbols.resolve(decl.DeclName().Value); !ok || sym.Decl == nil {
+	decl, isDecl := globalStmt.(Decl)
+	sf.declTrailing = isDecl
+	if isDecl {
+		name := decl.DeclName().Value
+		// declarations of the prelude may be shadowed
+		if sym, ok := sf.Symbols.declared(name); !ok || sym.Decl == nil {
 			sf.Symbols.Insert(decl)
+			return
 		}
+		sf.Symbols.resolve(name)
 		return
 	}
 	sf.Statements = append(sf.Statements, globalStmt)
 }
 
+// TrailingStatement returns the last statement of the file or nil, if a declaration follows it.
+func (sf *SourceFile) TrailingStatement() Statement {
+	if sf.declTrailing || len(sf.Statements) == 0 {
+		return nil
+	}
+	return sf.Statements[len(sf.Statements)-1]
+}
+
 func (sf SourceFile) EnumerateChildNodes(action func(child Node)) {
 	for _, sym := range sf.Symbols.Symbols {
 		if sym.Decl == nil {
@@ -10,6 +10,8 @@ type ModuleName StaticReference
 type ContextModule struct {
 	Name    registry.LogicalURI
 	Symbols *SymbolTable
+	// The prelude is implicitly imported, if any.
+	Prelude *ContextModule
 
 	Files []*SourceFile
 }
@@ -19,10 +21,17 @@ func MakeContextModule(name registry.LogicalURI) *ContextModule {
 		Name:  name,
 		Files: []*SourceFile{},
 	}
-	m.Symbols = MakeSymbolTable(m.Symbols.Parent, nil)
+	m.Symbols = MakeSymbolTable(nil, m)
 	return m
 }
 
+// UsePrelude resolves all identifiers, which are not declared within the module, from the prelude.
+// Must be called before any source file is parsed.
+func (m *ContextModule) UsePrelude(prelude *ContextModule) {
+	m.Prelude = prelude
+	m.Symbols.Prelude = prelude.Symbols
+}
+
 func (m *ContextModule) AddSourceFile(sourceFile *SourceFile) {
 	m.Files = append(m.Files, sourceFile)
 }
@@ -14,6 +14,8 @@ type DeclImport struct {
 	Alias      Identifier
 	ModuleName ModuleName
 	Members    []DeclImportMember
+
+	aliased bool
 }
 
 // TokenLiteral implements Node
@@ -43,6 +45,16 @@ func (e DeclImport) ExportScope() ExportScope {
 	return ExportScopeLocal
 }
 
+// Module returns the fully qualified name of the imported module.
+func (e DeclImport) Module() StaticReference {
+	if e.aliased {
+		return StaticReference(e.ModuleName)
+	}
+	module := make(StaticReference, 0, len(e.ModuleName)+1)
+	module = append(module, e.ModuleName...)
+	return append(module, e.Alias)
+}
+
 func (e *DeclImport) AddMember(member DeclImportMember) {
 	e.Members = append(e.Members, member)
 }
@@ -66,6 +78,7 @@ func MakeDeclAliasImport(tok token.Token, alias Identifier, name StaticReference
 		Alias:      alias,
 		ModuleName: ModuleName(name),
 		Members:    make([]DeclImportMember, 0),
+		aliased:    true,
 	}
 }
 
@@ -0,0 +1,90 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/vknabel/blush/token"
+)
+
+var _ Expr = &ExprFor{}
+
+// ExprFor collects the trailing expression of each iteration into an array.
+// It supports the same loop forms as StmtFor:
+//
+//	let doubled = for item <- items { item * 2 }
+//
+// continue skips the current element and break finishes the produced array.
+type ExprFor struct {
+	Token      token.Token
+	Condition  Expr
+	Element    *DeclParameter
+	Collection Expr
+	Block      Block
+	// Symbols declares the element and the locals of the block.
+	Symbols *SymbolTable
+}
+
+func MakeExprFor(t token.Token) *ExprFor {
+	return &ExprFor{
+		Token: t,
+	}
+}
+
+func (e *ExprFor) SetCondition(cond Expr) {
+	e.Condition = cond
+}
+
+func (e *ExprFor) SetCollection(element *DeclParameter, collection Expr) {
+	e.Element = element
+	e.Collection = collection
+}
+
+func (e *ExprFor) SetBlock(body Block, symbols *SymbolTable) {
+	e.Block = body
+	e.Symbols = symbols
+}
+
+// EnumerateChildNodes implements Expr.
+func (e *ExprFor) EnumerateChildNodes(action func(child Node)) {
+	if e.Condition != nil {
+		action(e.Condition)
+		e.Condition.EnumerateChildNodes(action)
+	}
+	if e.Element != nil {
+		action(e.Element)
+		e.Element.EnumerateChildNodes(action)
+	}
+	if e.Collection != nil {
+		action(e.Collection)
+		e.Collection.EnumerateChildNodes(action)
+	}
+	for _, n := range e.Block {
+		action(n)
+		n.EnumerateChildNodes(action)
+	}
+}
+
+// TokenLiteral implements Expr.
+func (e *ExprFor) TokenLiteral() token.Token {
+	return e.Token
+}
+
+// Expression implements Expr.
+func (e *ExprFor) Expression() string {
+	var out bytes.Buffer
+
+	out.WriteString("(for ")
+	if e.Collection != nil {
+		out.WriteString(e.Element.Name.String())
+		out.WriteString(" <- ")
+		out.WriteString(e.Collection.Expression())
+		out.WriteString(" ")
+	} else if e.Condition != nil {
+		out.WriteString(e.Condition.Expression())
+		out.WriteString(" ")
+	}
+	out.WriteString(fmt.Sprintf("{ /* %d stmts */ })", len(e.Block)))
+
+	return out.String()
+}
@@ -0,0 +1,76 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/vknabel/blush/token"
+)
+
+var _ Expr = &ExprSwitch{}
+
+// ExprSwitch evaluates to the trailing expression of the first case matching the subject.
+// A wildcard case is required as the last case:
+//
+//	let name = switch value {
+//	case 1: "one"
+//	case @String: value
+//	case _: "other"
+//	}
+type ExprSwitch struct {
+	Token   token.Token
+	Subject Expr
+	Cases   []*SwitchCase
+}
+
+func MakeExprSwitch(t token.Token, subject Expr) *ExprSwitch {
+	return &ExprSwitch{
+		Token:   t,
+		Subject: subject,
+	}
+}
+
+func (e *ExprSwitch) AddCase(c *SwitchCase) {
+	e.Cases = append(e.Cases, c)
+}
+
+// EnumerateChildNodes implements Expr.
+func (e *ExprSwitch) EnumerateChildNodes(action func(child Node)) {
+	action(e.Subject)
+	e.Subject.EnumerateChildNodes(action)
+
+	for _, c := range e.Cases {
+		action(c)
+		c.EnumerateChildNodes(action)
+	}
+}
+
+// TokenLiteral implements Expr.
+func (e *ExprSwitch) TokenLiteral() token.Token {
+	return e.Token
+}
+
+// Expression implements Expr.
+func (e *ExprSwitch) Expression() string {
+	var out bytes.Buffer
+
+	out.WriteString("(switch ")
+	out.WriteString(e.Subject.Expression())
+	out.WriteString(" {")
+	for _, c := range e.Cases {
+		out.WriteString(" case ")
+		switch {
+		case c.Value != nil:
+			out.WriteString(c.Value.Expression())
+		case c.Annotation != nil:
+			out.WriteString("@")
+			out.WriteString(c.Annotation.Reference.String())
+		default:
+			out.WriteString("_")
+		}
+		out.WriteString(fmt.Sprintf(": /* %d stmts */", len(c.Block)))
+	}
+	out.WriteString(" })")
+
+	return out.String()
+}
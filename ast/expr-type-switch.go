@@ -1,19 +1,32 @@
 package ast
 
-import "github.com/vknabel/blush/token"
+import (
+	"bytes"
+
+	"github.com/vknabel/blush/token"
+)
 
 var _ Expr = ExprTypeSwitch{}
 
+// ExprTypeSwitch calls the function of the case matching the type of the subject.
+// The cases refer to the cases of the enum type:
+//
+//	type result = Result {
+//	    Ok: { ok -> ok.value },
+//	    Err: { err -> err.message },
+//	}
 type ExprTypeSwitch struct {
 	Token     token.Token
+	Subject   Expr
 	Type      Expr
 	CaseOrder []Identifier
 	Cases     map[string]Expr
 }
 
-func MakeExprTypeSwitch(type_ Expr, token token.Token) *ExprTypeSwitch {
+func MakeExprTypeSwitch(subject Expr, type_ Expr, token token.Token) *ExprTypeSwitch {
 	return &ExprTypeSwitch{
 		Token:     token,
+		Subject:   subject,
 		Type:      type_,
 		CaseOrder: make([]Identifier, 0),
 		Cases:     make(map[string]Expr),
@@ -30,6 +43,7 @@ func (e ExprTypeSwitch) TokenLiteral() token.Token {
 }
 
 func (e ExprTypeSwitch) EnumerateChildNodes(enumerate func(Node)) {
+	enumerate(e.Subject)
 	enumerate(e.Type)
 	for _, key := range e.CaseOrder {
 		enumerate(key)
@@ -38,6 +52,24 @@ func (e ExprTypeSwitch) EnumerateChildNodes(enumerate func(Node)) {
 }
 
 // Expression implements Expr.
-func (ExprTypeSwitch) Expression() string {
-	panic("unimplemented") // TODO
+func (e ExprTypeSwitch) Expression() string {
+	var out bytes.Buffer
+
+	out.WriteString("(type ")
+	out.WriteString(e.Subject.Expression())
+	out.WriteString(" = ")
+	out.WriteString(e.Type.Expression())
+	out.WriteString(" {")
+	for i, key := range e.CaseOrder {
+		if i > 0 {
+			out.WriteString(",")
+		}
+		out.WriteString(" ")
+		out.WriteString(key.Value)
+		out.WriteString(": ")
+		out.WriteString(e.Cases[key.Value].Expression())
+	}
+	out.WriteString(" })")
+
+	return out.String()
 }
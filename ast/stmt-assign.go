@@ -0,0 +1,47 @@
+package ast
+
+import "github.com/vknabel/blush/token"
+
+var _ Statement = &StmtAssign{}
+
+// StmtAssign changes the value of a variable.
+// Without a target, the value is discarded:
+//
+//	x = 2
+//	_ = x
+type StmtAssign struct {
+	Token  token.Token
+	Target Expr // nil for `_ = expr`
+	Value  Expr
+}
+
+func MakeStmtAssign(t token.Token, target Expr, value Expr) *StmtAssign {
+	return &StmtAssign{
+		Token:  t,
+		Target: target,
+		Value:  value,
+	}
+}
+
+// IsDiscard reports whether the value is discarded.
+func (s *StmtAssign) IsDiscard() bool {
+	return s.Target == nil
+}
+
+// EnumerateChildNodes implements Statement.
+func (s *StmtAssign) EnumerateChildNodes(action func(child Node)) {
+	if s.Target != nil {
+		action(s.Target)
+		s.Target.EnumerateChildNodes(action)
+	}
+	action(s.Value)
+	s.Value.EnumerateChildNodes(action)
+}
+
+// TokenLiteral implements Statement.
+func (s *StmtAssign) TokenLiteral() token.Token {
+	return s.Token
+}
+
+// statementNode implements Statement.
+func (*StmtAssign) statementNode() {}
@@ -0,0 +1,26 @@
+package ast
+
+import "github.com/vknabel/blush/token"
+
+var _ Statement = &StmtBreak{}
+
+type StmtBreak struct {
+	Token token.Token
+}
+
+func MakeStmtBreak(t token.Token) *StmtBreak {
+	return &StmtBreak{
+		Token: t,
+	}
+}
+
+// EnumerateChildNodes implements Statement.
+func (s *StmtBreak) EnumerateChildNodes(action func(child Node)) {}
+
+// TokenLiteral implements Statement.
+func (s *StmtBreak) TokenLiteral() token.Token {
+	return s.Token
+}
+
+// statementNode implements Statement.
+func (s *StmtBreak) statementNode() {}
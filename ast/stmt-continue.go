@@ -0,0 +1,26 @@
+package ast
+
+import "github.com/vknabel/blush/token"
+
+var _ Statement = &StmtContinue{}
+
+type StmtContinue struct {
+	Token token.Token
+}
+
+func MakeStmtContinue(t token.Token) *StmtContinue {
+	return &StmtContinue{
+		Token: t,
+	}
+}
+
+// EnumerateChildNodes implements Statement.
+func (s *StmtContinue) EnumerateChildNodes(action func(child Node)) {}
+
+// TokenLiteral implements Statement.
+func (s *StmtContinue) TokenLiteral() token.Token {
+	return s.Token
+}
+
+// statementNode implements Statement.
+func (s *StmtContinue) statementNode() {}
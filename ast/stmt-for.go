@@ -0,0 +1,68 @@
+package ast
+
+import "github.com/vknabel/blush/token"
+
+var _ Statement = &StmtFor{}
+
+// StmtFor represents all three loop forms:
+//
+//	for { }              // infinite, Condition and Collection are nil
+//	for cond { }         // conditional, only Condition is set
+//	for item <- items { } // collection, Element and Collection are set
+type StmtFor struct {
+	Token      token.Token
+	Condition  Expr
+	Element    *DeclParameter
+	Collection Expr
+	Block      Block
+	// Symbols declares the element and the locals of the block.
+	Symbols *SymbolTable
+}
+
+func MakeStmtFor(t token.Token) *StmtFor {
+	return &StmtFor{
+		Token: t,
+	}
+}
+
+func (s *StmtFor) SetCondition(cond Expr) {
+	s.Condition = cond
+}
+
+func (s *StmtFor) SetCollection(element *DeclParameter, collection Expr) {
+	s.Element = element
+	s.Collection = collection
+}
+
+func (s *StmtFor) SetBlock(body Block, symbols *SymbolTable) {
+	s.Block = body
+	s.Symbols = symbols
+}
+
+// EnumerateChildNodes implements Statement.
+func (s *StmtFor) EnumerateChildNodes(action func(child Node)) {
+	if s.Condition != nil {
+		action(s.Condition)
+		s.Condition.EnumerateChildNodes(action)
+	}
+	if s.Element != nil {
+		action(s.Element)
+		s.Element.EnumerateChildNodes(action)
+	}
+	if s.Collection != nil {
+		action(s.Collection)
+		s.Collection.EnumerateChildNodes(action)
+	}
+	for _, n := range s.Block {
+		action(n)
+		n.EnumerateChildNodes(action)
+	}
+}
+
+// TokenLiteral implements Statement.
+func (s *StmtFor) TokenLiteral() token.Token {
+	return s.Token
+}
+
+// statementNode implements Statement.
+func (s *StmtFor) statementNode() {}
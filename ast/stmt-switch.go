@@ -0,0 +1,43 @@
+package ast
+
+import "github.com/vknabel/blush/token"
+
+var _ Statement = &StmtSwitch{}
+
+// StmtSwitch runs the block of the first case matching the subject.
+// If no case matches, nothing happens.
+type StmtSwitch struct {
+	Token   token.Token
+	Subject Expr
+	Cases   []*SwitchCase
+}
+
+func MakeStmtSwitch(t token.Token, subject Expr) *StmtSwitch {
+	return &StmtSwitch{
+		Token:   t,
+		Subject: subject,
+	}
+}
+
+func (s *StmtSwitch) AddCase(c *SwitchCase) {
+	s.Cases = append(s.Cases, c)
+}
+
+// EnumerateChildNodes implements Statement.
+func (s *StmtSwitch) EnumerateChildNodes(action func(child Node)) {
+	action(s.Subject)
+	s.Subject.EnumerateChildNodes(action)
+
+	for _, c := range s.Cases {
+		action(c)
+		c.EnumerateChildNodes(action)
+	}
+}
+
+// TokenLiteral implements Statement.
+func (s *StmtSwitch) TokenLiteral() token.Token {
+	return s.Token
+}
+
+// statementNode implements Statement.
+func (s *StmtSwitch) statementNode() {}
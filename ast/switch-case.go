@@ -0,0 +1,62 @@
+package ast
+
+import "github.com/vknabel/blush/token"
+
+var _ Node = &SwitchCase{}
+
+// SwitchCase is a single case of a switch statement or expression:
+//
+//	case 1:                // value case, only Value is set
+//	case @String:          // type case, only Annotation is set
+//	case @Has(Countable):  // annotation case, only Annotation is set
+//	case _:                // wildcard, neither Value nor Annotation are set
+type SwitchCase struct {
+	Token      token.Token
+	Value      Expr
+	Annotation *DeclAnnotationInstance
+	Block      Block
+}
+
+func MakeSwitchCase(t token.Token) *SwitchCase {
+	return &SwitchCase{
+		Token: t,
+	}
+}
+
+func (c *SwitchCase) SetValue(value Expr) {
+	c.Value = value
+}
+
+func (c *SwitchCase) SetAnnotation(anno *DeclAnnotationInstance) {
+	c.Annotation = anno
+}
+
+func (c *SwitchCase) SetBlock(block Block) {
+	c.Block = block
+}
+
+// IsWildcard reports whether the case matches all values.
+func (c *SwitchCase) IsWildcard() bool {
+	return c.Value == nil && c.Annotation == nil
+}
+
+// EnumerateChildNodes implements Node.
+func (c *SwitchCase) EnumerateChildNodes(action func(child Node)) {
+	if c.Value != nil {
+		action(c.Value)
+		c.Value.EnumerateChildNodes(action)
+	}
+	if c.Annotation != nil {
+		action(c.Annotation)
+		c.Annotation.EnumerateChildNodes(action)
+	}
+	for _, n := range c.Block {
+		action(n)
+		n.EnumerateChildNodes(action)
+	}
+}
+
+// TokenLiteral implements Node.
+func (c *SwitchCase) TokenLiteral() token.Token {
+	return c.Token
+}
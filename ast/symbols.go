@@ -63,10 +63,15 @@ type SymbolTable struct {
 	OpenedBy    Node
 	Symbols     map[string]*Symbol
 	FreeSymbols []*Symbol
+	// Prelude resolves all identifiers, which are not declared by the table or its parents.
+	Prelude *SymbolTable
+	// Blocks are the tables of nested blocks like loop bodies.
+	Blocks []*SymbolTable
 
 	symbolCounter    int
 	functionCounter  int
 	exportScopeLevel ExportScope
+	isBlock          bool
 	mu               sync.RWMutex
 }
 
@@ -78,6 +83,15 @@ func MakeSymbolTable(parent *SymbolTable, declaringNode Node) *SymbolTable {
 	}
 }
 
+// MakeBlockSymbolTable creates a table for the declarations of a nested block like a loop body.
+// Blocks run within the frame of their parent and thus refer to its symbols instead of capturing them.
+func MakeBlockSymbolTable(parent *SymbolTable, declaringNode Node) *SymbolTable {
+	st := MakeSymbolTable(parent, declaringNode)
+	st.isBlock = true
+	parent.Blocks = append(parent.Blocks, st)
+	return st
+}
+
 func (st *SymbolTable) Name() string {
 	st.mu.RLock()
 	defer st.mu.RUnlock()
@@ -160,19 +174,37 @@ func (st *SymbolTable) resolve(name string) (*Symbol, bool) {
 		return sym, true
 	}
 
-	if st.Parent == nil {
+	parent := st.Parent
+	if parent == nil {
+		parent = st.Prelude
+	}
+	if parent == nil {
 		return nil, false
 	}
 
-	st.Parent.mu.Lock()
-	defer st.Parent.mu.Unlock()
+	parent.mu.Lock()
+	defer parent.mu.Unlock()
 
-	if sym, ok := st.Parent.resolve(name); ok {
+	if sym, ok := parent.resolve(name); ok {
+		if st.isBlock {
+			return sym, true
+		}
 		return st.defineFree(sym), true
 	}
 	return nil, false
 }
 
+// declared returns the symbol declared within the table or its parents without resolving the prelude.
+func (st *SymbolTable) declared(name string) (*Symbol, bool) {
+	for table := st; table != nil; table = table.Parent {
+		// free symbols either refer to a parent or to the prelude
+		if sym, ok := table.Symbols[name]; ok && sym.Scope != FreeScope {
+			return sym, true
+		}
+	}
+	return nil, false
+}
+
 func (st *SymbolTable) defineFree(sym *Symbol) *Symbol {
 	idx := len(st.FreeSymbols)
 	st.FreeSymbols = append(st.FreeSymbols, sym)
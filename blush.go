@@ -0,0 +1,179 @@
+// Package blush embeds the Blush language into Go programs.
+//
+// An Engine loads sources or modules, compiles them and prepares a Program,
+// which runs on its own virtual machine.
+// Go implementations of extern declarations are provided by plugins.
+// The prelude of the standard library is implicitly imported into every program.
+package blush
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/vknabel/blush/ast"
+	"github.com/vknabel/blush/compiler"
+	"github.com/vknabel/blush/loader"
+	"github.com/vknabel/blush/parser"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/staticmodule"
+	"github.com/vknabel/blush/registry/stdlibreg"
+	"github.com/vknabel/blush/runtime"
+	"github.com/vknabel/blush/vm"
+)
+
+// Engine loads and compiles Blush programs.
+type Engine struct {
+	plugins  []runtime.ExternPlugin
+	stdlib   *stdlibreg.StdlibRegistry
+	packages []loader.Option
+}
+
+// New creates an engine, which binds extern declarations using the given plugins.
+func New(plugins ...runtime.ExternPlugin) *Engine {
+	return &Engine{
+		plugins: plugins,
+		stdlib:  stdlibreg.New(),
+	}
+}
+
+// UseStdlib implicitly imports the prelude of the given standard library instead of the embedded one.
+func (e *Engine) UseStdlib(stdlib *stdlibreg.StdlibRegistry) {
+	e.stdlib = stdlib
+}
+
+// UsePackage makes the modules of the package importable by the given name for all programs loaded afterwards.
+// Packages of the standard library are always importable, unless shadowed.
+func (e *Engine) UsePackage(name string, pkg registry.ResolvedPackage) {
+	e.packages = append(e.packages, loader.WithPackage(name, pkg))
+}
+
+// Register adds another plugin for all programs loaded afterwards.
+// Earlier plugins take precedence.
+func (e *Engine) Register(plugin runtime.ExternPlugin) {
+	e.plugins = append(e.plugins, plugin)
+}
+
+// LoadString loads a single source file with the given contents.
+func (e *Engine) LoadString(uri registry.LogicalURI, contents string) (*Program, error) {
+	return e.LoadSource(staticmodule.NewSourceString(uri, contents))
+}
+
+// LoadSource loads a single source file.
+func (e *Engine) LoadSource(src registry.Source) (*Program, error) {
+	return e.LoadModule(staticmodule.NewModule(src.URI(), []registry.Source{src}))
+}
+
+// LoadModule loads all source files of the module and all modules it imports.
+func (e *Engine) LoadModule(module registry.ResolvedModule) (*Program, error) {
+	preludeModule, err := e.stdlib.Prelude()
+	if err != nil {
+		return nil, err
+	}
+	prelude, err := parser.ParsePrelude(preludeModule)
+	if err != nil {
+		return nil, err
+	}
+	stdlib, err := e.stdlib.Discover(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	opts := make([]loader.Option, 0, len(stdlib)+len(e.packages))
+	for _, pkg := range stdlib {
+		opts = append(opts, loader.WithPackage(pkg.Source(), pkg))
+	}
+	opts = append(opts, e.packages...)
+
+	ld := loader.New(prelude, opts...)
+	ctxModule, err := ld.Load(module)
+	if err != nil {
+		return nil, err
+	}
+	if err := parseErrors(ld.Errors()); err != nil {
+		return nil, err
+	}
+	return e.compile(ctxModule, ctxModule.Symbols)
+}
+
+func (e *Engine) compile(node ast.Node, symbols *ast.SymbolTable) (*Program, error) {
+	comp := compiler.New(e.plugins...)
+	err := comp.Compile(node)
+	if err != nil {
+		return nil, err
+	}
+	return &Program{
+		symbols: symbols,
+		vm:      vm.New(comp.Bytecode()),
+	}, nil
+}
+
+func parseErrors(errs []parser.ParseError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	joined := make([]error, len(errs))
+	for i, err := range errs {
+		joined[i] = err
+	}
+	return errors.Join(joined...)
+}
+
+// Program is a compiled Blush program.
+// Globals are initialized lazily, thus functions can be called before or after running the program.
+type Program struct {
+	symbols *ast.SymbolTable
+	vm      *vm.VM
+}
+
+// Run executes the top level statements of the program.
+// Returns the value of the last expression statement or Null.
+func (p *Program) Run() (runtime.RuntimeValue, error) {
+	err := p.vm.Run()
+	if err != nil {
+		return nil, err
+	}
+	return p.vm.Result(), nil
+}
+
+// Lookup returns the value of the global declaration with the given name.
+func (p *Program) Lookup(name string) (runtime.RuntimeValue, error) {
+	sym, ok := p.symbols.Symbols[name]
+	if !ok || sym.Decl == nil {
+		return nil, fmt.Errorf("undefined identifier %q", name)
+	}
+	sym = sym.Original()
+
+	if sym.ConstantId != nil {
+		return p.vm.Constant(*sym.ConstantId), nil
+	}
+	if sym.GlobalId != nil {
+		return p.vm.Global(*sym.GlobalId)
+	}
+	return nil, fmt.Errorf("identifier %q is not global", name)
+}
+
+// Global returns the value of the global declaration with the given name converted to Go.
+func (p *Program) Global(name string) (any, error) {
+	val, err := p.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return FromValue(val)
+}
+
+// Call calls the global function with the given name.
+// The arguments are converted using ToValue.
+func (p *Program) Call(name string, args ...any) (runtime.RuntimeValue, error) {
+	callee, err := p.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]runtime.RuntimeValue, len(args))
+	for i, arg := range args {
+		vals[i], err = ToValue(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d of %s: %w", i, name, err)
+		}
+	}
+	return p.vm.Call(callee, vals...)
+}
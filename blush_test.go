@@ -0,0 +1,397 @@
+package blush_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	"github.com/vknabel/blush"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/localreg"
+	"github.com/vknabel/blush/registry/staticmodule"
+	"github.com/vknabel/blush/runtime"
+)
+
+func TestProgramRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected runtime.RuntimeValue
+	}{
+		{"1 + 2", runtime.Int(3)},
+		{"1 + 2\nfor x <- [4, 5] { x }", runtime.Null{}},
+		{"1 + 2\nfor { break }", runtime.Null{}},
+		{"1 + 2\nlet x = 4", runtime.Null{}},
+		{"switch 1 { case 1: 4 case _: 5 }", runtime.Int(4)},
+		{"let x = 1\nif x > 1 { 4 } else { x + 4 }", runtime.Int(5)},
+		{"if false { 4 }", runtime.Null{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			prog, err := blush.New().LoadString("testing:///test/test.blush", tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := prog.Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected.Inspect(), res.Inspect())
+			}
+		})
+	}
+}
+
+func TestProgramCall(t *testing.T) {
+	prog, err := blush.New().LoadString("testing:///test/test.blush", `
+	let greeting = "Hello"
+	let offset = 40
+
+	func add(value) {
+		return offset + value
+	}
+
+	func doubled(values) {
+		return for value <- values { value * 2 }
+	}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := prog.Call("add", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != runtime.Int(42) {
+		t.Errorf("expected 42, got %s", res.Inspect())
+	}
+
+	res, err = prog.Call("doubled", []int{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := blush.FromValue(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []any{int64(2), int64(4), int64(6)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	greeting, err := prog.Global("greeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if greeting != "Hello" {
+		t.Errorf("expected greeting global, got %v", greeting)
+	}
+
+	_, err = prog.Call("add", 1, 2)
+	if err == nil || err.Error() != "wrong number of arguments: want=1, got=2" {
+		t.Errorf("expected arity error, got %v", err)
+	}
+	_, err = prog.Call("missing")
+	if err == nil || err.Error() != `undefined identifier "missing"` {
+		t.Errorf("expected undefined error, got %v", err)
+	}
+}
+
+func TestProgramPlugins(t *testing.T) {
+	engine := blush.New()
+	engine.Register(blush.Funcs{
+		"env": func(args []runtime.RuntimeValue) (runtime.RuntimeValue, error) {
+			return blush.ToValue(map[string]any{"port": 8080})
+		},
+		"fail": func(args []runtime.RuntimeValue) (runtime.RuntimeValue, error) {
+			return nil, fmt.Errorf("oops")
+		},
+	})
+
+	prog, err := engine.LoadString("testing:///test/test.blush", `
+	extern func env()
+	extern func fail()
+
+	func port() {
+		return env()["port"]
+	}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := prog.Call("port")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != runtime.Int(8080) {
+		t.Errorf("expected 8080, got %s", res.Inspect())
+	}
+
+	_, err = prog.Call("fail")
+	if err == nil || err.Error() != "extern fail(#0): oops" {
+		t.Errorf("expected plugin error, got %v", err)
+	}
+	res, err = prog.Call("port")
+	if err != nil || res != runtime.Int(8080) {
+		t.Errorf("expected program to be usable after failure, got %v", err)
+	}
+}
+
+func TestLoadModule(t *testing.T) {
+	mod := staticmodule.NewModule("testing:///test", []registry.Source{
+		staticmodule.NewSourceString("testing:///test/a.blush", `
+		data Config {
+			name
+			port
+		}
+		`),
+		staticmodule.NewSourceString("testing:///test/b.blush", `
+		let config = Config("server", 8080)
+		`),
+	})
+
+	prog, err := blush.New().LoadModule(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := prog.Global("config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"name": "server", "port": int64(8080)}; !reflect.DeepEqual(config, want) {
+		t.Errorf("expected %v, got %v", want, config)
+	}
+}
+
+func TestPrelude(t *testing.T) {
+	prog, err := blush.New().LoadString("testing:///test/test.blush", `
+	// shadows the prelude
+	data Number {
+		value
+	}
+
+	func doubled(start, end) {
+		return for i <- Range(start, end) { i * 2 }
+	}
+
+	func count(end) {
+		let n = 0
+		for i <- Range(0, end) {
+			n = n + 1
+		}
+		return n
+	}
+	let number = Number(42)
+	let letters = for letter <- [3: "c", 1: "a", 2: "b"] { letter }
+	let chars = for char <- "hé!" { char }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := prog.Call("doubled", 2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doubled, err := blush.FromValue(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []any{int64(4), int64(6), int64(8)}; !reflect.DeepEqual(doubled, want) {
+		t.Errorf("expected %v, got %v", want, doubled)
+	}
+	res, err = prog.Call("count", 2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != runtime.Int(2000) {
+		t.Errorf("expected 2000, got %s", res.Inspect())
+	}
+	number, err := prog.Global("number")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"value": int64(42)}; !reflect.DeepEqual(number, want) {
+		t.Errorf("expected %v, got %v", want, number)
+	}
+	for name, want := range map[string]any{
+		"letters": []any{"a", "b", "c"},
+		"chars":   []any{'h', 'é', '!'},
+	} {
+		got, err := prog.Global(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %s to be %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestGlobalInitializers(t *testing.T) {
+	prog, err := blush.New().LoadString("testing:///test/test.blush", `
+	let tripled = switch 1 {
+	case 1:
+		let a = 2
+		a * 3
+	case _: 0
+	}
+
+	let items = [1, 2, 3]
+	let doubled = for item <- items { item * 2 }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]any{
+		"tripled": int64(6),
+		"doubled": []any{int64(2), int64(4), int64(6)},
+	} {
+		got, err := prog.Global(name)
+		if err != nil {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %s to be %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestLoadSyntaxErrors(t *testing.T) {
+	_, err := blush.New().LoadString("testing:///test/test.blush", "let = 1")
+	if err == nil {
+		t.Fatal("expected syntax error")
+	}
+}
+
+func TestConversion(t *testing.T) {
+	tests := []struct {
+		input    any
+		expected any
+	}{
+		{nil, nil},
+		{true, true},
+		{42, int64(42)},
+		{uint8(42), int64(42)},
+		{1.5, 1.5},
+		{"blush", "blush"},
+		{[]string{"a", "b"}, []any{"a", "b"}},
+		{map[string]int{"a": 1}, map[any]any{"a": int64(1)}},
+	}
+
+	for _, tt := range tests {
+		val, err := blush.ToValue(tt.input)
+		if err != nil {
+			t.Errorf("ToValue(%v): %s", tt.input, err)
+			continue
+		}
+		got, err := blush.FromValue(val)
+		if err != nil {
+			t.Errorf("FromValue(%v): %s", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("expected %#v, got %#v", tt.expected, got)
+		}
+	}
+
+	if _, err := blush.ToValue(struct{}{}); err == nil {
+		t.Error("expected structs to be unsupported")
+	}
+}
+
+func TestLoadImports(t *testing.T) {
+	fs := memfs.New()
+	files := map[string]string{
+		"/maths/maths.blush": `
+		let pi = 3
+
+		func double(value) {
+			return value * 2
+		}
+		`,
+		"/maths/geometry/circle.blush": `
+		import maths
+
+		func area(r) {
+			return maths.pi * r * r
+		}
+		`,
+	}
+	for name, contents := range files {
+		if err := billyutil.WriteFile(fs, name, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pkgs, err := localreg.New(fs, "/").DiscoverPackageVersions(context.Background(), "maths")
+	if err != nil || len(pkgs) != 1 {
+		t.Fatalf("expected maths package, got %v, %v", pkgs, err)
+	}
+	pkg, err := pkgs[0].Resolve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := blush.New()
+	engine.UsePackage("maths", pkg)
+
+	prog, err := engine.LoadString("testing:///test/test.blush", `
+	import maths
+	import geo = maths.geometry
+	import maths.geometry { area }
+
+	let doubled = maths.double(maths.pi)
+	let areas = [geo.area(1), area(2)]
+
+	func scaled(values) {
+		return for value <- values { maths.double(value) }
+	}
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]any{
+		"doubled": int64(6),
+		"areas":   []any{int64(3), int64(12)},
+	} {
+		got, err := prog.Global(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %s to be %v, got %v", name, want, got)
+		}
+	}
+	res, err := prog.Call("scaled", []int{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scaled, err := blush.FromValue(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []any{int64(2), int64(4)}; !reflect.DeepEqual(scaled, want) {
+		t.Errorf("expected %v, got %v", want, scaled)
+	}
+
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"import maths\nmaths.missing", `module maths has no member "missing"`},
+		{"import maths\nlet m = maths", `module "maths" cannot be used as a value`},
+		{"import maths { missing }", "unknown member missing"},
+		{"import strings", "cannot import strings"},
+	}
+	for _, tt := range tests {
+		_, err := engine.LoadString("testing:///test/test.blush", tt.input)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: expected error %q, got %v", tt.input, tt.err, err)
+		}
+	}
+}
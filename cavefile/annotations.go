@@ -0,0 +1,137 @@
+package cavefile
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vknabel/blush/ast"
+	"github.com/vknabel/blush/parser"
+)
+
+// dataDecls returns all data declarations of the source file in source order.
+func dataDecls(src *ast.SourceFile) []*ast.DeclData {
+	var decls []*ast.DeclData
+	seen := make(map[*ast.DeclData]bool)
+	for table := src.Symbols; table != nil; table = table.Parent {
+		for _, sym := range table.Symbols {
+			decl, ok := sym.Decl.(*ast.DeclData)
+			if !ok || seen[decl] || decl.Token.Source == nil || decl.Token.Source.File != src.Token.Source.File {
+				continue
+			}
+			seen[decl] = true
+			decls = append(decls, decl)
+		}
+	}
+	sort.Slice(decls, func(i, j int) bool {
+		return decls[i].Token.Source.Offset < decls[j].Token.Source.Offset
+	})
+	return decls
+}
+
+// annotationModule resolves the fully qualified module of an annotation through the imports of the file.
+// Returns an empty string for unqualified annotations.
+func annotationModule(symbols *ast.SymbolTable, anno *ast.DeclAnnotationInstance) string {
+	ref := anno.Reference
+	if len(ref) < 2 {
+		return ""
+	}
+	sym, ok := symbols.Symbols[ref[0].Value]
+	if !ok {
+		return ""
+	}
+	imp, ok := sym.Decl.(*ast.DeclImport)
+	if !ok {
+		return ""
+	}
+	module := imp.Module().String()
+	for _, ident := range ref[1 : len(ref)-1] {
+		module += "." + ident.Value
+	}
+	return module
+}
+
+func annotationError(anno *ast.DeclAnnotationInstance, details string) parser.ParseError {
+	return parser.ParseError{
+		Token:   anno.Token,
+		Summary: fmt.Sprintf("annotation @%s", anno.Reference),
+		Details: details,
+	}
+}
+
+func appendErr(errs []parser.ParseError, err *parser.ParseError) []parser.ParseError {
+	if err == nil {
+		return errs
+	}
+	return append(errs, *err)
+}
+
+func singleArgument(anno *ast.DeclAnnotationInstance) (ast.Expr, *parser.ParseError) {
+	if len(anno.Arguments) != 1 {
+		err := annotationError(anno, fmt.Sprintf("requires exactly one argument, got %d", len(anno.Arguments)))
+		return nil, &err
+	}
+	return anno.Arguments[0], nil
+}
+
+func stringArgument(anno *ast.DeclAnnotationInstance, dest *string) *parser.ParseError {
+	arg, err := singleArgument(anno)
+	if err != nil {
+		return err
+	}
+	lit, ok := arg.(*ast.ExprString)
+	if !ok {
+		err := annotationError(anno, "requires a string literal")
+		return &err
+	}
+	*dest = lit.Literal
+	return nil
+}
+
+func charArgument(anno *ast.DeclAnnotationInstance, dest *rune) *parser.ParseError {
+	arg, err := singleArgument(anno)
+	if err != nil {
+		return err
+	}
+	lit, ok := arg.(*ast.ExprChar)
+	if !ok {
+		err := annotationError(anno, "requires a char literal")
+		return &err
+	}
+	*dest = lit.Literal
+	return nil
+}
+
+func identifierArgument(anno *ast.DeclAnnotationInstance, dest *string) *parser.ParseError {
+	arg, err := singleArgument(anno)
+	if err != nil {
+		return err
+	}
+	ident, ok := arg.(*ast.ExprIdentifier)
+	if !ok {
+		err := annotationError(anno, "requires the name of a function")
+		return &err
+	}
+	*dest = ident.Name.Value
+	return nil
+}
+
+func stringsArgument(anno *ast.DeclAnnotationInstance, dest *[]string) *parser.ParseError {
+	arg, err := singleArgument(anno)
+	if err != nil {
+		return err
+	}
+	arr, ok := arg.(*ast.ExprArray)
+	if !ok {
+		err := annotationError(anno, "requires an array of string literals")
+		return &err
+	}
+	for _, el := range arr.Elements {
+		lit, ok := el.(*ast.ExprString)
+		if !ok {
+			err := annotationError(anno, "requires an array of string literals")
+			return &err
+		}
+		*dest = append(*dest, lit.Literal)
+	}
+	return nil
+}
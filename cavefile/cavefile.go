@@ -1,13 +1,244 @@
 package cavefile
 
-import "github.com/vknabel/blush/version"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vknabel/blush/ast"
+	"github.com/vknabel/blush/lexer"
+	"github.com/vknabel/blush/parser"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/token"
+	"github.com/vknabel/blush/version"
+)
+
+const caveModule = "cave"
 
 type Cavefile struct {
+	// The version of the package declared by @cave.Version on its dependencies.
+	// Nil if undeclared.
+	Version      version.Version
 	Dependencies []Dependency
+	Tasks        []Task
 }
 
 type Dependency struct {
+	// The name to import the dependency with.
+	// Submodules are separated by dots like `foo.bar`.
 	ImportName string
+	Kind       SourceKind
+	// The URL for Git, the path relative to the Cavefile for Local or the name for Stdlib dependencies.
 	Source     string
-	Predicate  version.Predicate
+	Constraint version.Constraint
+}
+
+// SourceKind mirrors the cave.Source enum.
+type SourceKind int
+
+const (
+	SourceGit SourceKind = iota
+	SourceLocal
+	SourceStdlib
+)
+
+var sourceKinds = map[string]SourceKind{
+	"Git":    SourceGit,
+	"Local":  SourceLocal,
+	"Stdlib": SourceStdlib,
+}
+
+func (k SourceKind) String() string {
+	switch k {
+	case SourceGit:
+		return "Git"
+	case SourceLocal:
+		return "Local"
+	case SourceStdlib:
+		return "Stdlib"
+	}
+	return ""
+}
+
+// AnyVersion is the constraint of dependencies without @cave.Version.
+var AnyVersion = version.ConstraintOf(version.Predicate{
+	Comparison: version.ComparisonGreaterThanOrEqual,
+	Version:    version.SemverVersion{},
+})
+
+// Load parses the Cavefile source.
+// Diagnostics of the Cavefile are returned as positioned parse errors.
+func Load(src registry.Source) (Cavefile, []parser.ParseError, error) {
+	lex, err := lexer.New(src)
+	if err != nil {
+		return Cavefile{}, nil, err
+	}
+	table := ast.MakeSymbolTable(nil, ast.Identifier{Value: "Cavefile"})
+	p := parser.NewSourceParser(lex, table, string(src.URI()))
+	file := p.ParseSourceFile()
+	if errs := p.Errors(); len(errs) > 0 {
+		return Cavefile{}, errs, nil
+	}
+	cave, errs := Parse(file)
+	return cave, errs, nil
+}
+
+// Parse reads the dependencies and tasks of a parsed Cavefile.
+func Parse(src *ast.SourceFile) (Cavefile, []parser.ParseError) {
+	var (
+		cave Cavefile
+		errs []parser.ParseError
+		deps *ast.DeclData
+	)
+	for _, decl := range dataDecls(src) {
+		var (
+			isDeps      bool
+			versionAnno *ast.DeclAnnotationInstance
+		)
+		for _, anno := range decl.Annotations {
+			if annotationModule(src.Symbols, anno) != caveModule {
+				continue
+			}
+			switch anno.Reference.Name().Value {
+			case "Dependencies":
+				isDeps = true
+				if len(anno.Arguments) > 0 {
+					errs = append(errs, annotationError(anno, fmt.Sprintf("requires no arguments, got %d", len(anno.Arguments))))
+				}
+				if deps != nil {
+					errs = append(errs, annotationError(anno, fmt.Sprintf("already declared by %s", deps.Name.Value)))
+					continue
+				}
+				deps = decl
+			case "Version":
+				versionAnno = anno
+			default:
+				errs = append(errs, annotationError(anno, "not applicable to data declarations"))
+			}
+		}
+		if versionAnno == nil {
+			continue
+		}
+		if !isDeps {
+			errs = append(errs, annotationError(versionAnno, "requires @cave.Dependencies on data declarations"))
+			continue
+		}
+		v, err := packageVersion(versionAnno)
+		if err != nil {
+			errs = append(errs, *err)
+			continue
+		}
+		cave.Version = v
+	}
+	if deps != nil {
+		for _, field := range deps.Fields {
+			dep, depErrs := parseDependency(src.Symbols, field)
+			errs = append(errs, depErrs...)
+			if len(depErrs) == 0 {
+				cave.Dependencies = append(cave.Dependencies, dep)
+			}
+		}
+	}
+
+	tasks, taskErrs := ParseTasks(src)
+	cave.Tasks = tasks
+	errs = append(errs, taskErrs...)
+	return cave, errs
+}
+
+func parseDependency(symbols *ast.SymbolTable, field ast.DeclField) (Dependency, []parser.ParseError) {
+	dep := Dependency{
+		ImportName: field.Name.Value,
+		Constraint: AnyVersion,
+	}
+	var (
+		errs      []parser.ParseError
+		hasSource bool
+	)
+	if len(field.Parameters) > 0 {
+		errs = append(errs, dependencyError(field, "dependencies cannot declare parameters"))
+	}
+	for _, anno := range field.Annotations {
+		if annotationModule(symbols, anno) != caveModule {
+			continue
+		}
+		name := anno.Reference.Name().Value
+		switch name {
+		case "Git", "Local", "Stdlib":
+			if hasSource {
+				errs = append(errs, annotationError(anno, "dependencies require exactly one source"))
+				continue
+			}
+			hasSource = true
+			dep.Kind = sourceKinds[name]
+			errs = appendErr(errs, stringArgument(anno, &dep.Source))
+		case "Version":
+			var predicate string
+			if err := stringArgument(anno, &predicate); err != nil {
+				errs = append(errs, *err)
+				continue
+			}
+			if strings.TrimSpace(predicate) == "" {
+				errs = append(errs, annotationError(anno, "requires a non-empty version predicate"))
+				continue
+			}
+			constraint, err := version.ParseConstraint(strings.TrimSpace(predicate))
+			if err != nil {
+				errs = append(errs, annotationError(anno, err.Error()))
+				continue
+			}
+			dep.Constraint = constraint
+		case "Name":
+			errs = appendErr(errs, stringArgument(anno, &dep.ImportName))
+			if !isImportName(dep.ImportName) {
+				errs = append(errs, annotationError(anno, fmt.Sprintf("%q is not a valid import name", dep.ImportName)))
+			}
+		default:
+			errs = append(errs, annotationError(anno, "not applicable to dependencies"))
+		}
+	}
+	if !hasSource {
+		errs = append(errs, dependencyError(field, "requires one of @cave.Git, @cave.Local or @cave.Stdlib"))
+	}
+	return dep, errs
+}
+
+// packageVersion parses the version of the package itself.
+func packageVersion(anno *ast.DeclAnnotationInstance) (version.Version, *parser.ParseError) {
+	var str string
+	if err := stringArgument(anno, &str); err != nil {
+		return nil, err
+	}
+	str = strings.TrimSpace(str)
+	if str == "" {
+		err := annotationError(anno, "requires a non-empty version")
+		return nil, &err
+	}
+	if version.ParsePredicate(str).Comparison != version.ComparisonExact || strings.HasPrefix(str, "=") {
+		err := annotationError(anno, fmt.Sprintf("requires an exact version, got predicate %q", str))
+		return nil, &err
+	}
+	return version.Parse(str), nil
+}
+
+func dependencyError(field ast.DeclField, details string) parser.ParseError {
+	return parser.ParseError{
+		Token:   field.Name.Token,
+		Summary: fmt.Sprintf("dependency %s", field.Name.Value),
+		Details: details,
+	}
+}
+
+// isImportName reports whether name consists of dot separated identifiers.
+func isImportName(name string) bool {
+	for _, segment := range strings.Split(name, ".") {
+		if segment == "" || token.LookupIdent(segment) != token.IDENT {
+			return false
+		}
+		for i, r := range segment {
+			if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+				return false
+			}
+		}
+	}
+	return true
 }
@@ -0,0 +1,137 @@
+package cavefile_test
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vknabel/blush/cavefile"
+	"github.com/vknabel/blush/registry/staticmodule"
+	"github.com/vknabel/blush/version"
+)
+
+func TestLoadExample(t *testing.T) {
+	contents, err := os.ReadFile("../examples/project/Cavefile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cave, errs, err := cavefile.Load(staticmodule.NewSourceString("testing:///Cavefile", string(contents)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range errs {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	want := []cavefile.Dependency{
+		{ImportName: "tests", Kind: cavefile.SourceStdlib, Source: "tests", Constraint: cavefile.AnyVersion},
+		{ImportName: "prelude", Kind: cavefile.SourceStdlib, Source: "prelude", Constraint: cavefile.AnyVersion},
+		{ImportName: "helpers", Kind: cavefile.SourceLocal, Source: "../some-local-package", Constraint: cavefile.AnyVersion},
+		{ImportName: "future", Kind: cavefile.SourceGit, Source: "https://github.com/vknabel/blush", Constraint: version.ConstraintOf(version.ParsePredicate(">0.1.0"))},
+	}
+	if !reflect.DeepEqual(cave.Dependencies, want) {
+		t.Errorf("unexpected dependencies:\ngot  %+v\nwant %+v", cave.Dependencies, want)
+	}
+	if len(cave.Tasks) != 1 || cave.Tasks[0].Name != "generate" {
+		t.Errorf("unexpected tasks %+v", cave.Tasks)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	src := `import renamed = cave
+
+@renamed.Dependencies()
+@renamed.Version("1.0.0")
+data Deps {
+	@renamed.Git("https://example.com/foo")
+	@renamed.Version("~1.2.3")
+	@renamed.Name("foo.bar")
+	bar
+}
+`
+	cave, errs, err := cavefile.Load(staticmodule.NewSourceString("testing:///Cavefile", src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range errs {
+		t.Errorf("unexpected error: %s", err)
+	}
+	want := []cavefile.Dependency{{
+		ImportName: "foo.bar",
+		Kind:       cavefile.SourceGit,
+		Source:     "https://example.com/foo",
+		Constraint: version.ConstraintOf(version.ParsePredicate("~1.2.3")),
+	}}
+	if !reflect.DeepEqual(cave.Dependencies, want) {
+		t.Errorf("unexpected dependencies:\ngot  %+v\nwant %+v", cave.Dependencies, want)
+	}
+	if cave.Version == nil || cave.Version.String() != "1.0.0" {
+		t.Errorf("expected package version 1.0.0, got %v", cave.Version)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		fields  string
+		details string
+		line    int
+	}{
+		{"foo", "requires one of @cave.Git, @cave.Local or @cave.Stdlib", 4},
+		{"@cave.Git(\"a\")\n@cave.Local(\"b\")\nfoo", "dependencies require exactly one source", 5},
+		{"@cave.Git(42)\nfoo", "requires a string literal", 4},
+		{"@cave.Stdlib()\nfoo", "requires exactly one argument, got 0", 4},
+		{"@cave.Git(\"a\")\n@cave.Version(\"\")\nfoo", "requires a non-empty version predicate", 5},
+		{"@cave.Git(\"a\")\n@cave.Version(\">=1.0 <2.y\")\nfoo", "\"<2.y\" at column 7 is not a semantic version", 5},
+		{"@cave.Git(\"a\")\n@cave.Name(\"foo..bar\")\nfoo", "\"foo..bar\" is not a valid import name", 5},
+		{"@cave.Git(\"a\")\n@cave.Name(\"func\")\nfoo", "\"func\" is not a valid import name", 5},
+		{"@cave.Git(\"a\")\n@cave.Dependencies()\nfoo", "not applicable to dependencies", 5},
+		{"@cave.Git(\"a\")\nfoo(bar)", "dependencies cannot declare parameters", 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.details, func(t *testing.T) {
+			src := "import cave\n@cave.Dependencies()\ndata Deps {\n" + tt.fields + "\n}\n"
+			_, errs, err := cavefile.Load(staticmodule.NewSourceString("testing:///Cavefile", src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(errs) != 1 {
+				t.Fatalf("expected exactly one error, got %v", errs)
+			}
+			if !strings.Contains(errs[0].Details, tt.details) {
+				t.Errorf("expected %q, got %q", tt.details, errs[0].Details)
+			}
+			if errs[0].Token.Source == nil {
+				t.Fatal("expected positioned error")
+			}
+			if line := strings.Count(src[:errs[0].Token.Source.Offset], "\n") + 1; line != tt.line {
+				t.Errorf("expected error at line %d, got %d", tt.line, line)
+			}
+		})
+	}
+}
+
+func TestLoadDeclarationErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		details string
+	}{
+		{"import cave\n@cave.Dependencies()\ndata A {}\n@cave.Dependencies()\ndata B {}", "already declared by A"},
+		{"import cave\n@cave.Dependencies(1)\ndata A {}", "requires no arguments, got 1"},
+		{"import cave\n@cave.Git(\"a\")\ndata A {}", "not applicable to data declarations"},
+		{"import cave\n@cave.Version(\"1.0.0\")\ndata A {}", "requires @cave.Dependencies on data declarations"},
+		{"import cave\n@cave.Dependencies()\n@cave.Version(\"^1.0.0\")\ndata A {}", "requires an exact version, got predicate \"^1.0.0\""},
+		{"import cave\n@cave.Dependencies()\n@cave.Version(\"\")\ndata A {}", "requires a non-empty version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.details, func(t *testing.T) {
+			_, errs, err := cavefile.Load(staticmodule.NewSourceString("testing:///Cavefile", tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0].Details, tt.details) {
+				t.Fatalf("expected %q, got %v", tt.details, errs)
+			}
+		})
+	}
+}
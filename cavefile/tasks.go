@@ -0,0 +1,178 @@
+package cavefile
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vknabel/blush/ast"
+	"github.com/vknabel/blush/parser"
+)
+
+const tasksModule = "cave.tasks"
+
+// Task is a data declaration of a Cavefile, which can be run from the command line.
+// Exactly one of Exec and Call is set.
+type Task struct {
+	Name    string
+	Aliases []string
+	Help    string
+
+	// The file to run, relative to the Cavefile.
+	Exec string
+	// The name of the function to call with the task.
+	Call string
+
+	Flags []TaskOption
+	Args  []TaskOption
+
+	Decl *ast.DeclData
+}
+
+// TaskOption is a field of a task, which will be set by a flag or a positional argument.
+type TaskOption struct {
+	Name    string
+	Aliases []string
+	Short   rune
+	Help    string
+	// The type annotation of the field like Bool, Int, Float or String.
+	Type string
+	// The index of the field within the data declaration.
+	Field int
+}
+
+// ParseTasks finds all declarations of the source file annotated with @tasks.Exec or @tasks.Call.
+func ParseTasks(src *ast.SourceFile) ([]Task, []parser.ParseError) {
+	var (
+		tasks []Task
+		errs  []parser.ParseError
+	)
+	for _, decl := range dataDecls(src) {
+		task, isTask, taskErrs := parseTask(src.Symbols, decl)
+		errs = append(errs, taskErrs...)
+		if isTask {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, errs
+}
+
+// LookupTask returns the task with the given name or alias.
+func LookupTask(tasks []Task, name string) (Task, bool) {
+	for _, task := range tasks {
+		if task.Name == name {
+			return task, true
+		}
+		for _, alias := range task.Aliases {
+			if alias == name {
+				return task, true
+			}
+		}
+	}
+	return Task{}, false
+}
+
+func parseTask(symbols *ast.SymbolTable, decl *ast.DeclData) (Task, bool, []parser.ParseError) {
+	task := Task{
+		Name: strings.ToLower(decl.Name.Value),
+		Decl: decl,
+	}
+	var (
+		errs   []parser.ParseError
+		isTask bool
+	)
+	for _, anno := range decl.Annotations {
+		if annotationModule(symbols, anno) != tasksModule {
+			continue
+		}
+		switch anno.Reference.Name().Value {
+		case "Exec":
+			isTask = true
+			errs = appendErr(errs, stringArgument(anno, &task.Exec))
+		case "Call":
+			isTask = true
+			errs = appendErr(errs, identifierArgument(anno, &task.Call))
+		case "Name":
+			errs = appendErr(errs, stringArgument(anno, &task.Name))
+		case "Alias":
+			errs = appendErr(errs, stringsArgument(anno, &task.Aliases))
+		case "Help":
+			errs = appendErr(errs, stringArgument(anno, &task.Help))
+		case "Import":
+			errs = append(errs, annotationError(anno, "must be declared on a dependency"))
+		default:
+			errs = append(errs, annotationError(anno, "not applicable to tasks"))
+		}
+	}
+	if !isTask {
+		return task, false, errs
+	}
+	if task.Exec != "" && task.Call != "" {
+		errs = append(errs, parser.ParseError{
+			Token:   decl.Token,
+			Summary: fmt.Sprintf("task %s", decl.Name.Value),
+			Details: "requires either @tasks.Exec or @tasks.Call, not both",
+		})
+	}
+
+	for i, field := range decl.Fields {
+		opt, kind, optErrs := parseTaskOption(symbols, field)
+		opt.Field = i
+		errs = append(errs, optErrs...)
+		switch kind {
+		case "Flag":
+			task.Flags = append(task.Flags, opt)
+		case "Arg":
+			task.Args = append(task.Args, opt)
+		}
+	}
+	return task, isTask, errs
+}
+
+// parseTaskOption returns whether the field is a Flag or an Arg.
+func parseTaskOption(symbols *ast.SymbolTable, field ast.DeclField) (TaskOption, string, []parser.ParseError) {
+	opt := TaskOption{
+		Name: field.Name.Value,
+		Type: "String",
+	}
+	var (
+		errs []parser.ParseError
+		kind string
+	)
+	for _, anno := range field.Annotations {
+		module := annotationModule(symbols, anno)
+		if module == "" && len(anno.Arguments) == 0 {
+			// type annotations like @Bool
+			opt.Type = anno.Reference.Name().Value
+			continue
+		}
+		if module != tasksModule {
+			continue
+		}
+
+		switch name := anno.Reference.Name().Value; name {
+		case "Flag", "Arg":
+			if kind != "" && kind != name {
+				errs = append(errs, annotationError(anno, "fields cannot be both flags and arguments"))
+			}
+			kind = name
+		case "Name":
+			errs = appendErr(errs, stringArgument(anno, &opt.Name))
+		case "Alias":
+			errs = appendErr(errs, stringsArgument(anno, &opt.Aliases))
+		case "Short":
+			errs = appendErr(errs, charArgument(anno, &opt.Short))
+		case "Help":
+			errs = appendErr(errs, stringArgument(anno, &opt.Help))
+		default:
+			errs = append(errs, annotationError(anno, "not applicable to fields of tasks"))
+		}
+	}
+	if kind == "Arg" && opt.Short != 0 {
+		errs = append(errs, parser.ParseError{
+			Token:   field.Name.Token,
+			Summary: fmt.Sprintf("argument %s", field.Name.Value),
+			Details: "@tasks.Short is only applicable to flags",
+		})
+	}
+	return opt, kind, errs
+}
@@ -0,0 +1,130 @@
+package cavefile_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vknabel/blush/ast"
+	"github.com/vknabel/blush/cavefile"
+	"github.com/vknabel/blush/lexer"
+	"github.com/vknabel/blush/parser"
+	"github.com/vknabel/blush/registry/staticmodule"
+)
+
+func parseCavefile(t *testing.T, input string) *ast.SourceFile {
+	t.Helper()
+	parentTable := ast.MakeSymbolTable(nil, ast.Identifier{Value: "Cavefile"})
+	l, err := lexer.New(staticmodule.NewSourceString("testing:///Cavefile", input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := parser.NewSourceParser(l, parentTable, "Cavefile")
+	srcFile := p.ParseSourceFile()
+	for _, err := range p.Errors() {
+		t.Errorf("parser error: %s", err)
+	}
+	return srcFile
+}
+
+func TestParseTasks(t *testing.T) {
+	src := parseCavefile(t, `import cave.tasks
+
+@tasks.Name("generate")
+@tasks.Alias(["gen"])
+@tasks.Help("Generates something")
+@tasks.Exec("tasks/generate.blush")
+data GenerateTask {
+	@Bool
+	@tasks.Flag()
+	@tasks.Name("dry")
+	@tasks.Short('d')
+	isDryRun
+
+	@Int
+	@tasks.Arg()
+	count
+
+	untouched
+}
+
+@tasks.Call(greet)
+data Hello {
+	@tasks.Arg()
+	name
+}
+
+data NoTask {}
+
+func greet(hello) {}
+`)
+	tasks, errs := cavefile.ParseTasks(src)
+	for _, err := range errs {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(tasks))
+	}
+
+	gen := tasks[0]
+	if gen.Name != "generate" || gen.Help != "Generates something" || gen.Exec != "tasks/generate.blush" || gen.Call != "" {
+		t.Errorf("unexpected task %+v", gen)
+	}
+	if len(gen.Aliases) != 1 || gen.Aliases[0] != "gen" {
+		t.Errorf("unexpected aliases %v", gen.Aliases)
+	}
+	if len(gen.Flags) != 1 || len(gen.Args) != 1 {
+		t.Fatalf("expected one flag and one arg, got %+v and %+v", gen.Flags, gen.Args)
+	}
+	if flag := gen.Flags[0]; flag.Name != "dry" || flag.Short != 'd' || flag.Type != "Bool" || flag.Field != 0 {
+		t.Errorf("unexpected flag %+v", flag)
+	}
+	if arg := gen.Args[0]; arg.Name != "count" || arg.Type != "Int" || arg.Field != 1 {
+		t.Errorf("unexpected arg %+v", arg)
+	}
+
+	hello := tasks[1]
+	if hello.Name != "hello" || hello.Call != "greet" || hello.Exec != "" {
+		t.Errorf("unexpected task %+v", hello)
+	}
+	if len(hello.Args) != 1 || hello.Args[0].Type != "String" {
+		t.Errorf("unexpected args %+v", hello.Args)
+	}
+
+	if _, ok := cavefile.LookupTask(tasks, "gen"); !ok {
+		t.Error("expected to find task by alias")
+	}
+	if _, ok := cavefile.LookupTask(tasks, "notask"); ok {
+		t.Error("expected data without @tasks.Exec or @tasks.Call not to be a task")
+	}
+}
+
+func TestParseTasksErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		details string
+	}{
+		{"import cave.tasks\n@tasks.Exec(42)\ndata A {}", "requires a string literal"},
+		{"import cave.tasks\n@tasks.Call(\"f\")\ndata A {}", "requires the name of a function"},
+		{"import cave.tasks\n@tasks.Exec()\ndata A {}", "requires exactly one argument, got 0"},
+		{"import cave.tasks\n@tasks.Exec(\"a\")\n@tasks.Call(f)\ndata A {}", "requires either @tasks.Exec or @tasks.Call, not both"},
+		{"import cave.tasks\n@tasks.Exec(\"a\")\n@tasks.Flag()\ndata A {}", "not applicable to tasks"},
+		{"import cave.tasks\n@tasks.Exec(\"a\")\ndata A {\n@tasks.Arg()\n@tasks.Short('a')\nfield\n}", "@tasks.Short is only applicable to flags"},
+		{"import cave.tasks\n@tasks.Exec(\"a\")\ndata A {\n@tasks.Arg()\n@tasks.Flag()\nfield\n}", "fields cannot be both flags and arguments"},
+		{"import renamed = cave.tasks\n@renamed.Exec(1)\ndata A {}", "requires a string literal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.details, func(t *testing.T) {
+			src := parseCavefile(t, tt.input)
+			_, errs := cavefile.ParseTasks(src)
+			if len(errs) != 1 {
+				t.Fatalf("expected exactly one error, got %v", errs)
+			}
+			if !strings.Contains(errs[0].Details, tt.details) {
+				t.Errorf("expected %q, got %q", tt.details, errs[0].Details)
+			}
+			if errs[0].Token.Source == nil {
+				t.Error("expected positioned error")
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/vknabel/blush/ast"
+	"github.com/vknabel/blush/compiler"
+	"github.com/vknabel/blush/runtime"
+	"github.com/vknabel/blush/vm"
+	"github.com/vknabel/blush/world"
+)
+
+func runCommand(w world.World, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(w.OS.Stderr(), "usage: blush run <file|dir>")
+		return exitUsage
+	}
+	return runModule(w, "run", args[0])
+}
+
+// runModule compiles and runs the module at the given path.
+func runModule(w world.World, name string, path string, plugins ...runtime.ExternPlugin) int {
+	mod, ctxModule, code := parseModuleArg(w, name, []string{path})
+	if ctxModule == nil {
+		return code
+	}
+
+	comp := compiler.New(plugins...)
+	if err := comp.Compile(ctxModule); err != nil {
+		mod.reportCompileError(w.OS.Stderr(), err)
+		return exitFailure
+	}
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		fmt.Fprintf(w.OS.Stderr(), "%s: runtime error: %s\n", mod.URI(), err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func checkCommand(w world.World, args []string) int {
+	mod, ctxModule, code := parseModuleArg(w, "check", args)
+	if ctxModule == nil {
+		return code
+	}
+	// compiling resolves all identifiers without running the module
+	if err := compiler.New().Compile(ctxModule); err != nil {
+		mod.reportCompileError(w.OS.Stderr(), err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func buildCommand(w world.World, args []string) int {
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	flags.SetOutput(w.OS.Stderr())
+	output := flags.String("o", "", "output file, defaults to the module name with .blushc extension")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	mod, ctxModule, code := parseModuleArg(w, "build", flags.Args())
+	if ctxModule == nil {
+		return code
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(ctxModule); err != nil {
+		mod.reportCompileError(w.OS.Stderr(), err)
+		return exitFailure
+	}
+	data, err := comp.Bytecode().MarshalBinary()
+	if err != nil {
+		fmt.Fprintf(w.OS.Stderr(), "%s: %s\n", mod.URI(), err)
+		return exitFailure
+	}
+
+	path := *output
+	if path == "" {
+		path = strings.TrimSuffix(string(mod.URI()), sourceExt) + ".blushc"
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		fmt.Fprintf(w.OS.Stderr(), "blush build: %s\n", err)
+		return exitFailure
+	}
+	if err := writeFile(w, path, data); err != nil {
+		fmt.Fprintf(w.OS.Stderr(), "blush build: %s\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// parseModuleArg loads and parses the module of the only argument and all modules it imports.
+// On failure, the errors have already been reported and the exit code is returned.
+func parseModuleArg(w world.World, name string, args []string) (*sourceModule, *ast.ContextModule, int) {
+	if len(args) != 1 {
+		fmt.Fprintf(w.OS.Stderr(), "usage: blush %s <file|dir>\n", name)
+		return nil, nil, exitUsage
+	}
+
+	mod, err := loadModule(w.FS, args[0])
+	if err != nil {
+		fmt.Fprintf(w.OS.Stderr(), "blush %s: %s\n", name, err)
+		return nil, nil, exitFailure
+	}
+	ctxModule, errs, err := mod.parse(w)
+	if err != nil {
+		fmt.Fprintf(w.OS.Stderr(), "blush %s: %s\n", name, err)
+		return nil, nil, exitFailure
+	}
+	if len(errs) > 0 {
+		mod.report(w.OS.Stderr(), errs)
+		return nil, nil, exitFailure
+	}
+	return mod, ctxModule, exitOK
+}
+
+func writeFile(w world.World, path string, data []byte) error {
+	f, err := w.FS.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+// Command blush runs, checks and builds Blush programs and vendors their dependencies.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/vknabel/blush/cavefile"
+	"github.com/vknabel/blush/world"
+)
+
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+type command struct {
+	name  string
+	usage string
+	help  string
+	run   func(w world.World, args []string) int
+}
+
+var commands = []command{
+	{"run", "<file|dir>", "compiles and runs a module", runCommand},
+	{"check", "<file|dir>", "reports syntax and declaration errors", checkCommand},
+	{"build", "[-o output] <file|dir>", "compiles a module into bytecode", buildCommand},
+	{"vendor", "", "copies all dependencies into the vendor directory", vendorCommand},
+}
+
+func main() {
+	w := world.World{
+		// paths are made absolute by the commands
+		FS: osfs.New("/"),
+		OS: world.LiveOS(),
+	}
+	Main(w, os.Args[1:])
+}
+
+// Main runs the command line interface and exits through the world's OS.
+func Main(w world.World, args []string) {
+	w.OS.Exit(dispatch(w, args))
+}
+
+func dispatch(w world.World, args []string) int {
+	if len(args) == 0 {
+		printUsage(w, w.OS.Stderr())
+		return exitUsage
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		printUsage(w, w.OS.Stdout())
+		return exitOK
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(w, args[1:])
+		}
+	}
+
+	cave, err := loadTasks(w)
+	if err != nil {
+		fmt.Fprintln(w.OS.Stderr(), err)
+		return exitFailure
+	}
+	if cave != nil {
+		if task, ok := cavefile.LookupTask(cave.tasks, args[0]); ok {
+			return cave.runTask(w, task, args[1:])
+		}
+	}
+
+	fmt.Fprintf(w.OS.Stderr(), "blush: unknown command %q\n", args[0])
+	printUsage(w, w.OS.Stderr())
+	return exitUsage
+}
+
+// printUsage lists all commands including the tasks of the Cavefile within the current directory.
+func printUsage(w world.World, out io.Writer) {
+	fmt.Fprintln(out, "usage: blush <command> [arguments]")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-8s %-24s %s\n", cmd.name, cmd.usage, cmd.help)
+	}
+
+	cave, err := loadTasks(w)
+	if err != nil || cave == nil || len(cave.tasks) == 0 {
+		return
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "tasks:")
+	for _, task := range cave.tasks {
+		fmt.Fprintf(out, "  %-33s %s\n", task.Name, task.Help)
+	}
+}
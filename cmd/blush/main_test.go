@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/vendorreg"
+	"github.com/vknabel/blush/world"
+)
+
+type testOS struct {
+	code   int
+	exited bool
+	stdout bytes.Buffer
+	stderr bytes.Buffer
+	env    map[string]string
+}
+
+func (o *testOS) Exit(code int) {
+	o.code = code
+	o.exited = true
+}
+
+func (o *testOS) Stdout() io.Writer { return &o.stdout }
+func (o *testOS) Stderr() io.Writer { return &o.stderr }
+func (o *testOS) Getenv(key string) string {
+	return o.env[key]
+}
+
+func runMain(t *testing.T, files map[string]string, args ...string) (*testOS, world.World) {
+	t.Helper()
+	fs := memfs.New()
+	for name, contents := range files {
+		err := billyutil.WriteFile(fs, name, []byte(contents), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	os := &testOS{}
+	w := world.World{FS: fs, OS: os}
+	Main(w, args)
+	if !os.exited {
+		t.Fatal("expected to exit")
+	}
+	return os, w
+}
+
+func TestUsage(t *testing.T) {
+	os, _ := runMain(t, nil)
+	if os.code != exitUsage {
+		t.Errorf("expected exit code %d, got %d", exitUsage, os.code)
+	}
+	if !strings.Contains(os.stderr.String(), "usage: blush <command>") {
+		t.Errorf("expected usage, got %q", os.stderr.String())
+	}
+
+	os, _ = runMain(t, nil, "unknown")
+	if os.code != exitUsage {
+		t.Errorf("expected exit code %d, got %d", exitUsage, os.code)
+	}
+}
+
+func TestRun(t *testing.T) {
+	files := map[string]string{
+		"/project/a.blush":           "func answer() { return 42 }\n",
+		"/project/b.blush":           "answer()\n",
+		"/failing.blush":             "[1][2]\n",
+		"/imports/main.blush":        "import cave\nimport maths { double }\n\n@cave.Stdlib(\"x\")\ndata Dep {}\n\n[1][maths.double(1) + double(0)]\n",
+		"/imports/maths/maths.blush": "func double(x) { return x * 2 }\n",
+	}
+
+	tests := []struct {
+		path   string
+		code   int
+		stderr string
+	}{
+		{path: "/project", code: exitOK},
+		{path: "/project/a.blush", code: exitOK},
+		{path: "/failing.blush", code: exitFailure, stderr: "/failing.blush: runtime error: array index 2 out of bounds\n"},
+		{path: "/imports/main.blush", code: exitFailure, stderr: "/imports/main.blush: runtime error: array index 2 out of bounds\n"},
+		{path: "/missing.blush", code: exitFailure, stderr: "blush run: "},
+	}
+	for _, tt := range tests {
+		os, _ := runMain(t, files, "run", tt.path)
+		if os.code != tt.code {
+			t.Errorf("%s: expected exit code %d, got %d: %s", tt.path, tt.code, os.code, os.stderr.String())
+		}
+		if !strings.HasPrefix(os.stderr.String(), tt.stderr) {
+			t.Errorf("%s: expected stderr %q, got %q", tt.path, tt.stderr, os.stderr.String())
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	files := map[string]string{
+		"/valid.blush":   "let a = 1\n",
+		"/invalid.blush": "let a = 1\nlet = 2\n",
+		"/twice.blush":   "func a() {}\nfunc a() {}\n",
+		"/missing.blush": "let a = 1\nfunc f() {\n  return a + x\n}\n",
+		// errors of imported modules are located within their own sources
+		"/app/main.blush":      "import lib\nlib.f()\n",
+		"/app/lib/lib.blush":   "func f() {\n  return y\n}\n",
+		"/other/main.blush":    "import lib\n",
+		"/other/lib/lib.blush": "let a = 1\nlet = 2\n",
+	}
+
+	os, _ := runMain(t, files, "check", "/valid.blush")
+	if os.code != exitOK {
+		t.Errorf("expected exit code %d, got %d: %s", exitOK, os.code, os.stderr.String())
+	}
+
+	os, _ = runMain(t, files, "check", "/invalid.blush")
+	if os.code != exitFailure {
+		t.Errorf("expected exit code %d, got %d", exitFailure, os.code)
+	}
+	if !strings.HasPrefix(os.stderr.String(), "/invalid.blush:2:5: ") {
+		t.Errorf("expected positioned parse error, got %q", os.stderr.String())
+	}
+
+	os, _ = runMain(t, files, "check", "/twice.blush")
+	if os.code != exitFailure {
+		t.Errorf("expected exit code %d, got %d", exitFailure, os.code)
+	}
+	if !strings.Contains(os.stderr.String(), "declaration error, symbol already defined") {
+		t.Errorf("expected symbol error, got %q", os.stderr.String())
+	}
+
+	os, _ = runMain(t, files, "check", "/missing.blush")
+	if os.code != exitFailure {
+		t.Errorf("expected exit code %d, got %d", exitFailure, os.code)
+	}
+	if want := "/missing.blush:3:14: undefined identifier \"x\"\n"; os.stderr.String() != want {
+		t.Errorf("expected %q, got %q", want, os.stderr.String())
+	}
+
+	os, _ = runMain(t, files, "check", "/app/main.blush")
+	if want := "/app/lib/lib.blush:2:10: undefined identifier \"y\"\n"; os.stderr.String() != want {
+		t.Errorf("expected %q, got %q", want, os.stderr.String())
+	}
+
+	os, _ = runMain(t, files, "check", "/other/main.blush")
+	if !strings.HasPrefix(os.stderr.String(), "/other/lib/lib.blush:2:5: ") {
+		t.Errorf("expected positioned parse error of import, got %q", os.stderr.String())
+	}
+}
+
+func TestBuild(t *testing.T) {
+	files := map[string]string{
+		"/main.blush": "func answer() { return 42 }\nanswer()\n",
+	}
+
+	os, w := runMain(t, files, "build", "/main.blush")
+	if os.code != exitOK {
+		t.Fatalf("expected exit code %d, got %d: %s", exitOK, os.code, os.stderr.String())
+	}
+	data, err := billyutil.ReadFile(w.FS, "/main.blushc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("BLSHBC")) {
+		t.Errorf("expected bytecode header, got %q", data)
+	}
+
+	os, w = runMain(t, files, "build", "-o", "/out/program", "/main.blush")
+	if os.code != exitOK {
+		t.Fatalf("expected exit code %d, got %d: %s", exitOK, os.code, os.stderr.String())
+	}
+	if _, err := w.FS.Stat("/out/program"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTasks(t *testing.T) {
+	cavefile, err := filepath.Abs("Cavefile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks := `import cave.tasks
+
+@tasks.Help("Picks an element")
+@tasks.Alias(["p"])
+@tasks.Call(pick)
+data Pick {
+	@Int
+	@tasks.Flag()
+	@tasks.Short('o')
+	@tasks.Help("added to the index")
+	offset
+
+	@Int
+	@tasks.Arg()
+	index
+}
+
+func pick(task) {
+	return [1, 2][task.index + task.offset]
+}
+
+@tasks.Name("script")
+@tasks.Exec("tasks/script.blush")
+data ScriptTask {
+	@Int
+	@tasks.Arg()
+	index
+}
+`
+	// the executed file declares another type where the Cavefile declares the task
+	script := strings.Replace(tasks, "data ScriptTask", "data Other", 1) + `
+extern let task
+switch task {
+case @Other: [][0]
+case _: [1][task.index]
+}
+`
+	files := map[string]string{
+		cavefile: tasks,
+		filepath.Join(filepath.Dir(cavefile), "tasks", "script.blush"): script,
+	}
+
+	tests := []struct {
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{args: []string{"pick", "1", "--offset", "0"}, code: exitOK},
+		{args: []string{"p", "-o", "1", "0"}, code: exitOK},
+		{args: []string{"pick", "0", "-o=2"}, code: exitFailure, stderr: "blush pick: runtime error: array index 2 out of bounds\n"},
+		{args: []string{"pick", "one"}, code: exitUsage, stderr: "blush pick: index must be an Int, got \"one\"\n"},
+		{args: []string{"pick", "0", "1"}, code: exitUsage, stderr: "blush pick: too many arguments, want at most 1\n"},
+		{args: []string{"pick", "-h"}, code: exitOK, stdout: "usage: blush pick [flags] [index]\n\nPicks an element\n"},
+		{args: []string{"script", "0"}, code: exitOK},
+		{args: []string{"script", "1"}, code: exitFailure, stderr: filepath.Join(filepath.Dir(cavefile), "tasks", "script.blush") + ": runtime error: array index 1 out of bounds\n"},
+		{args: []string{"help"}, code: exitOK, stdout: "usage: blush <command>"},
+	}
+	for _, tt := range tests {
+		os, _ := runMain(t, files, tt.args...)
+		if os.code != tt.code {
+			t.Errorf("%v: expected exit code %d, got %d: %s", tt.args, tt.code, os.code, os.stderr.String())
+		}
+		if !strings.HasPrefix(os.stdout.String(), tt.stdout) {
+			t.Errorf("%v: expected stdout %q, got %q", tt.args, tt.stdout, os.stdout.String())
+		}
+		if !strings.HasPrefix(os.stderr.String(), tt.stderr) {
+			t.Errorf("%v: expected stderr %q, got %q", tt.args, tt.stderr, os.stderr.String())
+		}
+	}
+
+	os, _ := runMain(t, files, "help")
+	if !strings.Contains(os.stdout.String(), "tasks:\n  pick ") {
+		t.Errorf("expected tasks within usage, got %q", os.stdout.String())
+	}
+}
+
+func TestVendor(t *testing.T) {
+	cavefile, err := filepath.Abs("Cavefile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	project := filepath.Dir(cavefile)
+	vendored := filepath.Join(project, "vendor", vendorreg.PackageDir("example.com/strings"))
+	files := map[string]string{
+		cavefile: `import cave
+
+@cave.Dependencies()
+data Deps {
+	@cave.Git("example.com/strings")
+	@cave.Version("^1.0.0")
+	strings
+
+	@cave.Local("lib")
+	lib
+}
+`,
+		filepath.Join(project, "lib", "lib.blush"):  "let lib = 1\n",
+		filepath.Join(project, "main.blush"):        "import strings\nimport lib\nlet total = strings.strings + lib.lib\n",
+		filepath.Join(vendored, "Cavefile"):         "import cave\n",
+		filepath.Join(vendored, "strings.blush"):    "let strings = 1\n",
+		filepath.Join(project, "vendor", "stale.x"): "stale",
+	}
+	fs := memfs.New()
+	for name, contents := range files {
+		if err := billyutil.WriteFile(fs, name, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// vendored by a previous run
+	entry := vendorreg.VendoredPackage{Source: "example.com/strings", Version: "1.2.0", Dir: filepath.Base(vendored)}
+	manifest := vendorreg.Manifest{Packages: []vendorreg.VendoredPackage{entry}}
+	if err := vendorreg.WriteManifest(fs, filepath.Join(project, "vendor"), manifest); err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err := vendorreg.New(fs, filepath.Join(project, "vendor")).Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest.Packages[0].Hash, err = registry.ContentHash(pkgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vendorreg.WriteManifest(fs, filepath.Join(project, "vendor"), manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	// without network access, the git dependency is served from the vendor directory
+	os := &testOS{env: map[string]string{"BLUSH_PACKAGES": "/packages"}}
+	Main(world.World{FS: fs, OS: os}, []string{"vendor"})
+	if os.code != exitOK {
+		t.Fatalf("expected exit code %d, got %d: %s", exitOK, os.code, os.stderr.String())
+	}
+	if _, err := fs.Stat(filepath.Join(project, "vendor", "stale.x")); err == nil {
+		t.Error("expected stale files to be removed")
+	}
+	if _, err := fs.Stat(filepath.Join(vendored, "strings.blush")); err != nil {
+		t.Errorf("expected vendored package to be kept, got %v", err)
+	}
+	got, err := vendorreg.ReadManifest(fs, filepath.Join(project, "vendor"))
+	if err != nil || got == nil || !reflect.DeepEqual(got.Packages, manifest.Packages) {
+		t.Errorf("expected manifest %+v, got %+v, %v", manifest, got, err)
+	}
+	lock, err := billyutil.ReadFile(fs, filepath.Join(project, "Cavefile.lock"))
+	if err != nil || !strings.Contains(string(lock), manifest.Packages[0].Hash) {
+		t.Errorf("expected locked content hash, got %s, %v", lock, err)
+	}
+
+	// run and build import the dependencies from the vendor directory
+	for _, cmd := range []string{"run", "build"} {
+		os = &testOS{env: map[string]string{"BLUSH_PACKAGES": "/packages"}}
+		Main(world.World{FS: fs, OS: os}, []string{cmd, filepath.Join(project, "main.blush")})
+		if os.code != exitOK {
+			t.Errorf("expected %s to exit with %d, got %d: %s", cmd, exitOK, os.code, os.stderr.String())
+		}
+	}
+
+	os = &testOS{}
+	Main(world.World{FS: fs, OS: os}, []string{"vendor", "extra"})
+	if os.code != exitUsage {
+		t.Errorf("expected exit code %d, got %d", exitUsage, os.code)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	"github.com/vknabel/blush/ast"
+	"github.com/vknabel/blush/compiler"
+	"github.com/vknabel/blush/loader"
+	"github.com/vknabel/blush/parser"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/staticmodule"
+	"github.com/vknabel/blush/registry/stdlibreg"
+	"github.com/vknabel/blush/token"
+	"github.com/vknabel/blush/version"
+	"github.com/vknabel/blush/world"
+)
+
+const sourceExt = ".blush"
+
+var errNoSources = errors.New("no " + sourceExt + " files")
+
+// sourceModule is a module loaded from a single file or all source files of a directory.
+type sourceModule struct {
+	*staticmodule.StaticModule
+	// the directory of the module, which is the root of the project for imports
+	dir      string
+	contents map[string]string
+	// all modules loaded by parse, which may be reported on
+	loaded []registry.ResolvedModule
+}
+
+// loadModule reads the given file or all source files directly within the given directory.
+func loadModule(fs billy.Filesystem, path string) (*sourceModule, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fs.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	dir := filepath.Dir(path)
+	if info.IsDir() {
+		dir = path
+		entries, err := fs.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && filepath.Ext(entry.Name()) == sourceExt {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("%w in %s", errNoSources, path)
+		}
+		slices.Sort(files)
+	} else {
+		files = []string{path}
+	}
+
+	mod := &sourceModule{
+		StaticModule: staticmodule.NewModule(registry.LogicalURI(path), nil),
+		dir:          dir,
+		contents:     make(map[string]string, len(files)),
+	}
+	for _, name := range files {
+		f, err := fs.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		contents, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		mod.Srcs = append(mod.Srcs, staticmodule.NewSource(registry.LogicalURI(name), contents))
+		mod.contents[name] = string(contents)
+	}
+	return mod, nil
+}
+
+// parse parses all sources of the module and all modules it imports.
+// Returns all syntax and declaration errors.
+// The prelude is implicitly imported, other modules are imported from the module's directory,
+// the dependencies of its Cavefile or the standard library.
+// The standard library may be overridden by $BLUSH_STDLIB.
+func (m *sourceModule) parse(w world.World) (*ast.ContextModule, []parser.ParseError, error) {
+	stdlib, err := stdlibreg.FromWorld(w)
+	if err != nil {
+		return nil, nil, err
+	}
+	preludeModule, err := stdlib.Prelude()
+	if err != nil {
+		return nil, nil, err
+	}
+	prelude, err := parser.ParsePrelude(preludeModule)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := context.Background()
+	stdlibPkgs, err := stdlib.Discover(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var opts []loader.Option
+	for _, pkg := range stdlibPkgs {
+		opts = append(opts, loader.WithPackage(pkg.Source(), pkg))
+	}
+	deps, err := dependencies(w, m.dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, pkg := range deps {
+		opts = append(opts, loader.WithPackage(name, pkg))
+	}
+	opts = append(opts, loader.WithProject(projectPackage{fs: w.FS, dir: m.dir}))
+
+	ld := loader.New(prelude, opts...)
+	ctxModule, err := ld.Load(m)
+	m.loaded = ld.Modules()
+	if err != nil {
+		return nil, nil, err
+	}
+	return ctxModule, ld.Errors(), nil
+}
+
+// projectPackage resolves the submodules of the project directory by the path of each import,
+// instead of discovering all modules within the project.
+// Packages vendored into the project are only imported as dependencies.
+type projectPackage struct {
+	fs  billy.Filesystem
+	dir string
+}
+
+// ResolveModule implements loader.ModuleResolver.
+func (p projectPackage) ResolveModule(modPath string) (registry.ResolvedModule, error) {
+	if modPath == "" || modPath == vendorDir || strings.HasPrefix(modPath, vendorDir+"/") {
+		return nil, nil
+	}
+	dir := filepath.Join(p.dir, filepath.FromSlash(modPath))
+	if info, err := p.fs.Stat(dir); err != nil || !info.IsDir() {
+		return nil, nil
+	}
+	mod, err := loadModule(p.fs, dir)
+	if errors.Is(err, errNoSources) {
+		return nil, nil
+	}
+	return mod, err
+}
+
+// Source implements registry.Package.
+func (p projectPackage) Source() string {
+	return p.dir
+}
+
+// Version implements registry.Package.
+func (p projectPackage) Version() version.Version {
+	return version.SemverVersion{}
+}
+
+// Resolve implements registry.Package.
+func (p projectPackage) Resolve(ctx context.Context) (registry.ResolvedPackage, error) {
+	return p, nil
+}
+
+// ResolveModules implements registry.ResolvedPackage.
+// Submodules are resolved on demand by ResolveModule.
+func (p projectPackage) ResolveModules() ([]registry.ResolvedModule, error) {
+	return nil, nil
+}
+
+// Cavefile implements registry.ResolvedPackage.
+func (p projectPackage) Cavefile() (registry.Source, error) {
+	return registry.Cavefile(registry.LogicalURI(p.dir), func(name string) ([]byte, error) {
+		return billyutil.ReadFile(p.fs, filepath.Join(p.dir, name))
+	})
+}
+
+// position formats the location of the token as file:line:col.
+// Tokens of imported modules are located within the sources of the module owning them.
+func (m *sourceModule) position(tok token.Token) string {
+	src := tok.Source
+	if src == nil {
+		return string(m.URI())
+	}
+	contents, ok := m.contents[src.File]
+	if !ok {
+		contents, ok = m.loadedContents(src.File)
+	}
+	if !ok || src.Offset > len(contents) {
+		return src.File
+	}
+	before := contents[:src.Offset]
+	line := strings.Count(before, "\n") + 1
+	col := src.Offset - strings.LastIndex(before, "\n")
+	return fmt.Sprintf("%s:%d:%d", src.File, line, col)
+}
+
+// loadedContents reads the source with the given URI from the loaded module owning it.
+func (m *sourceModule) loadedContents(uri string) (string, bool) {
+	for _, mod := range m.loaded {
+		srcs, err := mod.Sources()
+		if err != nil {
+			continue
+		}
+		for _, src := range srcs {
+			if string(src.URI()) != uri {
+				continue
+			}
+			contents, err := src.Read()
+			if err != nil {
+				return "", false
+			}
+			m.contents[uri] = string(contents)
+			return m.contents[uri], true
+		}
+	}
+	return "", false
+}
+
+// report prints all errors with their positions.
+func (m *sourceModule) report(out io.Writer, errs []parser.ParseError) {
+	for _, err := range errs {
+		fmt.Fprintf(out, "%s: %s, %s\n", m.position(err.Token), err.Summary, err.Details)
+	}
+}
+
+// reportCompileError prints the compile error at its position, if known.
+func (m *sourceModule) reportCompileError(out io.Writer, err error) {
+	var srcErr compiler.SourceError
+	if errors.As(err, &srcErr) {
+		fmt.Fprintf(out, "%s: %s\n", m.position(srcErr.Token), srcErr.Message)
+		return
+	}
+	fmt.Fprintf(out, "%s: %s\n", m.URI(), err)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/vknabel/blush"
+	"github.com/vknabel/blush/ast"
+	"github.com/vknabel/blush/cavefile"
+	"github.com/vknabel/blush/parser"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/stdlibreg"
+	"github.com/vknabel/blush/runtime"
+	"github.com/vknabel/blush/world"
+)
+
+// cavefileTasks is the Cavefile of the current directory and its tasks.
+type cavefileTasks struct {
+	module *sourceModule
+	tasks  []cavefile.Task
+}
+
+// loadTasks loads the tasks of the Cavefile within the current directory.
+// Returns nil if there is no Cavefile.
+func loadTasks(w world.World) (*cavefileTasks, error) {
+	path, err := filepath.Abs(registry.CavefileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.FS.Stat(path); err != nil {
+		return nil, nil
+	}
+
+	mod, err := loadModule(w.FS, path)
+	if err != nil {
+		return nil, err
+	}
+	// imports of the Cavefile are not resolved, hence only syntax errors are relevant
+	mp := parser.NewModuleParse(mod)
+	ctxModule, err := mp.Parse(mod)
+	if err != nil {
+		return nil, err
+	}
+	errs := mp.Errors()
+	if len(errs) > 0 {
+		return nil, &diagnosticsError{mod, errs}
+	}
+
+	ct := &cavefileTasks{module: mod}
+	for _, src := range ctxModule.Files {
+		cave, caveErrs := cavefile.Parse(src)
+		ct.tasks = append(ct.tasks, cave.Tasks...)
+		errs = append(errs, caveErrs...)
+	}
+	if len(errs) > 0 {
+		return nil, &diagnosticsError{mod, errs}
+	}
+	return ct, nil
+}
+
+// diagnosticsError reports positioned errors of a module.
+type diagnosticsError struct {
+	module *sourceModule
+	errs   []parser.ParseError
+}
+
+func (e *diagnosticsError) Error() string {
+	var out strings.Builder
+	e.module.report(&out, e.errs)
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// runTask parses the flags and arguments of the task into a data value and runs the task with it.
+func (c *cavefileTasks) runTask(w world.World, task cavefile.Task, args []string) int {
+	values, err := parseTaskArgs(w, task, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintf(w.OS.Stderr(), "blush %s: %s\n", task.Name, err)
+		printTaskUsage(w.OS.Stderr(), task)
+		return exitUsage
+	}
+
+	stdlib, err := stdlibreg.FromWorld(w)
+	if err != nil {
+		fmt.Fprintf(w.OS.Stderr(), "blush %s: %s\n", task.Name, err)
+		return exitFailure
+	}
+	engine := blush.New()
+	engine.UseStdlib(stdlib)
+	prog, err := engine.LoadModule(c.module)
+	if err != nil {
+		fmt.Fprintf(w.OS.Stderr(), "%s: %s\n", c.module.URI(), err)
+		return exitFailure
+	}
+	dataType, err := prog.Lookup(task.Decl.Name.Value)
+	if err != nil {
+		fmt.Fprintf(w.OS.Stderr(), "%s: %s\n", c.module.URI(), err)
+		return exitFailure
+	}
+
+	if task.Call != "" {
+		instance := runtime.MakeDataValue(dataType.(*runtime.DataType), values)
+		_, err := prog.Call(task.Call, instance)
+		if err != nil {
+			fmt.Fprintf(w.OS.Stderr(), "blush %s: runtime error: %s\n", task.Name, err)
+			return exitFailure
+		}
+		return exitOK
+	}
+
+	path := filepath.Join(filepath.Dir(string(c.module.URI())), task.Exec)
+	return runModule(w, task.Name, path, taskPlugin{dataType.(*runtime.DataType), values})
+}
+
+// parseTaskArgs converts flags and positional arguments into the field values of the task.
+// Flags may appear before, between or after arguments.
+func parseTaskArgs(w world.World, task cavefile.Task, args []string) ([]runtime.RuntimeValue, error) {
+	values := make([]runtime.RuntimeValue, len(task.Decl.Fields))
+	for i := range values {
+		values[i] = runtime.Null{}
+	}
+
+	flags := flag.NewFlagSet(task.Name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Usage = func() {
+		printTaskUsage(w.OS.Stdout(), task)
+	}
+	for _, opt := range task.Flags {
+		if opt.Type == "Bool" {
+			values[opt.Field] = runtime.Bool(false)
+		}
+		val := &optionValue{opt: opt, dest: &values[opt.Field]}
+		for _, name := range optionNames(opt) {
+			flags.Var(val, name, opt.Help)
+		}
+	}
+
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if len(positional) > len(task.Args) {
+		return nil, fmt.Errorf("too many arguments, want at most %d", len(task.Args))
+	}
+	for i, arg := range positional {
+		opt := task.Args[i]
+		val, err := parseOption(opt, arg)
+		if err != nil {
+			return nil, err
+		}
+		values[opt.Field] = val
+	}
+	return values, nil
+}
+
+func optionNames(opt cavefile.TaskOption) []string {
+	names := append([]string{opt.Name}, opt.Aliases...)
+	if opt.Short != 0 {
+		names = append(names, string(opt.Short))
+	}
+	return names
+}
+
+func parseOption(opt cavefile.TaskOption, arg string) (runtime.RuntimeValue, error) {
+	switch opt.Type {
+	case "Bool":
+		b, err := strconv.ParseBool(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a Bool, got %q", opt.Name, arg)
+		}
+		return runtime.Bool(b), nil
+	case "Int":
+		i, err := strconv.ParseInt(arg, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be an Int, got %q", opt.Name, arg)
+		}
+		return runtime.Int(i), nil
+	case "Float":
+		f, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a Float, got %q", opt.Name, arg)
+		}
+		return runtime.Float(f), nil
+	default:
+		return runtime.String(arg), nil
+	}
+}
+
+// optionValue sets a field of the task when its flag is parsed.
+type optionValue struct {
+	opt  cavefile.TaskOption
+	dest *runtime.RuntimeValue
+}
+
+// String implements flag.Value.
+func (v *optionValue) String() string {
+	if v == nil || v.dest == nil {
+		return ""
+	}
+	return (*v.dest).Inspect()
+}
+
+// Set implements flag.Value.
+func (v *optionValue) Set(arg string) error {
+	val, err := parseOption(v.opt, arg)
+	if err != nil {
+		return err
+	}
+	*v.dest = val
+	return nil
+}
+
+// IsBoolFlag allows Bool flags without explicit values.
+func (v *optionValue) IsBoolFlag() bool {
+	return v.opt.Type == "Bool"
+}
+
+func printTaskUsage(out io.Writer, task cavefile.Task) {
+	usage := "usage: blush " + task.Name
+	if len(task.Flags) > 0 {
+		usage += " [flags]"
+	}
+	for _, arg := range task.Args {
+		usage += " [" + arg.Name + "]"
+	}
+	fmt.Fprintln(out, usage)
+	if task.Help != "" {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, task.Help)
+	}
+
+	if len(task.Flags) > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "flags:")
+		for _, opt := range task.Flags {
+			names := optionNames(opt)
+			for i, name := range names {
+				names[i] = "--" + name
+				if len(name) == 1 {
+					names[i] = "-" + name
+				}
+			}
+			fmt.Fprintf(out, "  %-24s %s\n", strings.Join(names, ", "), opt.Help)
+		}
+	}
+	if len(task.Args) > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "arguments:")
+		for _, opt := range task.Args {
+			fmt.Fprintf(out, "  %-24s %s\n", opt.Name, opt.Help)
+		}
+	}
+}
+
+// taskPlugin provides the running task to `extern let task` declarations of executed files.
+//
+// The data type of the task is declared by the Cavefile, which is not part of the executed program.
+// Thus the task only provides its fields and does not match any type of the executed program.
+type taskPlugin struct {
+	dataType *runtime.DataType
+	values   []runtime.RuntimeValue
+}
+
+// Bind implements runtime.ExternPlugin.
+func (p taskPlugin) Bind(module *ast.SymbolTable, decl *ast.Symbol) runtime.RuntimeValue {
+	if _, ok := decl.Decl.(*ast.DeclExternValue); !ok || decl.Name != "task" {
+		return nil
+	}
+	task := runtime.MakeDataValue(p.dataType, p.values)
+	// type ids refer to constants of the executed program,
+	// the constant of the task itself is not a type
+	task.TypeId = runtime.TypeId(*decl.ConstantId)
+	return task
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/vknabel/blush/cavefile"
+	"github.com/vknabel/blush/pkgmanager"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/stdlibreg"
+	"github.com/vknabel/blush/world"
+)
+
+const (
+	// envPackages overrides the directory of installed packages.
+	envPackages = "BLUSH_PACKAGES"
+	vendorDir   = "vendor"
+)
+
+// vendorCommand installs the dependencies of the Cavefile within the current directory
+// and copies them into its vendor directory.
+// Previously vendored packages are preferred, which allows vendoring again without network access.
+func vendorCommand(w world.World, args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(w.OS.Stderr(), "usage: blush vendor")
+		return exitUsage
+	}
+	if err := vendor(w); err != nil {
+		fmt.Fprintf(w.OS.Stderr(), "blush vendor: %s\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+func vendor(w world.World) error {
+	path, err := filepath.Abs(registry.CavefileName)
+	if err != nil {
+		return err
+	}
+	project := filepath.Dir(path)
+	cave, err := projectCavefile(w, project)
+	if err != nil {
+		return err
+	}
+	if cave == nil {
+		return fmt.Errorf("no %s in %s", registry.CavefileName, project)
+	}
+	pm, err := packageManager(w, project)
+	if err != nil {
+		return err
+	}
+
+	task := pm.Install(*cave, pkgmanager.WithLockfile(w.FS, filepath.Join(project, pkgmanager.LockfileName)))
+	if err := task.Run(context.Background()); err != nil {
+		return err
+	}
+	return task.Vendor(w.FS, filepath.Join(project, vendorDir))
+}
+
+// dependencies resolves the dependencies declared by the Cavefile of the project by their import names.
+// Only vendored and previously installed packages are used, remotes are never contacted.
+// Returns nil if the project has no Cavefile.
+func dependencies(w world.World, project string) (map[string]registry.ResolvedPackage, error) {
+	cave, err := projectCavefile(w, project)
+	if err != nil || cave == nil {
+		return nil, err
+	}
+	pm, err := packageManager(w, project, pkgmanager.WithOffline())
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []pkgmanager.InstallOption
+	// existing pins are respected, but running does not create a lockfile
+	lockfile := filepath.Join(project, pkgmanager.LockfileName)
+	if _, err := w.FS.Stat(lockfile); err == nil {
+		opts = append(opts, pkgmanager.WithLockfile(w.FS, lockfile))
+	}
+	task := pm.Install(*cave, opts...)
+	if err := task.Run(context.Background()); err != nil {
+		return nil, fmt.Errorf("%w, run blush vendor to install the dependencies", err)
+	}
+	return task.Imports()
+}
+
+// projectCavefile loads the Cavefile within the project directory.
+// Returns nil if there is none.
+func projectCavefile(w world.World, project string) (*cavefile.Cavefile, error) {
+	path := filepath.Join(project, registry.CavefileName)
+	if _, err := w.FS.Stat(path); err != nil {
+		return nil, nil
+	}
+	mod, err := loadModule(w.FS, path)
+	if err != nil {
+		return nil, err
+	}
+	cave, errs, err := cavefile.Load(mod.Srcs[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, &diagnosticsError{mod, errs}
+	}
+	return &cave, nil
+}
+
+// packageManager installs the dependencies of the project.
+// Packages vendored into the project are preferred over installed ones.
+func packageManager(w world.World, project string, opts ...pkgmanager.Option) (*pkgmanager.PackageManager, error) {
+	packages, err := packagesDir(w)
+	if err != nil {
+		return nil, err
+	}
+	pkgfs, err := w.FS.Chroot(packages)
+	if err != nil {
+		return nil, err
+	}
+	stdlib, err := stdlibreg.FromWorld(w)
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]pkgmanager.Option{
+		pkgmanager.WithProject(w.FS, project),
+		pkgmanager.WithStdlib(stdlib),
+		pkgmanager.WithVendored(w.FS, filepath.Join(project, vendorDir)),
+	}, opts...)
+	return pkgmanager.New(pkgfs, opts...)
+}
+
+// packagesDir returns the directory of installed packages.
+// Defaults to the blush directory within the user's cache.
+func packagesDir(w world.World) (string, error) {
+	if dir := w.OS.Getenv(envPackages); dir != "" {
+		return filepath.Abs(dir)
+	}
+	if cache := w.OS.Getenv("XDG_CACHE_HOME"); cache != "" {
+		return filepath.Join(cache, "blush"), nil
+	}
+	if home := w.OS.Getenv("HOME"); home != "" {
+		return filepath.Join(home, ".cache", "blush"), nil
+	}
+	return "", fmt.Errorf("cannot locate the package cache, set $%s", envPackages)
+}
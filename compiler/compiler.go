@@ -3,6 +3,8 @@ package compiler
 import (
 	"fmt"
 	"math"
+	"sort"
+	"strings"
 
 	"github.com/vknabel/blush/ast"
 	"github.com/vknabel/blush/op"
@@ -19,50 +21,70 @@ const (
 func (c *Compiler) Compile(node ast.Node) error {
 	switch node := node.(type) {
 	case *ast.ContextModule:
-		c.enterScope(node.Symbols)
+		if c.resultFile == nil && len(node.Files) > 0 {
+			c.resultFile = node.Files[len(node.Files)-1]
+		}
+		if node.Prelude != nil && !c.linked[node.Prelude] {
+			// the prelude is compiled once and shared by all modules
+			c.linked[node.Prelude] = true
+			err := c.Compile(node.Prelude)
+			if err != nil {
+				return fmt.Errorf("prelude: %w", err)
+			}
+		}
 
-		for _, src := range node.Files {
-			err := c.Compile(src)
+		c.linked[node] = true
+		// imported modules are initialized before the importing module
+		for _, imported := range importedModules(node) {
+			if c.linked[imported] {
+				continue
+			}
+			err := c.Compile(imported)
 			if err != nil {
-				return err
+				return fmt.Errorf("%s: %w", imported.Name, err)
 			}
 		}
 
-		c.leaveScope()
+		// all files of a module run within the current scope
+		restore := c.useSymbols(node.Symbols)
+		defer restore()
 
-		return nil
-	case *ast.SourceFile:
-		c.enterScope(node.Symbols)
+		err := c.compileDeclaredSymbols(node.Symbols)
+		if err != nil {
+			return err
+		}
 
-		for _, sym := range node.Symbols.Symbols {
-			err := c.reserveSymbol(sym)
+		for _, src := range node.Files {
+			err := c.Compile(src)
 			if err != nil {
 				return err
 			}
 		}
 
-		for _, sym := range node.Symbols.Symbols {
-			err := c.compileSymbol(sym)
-			if err != nil {
-				return err
-			}
+		return nil
+	case *ast.SourceFile:
+		if c.resultFile == nil {
+			c.resultFile = node
 		}
+		restore := c.useSymbols(node.Symbols)
+		defer restore()
 
-		for _, stmt := range node.Statements {
+		err := c.compileDeclaredSymbols(node.Symbols)
+		if err != nil {
+			return err
+		}
+
+		trailing := node == c.resultFile && node.TrailingStatement() != nil
+		for i, stmt := range node.Statements {
+			if trailing && i == len(node.Statements)-1 {
+				return c.compileResult(stmt)
+			}
 			err := c.Compile(stmt)
 			if err != nil {
 				return err
 			}
 		}
 
-		scope := c.leaveScope()
-
-		// at its core this is fine, but shouldn't this be at the module level?
-		c.scopes[c.scopeIdx].Instructions = append(
-			c.scopes[c.scopeIdx].Instructions,
-			scope.Instructions...,
-		)
-
 		return nil
 
 	case *ast.DeclVariable, *ast.DeclFunc:
@@ -78,9 +100,39 @@ func (c *Compiler) Compile(node ast.Node) error {
 		return nil
 	case ast.StmtIf:
 		return c.compileStmtIf(node)
+	case *ast.StmtFor:
+		return c.compileStmtFor(node)
+	case *ast.StmtSwitch:
+		return c.compileSwitch(node.Subject, node.Cases, c.compileBlock)
+	case *ast.StmtAssign:
+		return c.compileStmtAssign(node)
+	case *ast.StmtBreak:
+		loop := c.currentLoop()
+		if loop == nil {
+			return fmt.Errorf("break outside of loop")
+		}
+		pos := c.emit(op.Jump, placeholderJumpAddress)
+		loop.breaks = append(loop.breaks, pos)
+		return nil
+	case *ast.StmtContinue:
+		loop := c.currentLoop()
+		if loop == nil {
+			return fmt.Errorf("continue outside of loop")
+		}
+		pos := c.emit(op.Jump, placeholderJumpAddress)
+		loop.continues = append(loop.continues, pos)
+		return nil
 
 	case ast.ExprIf:
 		return c.compileExprIf(node)
+	case *ast.ExprFunc:
+		return c.compileExprFunc(node)
+	case *ast.ExprFor:
+		return c.compileExprFor(node)
+	case *ast.ExprSwitch:
+		return c.compileSwitch(node.Subject, node.Cases, c.compileValueBlock)
+	case *ast.ExprTypeSwitch:
+		return c.compileExprTypeSwitch(node)
 	case *ast.ExprOperatorUnary:
 		return c.compileExprOperatorUnary(node)
 	case *ast.ExprOperatorBinary:
@@ -147,42 +199,20 @@ func (c *Compiler) Compile(node ast.Node) error {
 		return nil
 	case *ast.ExprIdentifier:
 		symbol := c.scopes[c.scopeIdx].symbols.LookupIdentifier(node.Name)
-		if symbol == nil {
-			return fmt.Errorf("undefined identifier %q", node.Name)
-		}
-		switch symbol.Decl.(type) {
-		case *ast.DeclFunc, *ast.DeclData, *ast.DeclEnum, *ast.DeclExternFunc, *ast.DeclAnnotation:
-			sym := symbol.Original()
-			if sym.ConstantId == nil {
-				return fmt.Errorf("identifier %q has no constant id", node.Name)
-			}
-			c.emit(op.Const, *sym.ConstantId)
-			return nil
-
-		case *ast.DeclVariable:
-			sym := symbol.Original()
-
-			if sym.LocalId != nil {
-				c.emit(op.GetLocal, *sym.LocalId)
-				return nil
-			}
-			if sym.GlobalId != nil {
-				c.emit(op.GetGlobal, *sym.GlobalId)
-				return nil
-			}
-
-			return fmt.Errorf("variable %q has no local or global id", node.Name)
-
-		case *ast.DeclParameter:
-			c.emit(op.GetLocal, *symbol.LocalId)
-			return nil
-
-		default:
-			return fmt.Errorf("identifier %q has unknown declaration type %T", node.Name, symbol.Decl)
+		if symbol == nil || symbol.Decl == nil {
+			return errUndefined(node.Name)
 		}
+		return c.compileSymbolReference(symbol)
 
 	case *ast.ExprMemberAccess:
-		err := c.Compile(node.Target)
+		member, err := c.importedMember(node)
+		if err != nil {
+			return err
+		}
+		if member != nil {
+			return c.compileSymbolReference(member)
+		}
+		err = c.Compile(node.Target)
 		if err != nil {
 			return err
 		}
@@ -274,12 +304,17 @@ func (c *Compiler) reserveSymbol(sym *ast.Symbol) error {
 		sym.LocalId = &id
 		return nil
 
-	case *ast.DeclData, *ast.DeclEnum, *ast.DeclExternFunc, *ast.DeclAnnotation:
+	case *ast.DeclData, *ast.DeclEnum, *ast.DeclAnnotation,
+		*ast.DeclExternFunc, *ast.DeclExternType, *ast.DeclExternValue:
 		id := len(c.constants)
 		c.constants = append(c.constants, nil)
 		sym.ConstantId = &id
 		return nil
 
+	case *ast.DeclImport, *ast.DeclImportMember, *ast.DeclModule:
+		// imported modules are compiled on their own
+		return nil
+
 	default:
 		return fmt.Errorf("unknown declaration %T", decl)
 	}
@@ -297,6 +332,67 @@ func (c *Compiler) replaceInstruction(pos int, patched []byte) {
 	}
 }
 
+// compileDeclaredSymbols reserves and compiles all symbols declared within the table.
+func (c *Compiler) compileDeclaredSymbols(table *ast.SymbolTable) error {
+	symbols := declaredSymbols(table)
+	for _, sym := range symbols {
+		err := c.reserveSymbol(sym)
+		if err != nil {
+			return err
+		}
+	}
+
+	// enums are compiled first, so type expressions can resolve their cases
+	sort.SliceStable(symbols, func(i, j int) bool {
+		_, isEnum := symbols[i].Decl.(*ast.DeclEnum)
+		_, isOtherEnum := symbols[j].Decl.(*ast.DeclEnum)
+		return isEnum && !isOtherEnum
+	})
+	for _, sym := range symbols {
+		if sym.Decl.ExportScope() == ast.ExportScopeLocal {
+			// locals within top level blocks are compiled in place
+			continue
+		}
+		err := c.compileSymbol(sym)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileResult compiles the last statement of the program.
+// The pop of a trailing expression statement is recorded as the result of the program.
+// Trailing if and switch statements record the trailing expression statements of each of their branches.
+func (c *Compiler) compileResult(stmt ast.Statement) error {
+	switch stmt := stmt.(type) {
+	case ast.StmtIf:
+		return c.compileStmtIfBlocks(stmt, c.compileResultBlock)
+	case *ast.StmtSwitch:
+		return c.compileSwitch(stmt.Subject, stmt.Cases, c.compileResultBlock)
+	}
+
+	err := c.Compile(stmt)
+	if err != nil {
+		return err
+	}
+	if _, ok := stmt.(*ast.StmtExpr); ok {
+		c.results = append(c.results, c.scopes[c.scopeIdx].lastInstruction.Position)
+	}
+	return nil
+}
+
+func (c *Compiler) compileResultBlock(block ast.Block) error {
+	if len(block) == 0 {
+		return nil
+	}
+	err := c.compileBlock(block[:len(block)-1])
+	if err != nil {
+		return err
+	}
+	return c.compileResult(block[len(block)-1])
+}
+
 func (c *Compiler) compileBlock(block ast.Block) error {
 	for _, stmt := range block {
 		err := c.Compile(stmt)
@@ -308,6 +404,11 @@ func (c *Compiler) compileBlock(block ast.Block) error {
 }
 
 func (c *Compiler) compileStmtIf(node ast.StmtIf) error {
+	return c.compileStmtIfBlocks(node, c.compileBlock)
+}
+
+// compileStmtIfBlocks compiles all branches of the if statement using the given block compiler.
+func (c *Compiler) compileStmtIfBlocks(node ast.StmtIf, compileBlock func(ast.Block) error) error {
 	var (
 		jumpNext int
 		jumpEnds = make([]int, 0, 1+len(node.ElseIf))
@@ -319,7 +420,7 @@ func (c *Compiler) compileStmtIf(node ast.StmtIf) error {
 	}
 	jumpNext = c.emit(op.JumpFalse, placeholderJumpAddress)
 
-	err = c.compileBlock(node.IfBlock)
+	err = compileBlock(node.IfBlock)
 	if err != nil {
 		return err
 	}
@@ -335,7 +436,7 @@ func (c *Compiler) compileStmtIf(node ast.StmtIf) error {
 		}
 		jumpNext = c.emit(op.JumpFalse, placeholderJumpAddress)
 
-		err = c.compileBlock(elseIf.Block)
+		err = compileBlock(elseIf.Block)
 		if err != nil {
 			return err
 		}
@@ -345,14 +446,15 @@ func (c *Compiler) compileStmtIf(node ast.StmtIf) error {
 	if node.ElseBlock != nil {
 		c.changeOperand(jumpNext, len(c.currentInstructions()))
 
-		err = c.compileBlock(node.ElseBlock)
+		err = compileBlock(node.ElseBlock)
 		if err != nil {
 			return err
 		}
 	} else {
 		lastIndex := len(jumpEnds) - 1
 
-		if c.isLastInstruction(op.Pop) {
+		// without else, there is nothing to jump over
+		if c.isLastInstruction(op.Jump) {
 			c.removeLastInstruction()
 		}
 
@@ -366,6 +468,367 @@ func (c *Compiler) compileStmtIf(node ast.StmtIf) error {
 	return nil
 }
 
+func (c *Compiler) compileStmtFor(node *ast.StmtFor) error {
+	return c.compileLoop(node.Condition, node.Element, node.Collection, node.Symbols, func() error {
+		return c.compileBlock(node.Block)
+	})
+}
+
+// compileExprFor collects the values of all iterations into an array, which is kept in a hidden local.
+func (c *Compiler) compileExprFor(node *ast.ExprFor) error {
+	acc := c.reserveHiddenLocal()
+	c.emit(op.Const, c.addConstant(runtime.Int(0)))
+	c.emit(op.Array)
+	c.emit(op.SetLocal, acc)
+
+	err := c.compileLoop(node.Condition, node.Element, node.Collection, node.Symbols, func() error {
+		return c.compileYieldingBlock(node.Block, acc)
+	})
+	if err != nil {
+		return err
+	}
+	c.emit(op.GetLocal, acc)
+	return nil
+}
+
+// compileYieldingBlock appends the value of the trailing expression to the array in the given local.
+// Trailing if and switch statements yield from each of their branches,
+// trailing for statements yield from each of their iterations. Other statements do not yield.
+func (c *Compiler) compileYieldingBlock(block ast.Block, acc int) error {
+	if len(block) == 0 {
+		return nil
+	}
+	err := c.compileBlock(block[:len(block)-1])
+	if err != nil {
+		return err
+	}
+
+	switch last := block[len(block)-1].(type) {
+	case *ast.StmtExpr:
+		return c.compileYield(last.Expr, acc)
+	case ast.StmtIf:
+		return c.compileStmtIfBlocks(last, func(b ast.Block) error {
+			return c.compileYieldingBlock(b, acc)
+		})
+	case *ast.StmtSwitch:
+		return c.compileSwitch(last.Subject, last.Cases, func(b ast.Block) error {
+			return c.compileYieldingBlock(b, acc)
+		})
+	case *ast.StmtFor:
+		return c.compileLoop(last.Condition, last.Element, last.Collection, last.Symbols, func() error {
+			return c.compileYieldingBlock(last.Block, acc)
+		})
+	default:
+		return c.Compile(last)
+	}
+}
+
+func (c *Compiler) compileYield(expr ast.Expr, acc int) error {
+	c.emit(op.GetLocal, acc)
+	err := c.Compile(expr)
+	if err != nil {
+		return err
+	}
+	c.emit(op.Append)
+	c.emit(op.SetLocal, acc)
+	return nil
+}
+
+// compileLoop compiles all loop forms. The body is compiled by the given callback.
+// The element and the locals of the body are declared by the given symbols.
+func (c *Compiler) compileLoop(condition ast.Expr, element *ast.DeclParameter, collection ast.Expr, symbols *ast.SymbolTable, compileBody func() error) error {
+	compileBlock := compileBody
+	if symbols != nil {
+		compileBlock = func() error {
+			restore := c.useSymbols(symbols)
+			defer restore()
+			return compileBody()
+		}
+		for _, sym := range declaredSymbols(symbols) {
+			err := c.reserveSymbol(sym)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if collection != nil {
+		return c.compileCollectionLoop(element, collection, symbols, compileBlock)
+	}
+
+	startPos := len(c.currentInstructions())
+	jumpEnd := -1
+
+	if condition != nil {
+		err := c.Compile(condition)
+		if err != nil {
+			return err
+		}
+		jumpEnd = c.emit(op.JumpFalse, placeholderJumpAddress)
+	}
+
+	c.enterLoop()
+	err := compileBlock()
+	if err != nil {
+		return err
+	}
+	c.emit(op.Jump, startPos)
+
+	endPos := len(c.currentInstructions())
+	if jumpEnd >= 0 {
+		c.changeOperand(jumpEnd, endPos)
+	}
+	c.leaveLoop(startPos, endPos)
+	return nil
+}
+
+func (c *Compiler) compileCollectionLoop(element *ast.DeclParameter, collection ast.Expr, symbols *ast.SymbolTable, compileBody func() error) error {
+	if symbols == nil {
+		symbols = c.scopes[c.scopeIdx].symbols
+	}
+	sym := symbols.LookupIdentifier(element.Name)
+	if sym.LocalId == nil {
+		return fmt.Errorf("loop element %q has no local id", element.Name)
+	}
+
+	err := c.Compile(collection)
+	if err != nil {
+		return err
+	}
+	c.emit(op.Iterate)
+
+	// the iterator stays on the stack until the loop ends
+	nextPos := c.emit(op.IterNext, placeholderJumpAddress)
+	c.emit(op.SetLocal, *sym.LocalId)
+
+	c.enterLoop()
+	err = compileBody()
+	if err != nil {
+		return err
+	}
+	c.emit(op.Jump, nextPos)
+
+	// breaks also need to end the iteration
+	endPos := c.emit(op.IterEnd)
+	c.changeOperand(nextPos, endPos)
+	c.leaveLoop(nextPos, endPos)
+	return nil
+}
+
+// compileSwitch runs the block of the first case matching the subject using the given block compiler.
+// The subject is evaluated once and kept in a hidden local.
+func (c *Compiler) compileSwitch(subject ast.Expr, cases []*ast.SwitchCase, compileBlock func(ast.Block) error) error {
+	subj := c.reserveHiddenLocal()
+	err := c.Compile(subject)
+	if err != nil {
+		return err
+	}
+	c.emit(op.SetLocal, subj)
+
+	jumpEnds := make([]int, 0, len(cases))
+	for _, switchCase := range cases {
+		jumpNext := -1
+		if !switchCase.IsWildcard() {
+			err = c.compileSwitchCondition(switchCase, subj)
+			if err != nil {
+				return err
+			}
+			jumpNext = c.emit(op.JumpFalse, placeholderJumpAddress)
+		}
+
+		err = compileBlock(switchCase.Block)
+		if err != nil {
+			return err
+		}
+		jumpEnds = append(jumpEnds, c.emit(op.Jump, placeholderJumpAddress))
+
+		if jumpNext >= 0 {
+			c.changeOperand(jumpNext, len(c.currentInstructions()))
+		}
+	}
+
+	endPos := len(c.currentInstructions())
+	for _, pos := range jumpEnds {
+		c.changeOperand(pos, endPos)
+	}
+	return nil
+}
+
+// compileSwitchCondition pushes whether the subject in the given local matches the case.
+//
+//	case 1:               // equality
+//	case @String:         // shorthand for @Type(String)
+//	case @Type(String):   // the subject is of the type
+//	case @Has(Countable): // the subject's type has the annotation
+func (c *Compiler) compileSwitchCondition(switchCase *ast.SwitchCase, subj int) error {
+	c.emit(op.GetLocal, subj)
+	if switchCase.Value != nil {
+		err := c.Compile(switchCase.Value)
+		if err != nil {
+			return err
+		}
+		c.emit(op.Equal)
+		return nil
+	}
+
+	anno := switchCase.Annotation
+	if len(anno.Arguments) == 0 {
+		err := c.Compile(staticReferenceExpr(anno.Reference))
+		if err != nil {
+			return err
+		}
+		c.emit(op.IsType)
+		return nil
+	}
+
+	name := anno.Reference.Name().Value
+	if len(anno.Arguments) != 1 || (name != "Type" && name != "Has") {
+		return fmt.Errorf("case @%s requires a type, @Type(type) or @Has(annotation)", anno.Reference)
+	}
+	err := c.Compile(anno.Arguments[0])
+	if err != nil {
+		return err
+	}
+	if name == "Has" {
+		c.emit(op.HasAnnotation)
+	} else {
+		c.emit(op.IsType)
+	}
+	return nil
+}
+
+// compileValueBlock pushes the value of the trailing expression of the block.
+// Trailing switch statements push the value of their matching case.
+// Blocks ending with a return do not produce a value.
+func (c *Compiler) compileValueBlock(block ast.Block) error {
+	if len(block) == 0 {
+		c.emit(op.ConstNull)
+		return nil
+	}
+	err := c.compileBlock(block[:len(block)-1])
+	if err != nil {
+		return err
+	}
+
+	switch last := block[len(block)-1].(type) {
+	case *ast.StmtExpr:
+		return c.Compile(last.Expr)
+	case *ast.StmtSwitch:
+		return c.compileSwitch(last.Subject, last.Cases, c.compileValueBlock)
+	default:
+		return c.Compile(last)
+	}
+}
+
+// compileExprTypeSwitch calls the function of the case, whose type includes the subject, with the subject.
+// All cases of the enum need to be handled, unless the last case is Any.
+// Subjects not matching any case fail at runtime.
+func (c *Compiler) compileExprTypeSwitch(node *ast.ExprTypeSwitch) error {
+	enumSym, enum, err := c.resolveEnum(node.Type)
+	if err != nil {
+		return err
+	}
+	decl := enumSym.Decl.(*ast.DeclEnum)
+	caseTypes := make(map[string]int, len(decl.Cases))
+	for i, enumCase := range decl.Cases {
+		caseTypes[enumCase.DeclName().Value] = enum.Cases[i]
+	}
+
+	catchAll := false
+	for i, key := range node.CaseOrder {
+		if _, ok := caseTypes[key.Value]; ok {
+			continue
+		}
+		if key.Value != "Any" {
+			return fmt.Errorf("%s is not a case of enum %s", key.Value, decl.Name)
+		}
+		if i != len(node.CaseOrder)-1 {
+			return fmt.Errorf("case Any of enum %s must be the last case", decl.Name)
+		}
+		catchAll = true
+	}
+	if !catchAll {
+		var missing []string
+		for _, enumCase := range decl.Cases {
+			if _, ok := node.Cases[enumCase.DeclName().Value]; !ok {
+				missing = append(missing, enumCase.DeclName().Value)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("type expression of enum %s misses cases %s", decl.Name, strings.Join(missing, ", "))
+		}
+	}
+
+	subj := c.reserveHiddenLocal()
+	err = c.Compile(node.Subject)
+	if err != nil {
+		return err
+	}
+	c.emit(op.SetLocal, subj)
+
+	jumpEnds := make([]int, 0, len(node.CaseOrder))
+	for _, key := range node.CaseOrder {
+		jumpNext := -1
+		if typeId, ok := caseTypes[key.Value]; ok {
+			c.emit(op.GetLocal, subj)
+			c.emit(op.Const, typeId)
+			c.emit(op.IsType)
+			jumpNext = c.emit(op.JumpFalse, placeholderJumpAddress)
+		}
+
+		c.emit(op.GetLocal, subj)
+		err = c.Compile(node.Cases[key.Value])
+		if err != nil {
+			return err
+		}
+		c.emit(op.Call, 1)
+		jumpEnds = append(jumpEnds, c.emit(op.Jump, placeholderJumpAddress))
+
+		if jumpNext >= 0 {
+			c.changeOperand(jumpNext, len(c.currentInstructions()))
+		}
+	}
+	if !catchAll {
+		c.emit(op.GetLocal, subj)
+		c.emit(op.Unmatched, *enumSym.ConstantId)
+	}
+
+	endPos := len(c.currentInstructions())
+	for _, pos := range jumpEnds {
+		c.changeOperand(pos, endPos)
+	}
+	return nil
+}
+
+// resolveEnum returns the symbol and the compiled enum the expression refers to.
+func (c *Compiler) resolveEnum(expr ast.Expr) (*ast.Symbol, *runtime.EnumType, error) {
+	ident, ok := expr.(*ast.ExprIdentifier)
+	if !ok {
+		return nil, nil, fmt.Errorf("type expression requires an enum, got %s", expr.Expression())
+	}
+	symbol := c.scopes[c.scopeIdx].symbols.LookupIdentifier(ident.Name)
+	if symbol == nil || symbol.Decl == nil {
+		return nil, nil, errUndefined(ident.Name)
+	}
+	sym := symbol.Original()
+	if _, ok := sym.Decl.(*ast.DeclEnum); !ok || sym.ConstantId == nil {
+		return nil, nil, fmt.Errorf("type expression requires an enum, got %s", expr.Expression())
+	}
+	enum, ok := c.constants[*sym.ConstantId].(*runtime.EnumType)
+	if !ok {
+		return nil, nil, fmt.Errorf("enum %s is not compiled yet", ident.Name)
+	}
+	return sym, enum, nil
+}
+
+// staticReferenceExpr converts the reference into the equivalent member accesses.
+func staticReferenceExpr(ref ast.StaticReference) ast.Expr {
+	var expr ast.Expr = ast.MakeExprIdentifier(ref[0])
+	for _, ident := range ref[1:] {
+		expr = ast.MakeExprMemberAccess(ident.Token, expr, ident)
+	}
+	return expr
+}
+
 func (c *Compiler) compileExprIf(node ast.ExprIf) error {
 	var (
 		jumpNext int
@@ -557,40 +1020,74 @@ func (c *Compiler) compileSymbol(sym *ast.Symbol) error {
 			return err
 		}
 
+		dt.Iterate, err = c.iterateFunction(sym.Name, decl.Annotations)
+		if err != nil {
+			return err
+		}
+
 		c.constants[*sym.ConstantId] = dt
 
 		return nil
 
-	case *ast.DeclFunc:
-		c.enterScope(decl.Impl.Symbols)
-
-		for _, child := range decl.Impl.Symbols.Symbols {
-			if child.Decl == nil {
-				continue
+	case *ast.DeclEnum:
+		et := runtime.MakeEnumType(sym)
+		for _, enumCase := range decl.Cases {
+			caseSym := c.scopes[c.scopeIdx].symbols.LookupRef(enumCase.Case)
+			if caseSym == nil || caseSym.Decl == nil || caseSym.Original().ConstantId == nil {
+				return fmt.Errorf("case %s of enum %s is not a type", enumCase.Case, decl.Name)
 			}
-			err := c.reserveSymbol(child)
+			et.Cases = append(et.Cases, *caseSym.Original().ConstantId)
+		}
+		c.constants[*sym.ConstantId] = et
+		return nil
+
+	case *ast.DeclAnnotation:
+		c.constants[*sym.ConstantId] = runtime.MakeAnnotationType(sym)
+		return nil
+
+	case *ast.DeclExternFunc, *ast.DeclExternType, *ast.DeclExternValue:
+		val, err := c.plugins.Bind(c.scopes[c.scopeIdx].symbols, sym)
+		if err != nil {
+			return err
+		}
+		if typ, ok := val.(runtime.SimpleType); ok {
+			typ.Iterate, err = c.iterateFunction(sym.Name, typ.Annotations())
 			if err != nil {
 				return err
 			}
+			val = typ
 		}
-		err := c.compileBlock(decl.Impl.Impl)
+		c.constants[*sym.ConstantId] = val
+		return nil
+
+	case *ast.DeclImport, *ast.DeclImportMember, *ast.DeclModule:
+		return nil
+
+	case *ast.DeclFunc:
+		fn, err := c.compileFunction(decl.Impl, sym, false)
 		if err != nil {
 			return err
 		}
-		scope := c.leaveScope()
-
-		c.constants[*sym.ConstantId] = runtime.MakeCompiledFunction(
-			scope.Instructions,
-			len(decl.Impl.Parameters),
-			sym,
-		)
-
+		c.constants[*sym.ConstantId] = fn
 		return nil
 
 	case *ast.DeclVariable:
 		switch decl.ExportScope() {
 		case ast.ExportScopeInternal, ast.ExportScopePublic:
-			c.enterScope(sym.ChildTable)
+			// initializers run in their own frame and declare their own locals
+			syms := sym.ChildTable
+			if syms == nil {
+				syms = c.scopes[c.scopeIdx].symbols
+			}
+			c.enterScope(syms)
+			if sym.ChildTable != nil {
+				for _, child := range declaredSymbols(sym.ChildTable) {
+					err := c.reserveSymbol(child)
+					if err != nil {
+						return err
+					}
+				}
+			}
 
 			err := c.Compile(decl.Value)
 			if err != nil {
@@ -623,3 +1120,408 @@ func (c *Compiler) compileSymbol(sym *ast.Symbol) error {
 		return fmt.Errorf("unknown declaration %T", decl)
 	}
 }
+
+// iterateFunction returns the constant id of the iterate function provided by @Iterable, if any.
+func (c *Compiler) iterateFunction(name string, annos ast.AnnotationChain) (*int, error) {
+	for _, anno := range annos {
+		if anno.Reference.Name().Value != "Iterable" {
+			continue
+		}
+		if len(anno.Arguments) != 1 {
+			return nil, fmt.Errorf("@Iterable of %q requires exactly one argument", name)
+		}
+		id, err := c.annotationArgument(anno.Arguments[0])
+		if err != nil {
+			return nil, err
+		}
+		return &id, nil
+	}
+	return nil, nil
+}
+
+// compileFunction compiles the implementation of a function into a constant value.
+// Function literals implicitly return their trailing expression.
+// Trailing if and switch statements return from each of their branches.
+func (c *Compiler) compileFunction(impl *ast.ExprFunc, sym *ast.Symbol, implicitReturn bool) (*runtime.CompiledFunction, error) {
+	c.enterScope(impl.Symbols)
+
+	for _, child := range declaredSymbols(impl.Symbols) {
+		err := c.reserveSymbol(child)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	compileBlock := c.compileBlock
+	if implicitReturn {
+		compileBlock = c.compileReturningBlock
+	}
+	err := compileBlock(impl.Impl)
+	if err != nil {
+		return nil, err
+	}
+
+	// falling off the end of a function implicitly returns null
+	if !c.isLastInstruction(op.Return) || c.isJumpTarget(len(c.currentInstructions())) {
+		c.emit(op.ConstNull)
+		c.emit(op.Return)
+	}
+	scope := c.leaveScope()
+
+	return runtime.MakeCompiledFunction(
+		scope.Instructions,
+		len(impl.Parameters),
+		scope.NumLocals(),
+		sym,
+	), nil
+}
+
+// compileReturningBlock returns the value of the trailing expression of the block.
+// Trailing if and switch statements return from each of their branches, other statements do not return.
+func (c *Compiler) compileReturningBlock(block ast.Block) error {
+	if len(block) == 0 {
+		return nil
+	}
+	err := c.compileBlock(block[:len(block)-1])
+	if err != nil {
+		return err
+	}
+
+	switch last := block[len(block)-1].(type) {
+	case *ast.StmtExpr:
+		err := c.Compile(last.Expr)
+		if err != nil {
+			return err
+		}
+		c.emit(op.Return)
+		return nil
+	case ast.StmtIf:
+		return c.compileStmtIfBlocks(last, c.compileReturningBlock)
+	case *ast.StmtSwitch:
+		return c.compileSwitch(last.Subject, last.Cases, c.compileReturningBlock)
+	default:
+		return c.Compile(last)
+	}
+}
+
+// compileExprFunc compiles a function literal into a constant and pushes it as a value.
+func (c *Compiler) compileExprFunc(node *ast.ExprFunc) error {
+	id, err := c.compileExprFuncConstant(node)
+	if err != nil {
+		return err
+	}
+	return c.compileClosure(id, node.Symbols, node)
+}
+
+func (c *Compiler) compileExprFuncConstant(node *ast.ExprFunc) (int, error) {
+	sym := &ast.Symbol{
+		Name:       node.Name,
+		Scope:      ast.FunctionScope,
+		ChildTable: node.Symbols,
+	}
+	id := len(c.constants)
+	c.constants = append(c.constants, nil)
+	sym.ConstantId = &id
+
+	fn, err := c.compileFunction(node, sym, true)
+	if err != nil {
+		return 0, err
+	}
+	c.constants[id] = fn
+	return id, nil
+}
+
+// compileClosure pushes the function constant.
+// If its implementation captures locals of enclosing functions, a closure will be created.
+func (c *Compiler) compileClosure(constantId int, table *ast.SymbolTable, fromNode ast.Node) error {
+	captured := capturedSymbols(table)
+	if len(captured) == 0 {
+		c.emit(op.Const, constantId)
+		return nil
+	}
+
+	for _, free := range captured {
+		// captured symbols might need to be captured by the current scope, too
+		local := c.scopes[c.scopeIdx].symbols.Lookup(free.Name, fromNode)
+		err := c.compileCapture(local)
+		if err != nil {
+			return err
+		}
+	}
+	c.emit(op.Closure, constantId, len(captured))
+	return nil
+}
+
+// compileCapture pushes the cell of a captured variable.
+// The cell is shared with the declaring function and all other closures capturing it.
+func (c *Compiler) compileCapture(symbol *ast.Symbol) error {
+	if symbol.Scope == ast.FreeScope && isCaptured(symbol) {
+		c.emit(op.CaptureFree, capturedIndex(c.scopes[c.scopeIdx].symbols, symbol))
+		return nil
+	}
+	sym := symbol.Original()
+	if sym.LocalId == nil {
+		return fmt.Errorf("captured variable %q has no local id", symbol.Name)
+	}
+	c.emit(op.CaptureLocal, *sym.LocalId)
+	return nil
+}
+
+// compileStmtAssign stores the value in the target variable, field or element or discards it.
+// Targets are evaluated before the value.
+func (c *Compiler) compileStmtAssign(node *ast.StmtAssign) error {
+	switch target := node.Target.(type) {
+	case nil:
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+		c.emit(op.Pop)
+		return nil
+
+	case *ast.ExprIdentifier:
+		symbol := c.scopes[c.scopeIdx].symbols.LookupIdentifier(target.Name)
+		if symbol == nil || symbol.Decl == nil {
+			return fmt.Errorf("cannot assign to undeclared variable %q", target.Name)
+		}
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+		return c.compileSymbolAssignment(symbol)
+
+	case *ast.ExprMemberAccess:
+		err := c.Compile(target.Target)
+		if err != nil {
+			return err
+		}
+		err = c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+		c.emit(op.SetField, c.addConstant(c.plugins.Prelude().String(target.Property.Value)))
+		return nil
+
+	case *ast.ExprIndexAccess:
+		err := c.Compile(target.Target)
+		if err != nil {
+			return err
+		}
+		err = c.Compile(target.IndexExpr)
+		if err != nil {
+			return err
+		}
+		err = c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+		c.emit(op.SetIndex)
+		return nil
+
+	default:
+		return fmt.Errorf("cannot assign to %s", node.Target.Expression())
+	}
+}
+
+// compileSymbolAssignment pops the value on top of the stack into the variable of the symbol.
+// Captured variables are assigned through their cells, thus all closures observe the assignment.
+func (c *Compiler) compileSymbolAssignment(symbol *ast.Symbol) error {
+	switch symbol.Decl.(type) {
+	case *ast.DeclVariable, *ast.DeclParameter:
+	default:
+		return fmt.Errorf("cannot assign to %q, only variables can be assigned", symbol.Name)
+	}
+
+	if symbol.Scope == ast.FreeScope && isCaptured(symbol) {
+		c.emit(op.SetFree, capturedIndex(c.scopes[c.scopeIdx].symbols, symbol))
+		return nil
+	}
+	sym := symbol.Original()
+	if sym.LocalId != nil {
+		c.emit(op.AssignLocal, *sym.LocalId)
+		return nil
+	}
+	if sym.GlobalId != nil {
+		c.emit(op.SetGlobal, *sym.GlobalId)
+		return nil
+	}
+	return fmt.Errorf("variable %q has no local or global id", symbol.Name)
+}
+
+// compileSymbolReference pushes the value of the symbol onto the stack.
+func (c *Compiler) compileSymbolReference(symbol *ast.Symbol) error {
+	if symbol.Scope == ast.FreeScope && isCaptured(symbol) {
+		c.emit(op.GetFree, capturedIndex(c.scopes[c.scopeIdx].symbols, symbol))
+		return nil
+	}
+
+	switch symbol.Decl.(type) {
+	case *ast.DeclFunc:
+		sym := symbol.Original()
+		if sym.ConstantId == nil {
+			return fmt.Errorf("identifier %q has no constant id", symbol.Name)
+		}
+		return c.compileClosure(*sym.ConstantId, sym.ChildTable, symbol.Decl)
+
+	case *ast.DeclData, *ast.DeclEnum, *ast.DeclAnnotation,
+		*ast.DeclExternFunc, *ast.DeclExternType, *ast.DeclExternValue:
+		sym := symbol.Original()
+		if sym.ConstantId == nil {
+			return fmt.Errorf("identifier %q has no constant id", symbol.Name)
+		}
+		c.emit(op.Const, *sym.ConstantId)
+		return nil
+
+	case *ast.DeclVariable:
+		sym := symbol.Original()
+
+		if sym.LocalId != nil {
+			c.emit(op.GetLocal, *sym.LocalId)
+			return nil
+		}
+		if sym.GlobalId != nil {
+			c.emit(op.GetGlobal, *sym.GlobalId)
+			return nil
+		}
+
+		return fmt.Errorf("variable %q has no local or global id", symbol.Name)
+
+	case *ast.DeclParameter:
+		c.emit(op.GetLocal, *symbol.LocalId)
+		return nil
+
+	case *ast.DeclImportMember:
+		sym := symbol.Original()
+		if sym == symbol || sym.Decl == nil {
+			return fmt.Errorf("imported member %q is not linked", symbol.Name)
+		}
+		return c.compileSymbolReference(sym)
+
+	case *ast.DeclImport:
+		return fmt.Errorf("module %q cannot be used as a value", symbol.Name)
+
+	default:
+		return fmt.Errorf("identifier %q has unknown declaration type %T", symbol.Name, symbol.Decl)
+	}
+}
+
+// importedMember resolves the member of an imported module statically, like maths.sin.
+// Returns nil if the target does not refer to an imported module.
+func (c *Compiler) importedMember(node *ast.ExprMemberAccess) (*ast.Symbol, error) {
+	ident, ok := node.Target.(*ast.ExprIdentifier)
+	if !ok {
+		return nil, nil
+	}
+	symbol := c.scopes[c.scopeIdx].symbols.LookupIdentifier(ident.Name)
+	if symbol == nil || symbol.Decl == nil {
+		return nil, errUndefined(ident.Name)
+	}
+	decl, ok := symbol.Decl.(*ast.DeclImport)
+	if !ok {
+		return nil, nil
+	}
+	module := symbol.Original().ChildTable
+	if module == nil {
+		return nil, fmt.Errorf("module %s is not linked", decl.Module())
+	}
+	member, ok := module.Symbols[node.Property.Value]
+	if !ok || member.Decl == nil || member.Scope == ast.FreeScope {
+		return nil, fmt.Errorf("module %s has no member %q", decl.Module(), node.Property.Value)
+	}
+	return member, nil
+}
+
+// annotationArgument resolves an argument of an annotation instance to a constant id.
+// Annotations are instantiated at compile time and thus only accept constants.
+func (c *Compiler) annotationArgument(arg ast.Expr) (int, error) {
+	switch arg := arg.(type) {
+	case *ast.ExprIdentifier:
+		symbol := c.scopes[c.scopeIdx].symbols.LookupIdentifier(arg.Name)
+		if symbol == nil || symbol.Decl == nil {
+			return 0, errUndefined(arg.Name)
+		}
+		sym := symbol.Original()
+		if sym.ConstantId == nil {
+			return 0, fmt.Errorf("annotation argument %q is not a constant", arg.Name)
+		}
+		return *sym.ConstantId, nil
+
+	case *ast.ExprFunc:
+		id, err := c.compileExprFuncConstant(arg)
+		if err != nil {
+			return 0, err
+		}
+		if len(capturedSymbols(arg.Symbols)) > 0 {
+			return 0, fmt.Errorf("annotation argument %s must not capture locals", arg.Expression())
+		}
+		return id, nil
+
+	default:
+		return 0, fmt.Errorf("unsupported annotation argument %s", arg.Expression())
+	}
+}
+
+// isCaptured reports whether a free symbol refers to a local of an enclosing function.
+// Globals and constants are referenced directly instead.
+func isCaptured(free *ast.Symbol) bool {
+	return free.Original().LocalId != nil
+}
+
+// capturedSymbols returns the free symbols of the table, which need to be captured by closures.
+// The order matches the indices of op.GetFree.
+func capturedSymbols(table *ast.SymbolTable) []*ast.Symbol {
+	if table == nil {
+		return nil
+	}
+	captured := make([]*ast.Symbol, 0, len(table.FreeSymbols))
+	for _, free := range table.FreeSymbols {
+		if isCaptured(free) {
+			captured = append(captured, free)
+		}
+	}
+	return captured
+}
+
+// capturedIndex returns the index of the free symbol of the table within its closure.
+func capturedIndex(table *ast.SymbolTable, free *ast.Symbol) int {
+	idx := 0
+	for _, sym := range table.FreeSymbols[:free.Index] {
+		if isCaptured(sym) {
+			idx++
+		}
+	}
+	return idx
+}
+
+// importedModules returns the modules imported by any file of the module in import order.
+// Imports without a linked module are omitted.
+func importedModules(module *ast.ContextModule) []*ast.ContextModule {
+	var modules []*ast.ContextModule
+	for _, src := range module.Files {
+		for _, sym := range declaredSymbols(src.Symbols) {
+			if _, ok := sym.Decl.(*ast.DeclImport); !ok || sym.ChildTable == nil {
+				continue
+			}
+			if imported, ok := sym.ChildTable.OpenedBy.(*ast.ContextModule); ok {
+				modules = append(modules, imported)
+			}
+		}
+	}
+	return modules
+}
+
+// declaredSymbols returns the symbols declared within the given table in declaration order.
+// Placeholders of unresolved references and captured free symbols are omitted.
+func declaredSymbols(table *ast.SymbolTable) []*ast.Symbol {
+	symbols := make([]*ast.Symbol, 0, len(table.Symbols))
+	for _, sym := range table.Symbols {
+		if sym.Decl == nil || sym.Scope == ast.FreeScope {
+			continue
+		}
+		symbols = append(symbols, sym)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i].Index < symbols[j].Index
+	})
+	return symbols
+}
@@ -1,6 +1,7 @@
 package compiler_test
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -358,7 +359,7 @@ func TestBinaryOperators(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
-func TesEQtIfStmtsArithmetic(t *testing.T) {
+func TestIfStmtsArithmetic(t *testing.T) {
 	tests := []compilerTestCase{
 		{
 			input:             "if 1 { 2 } else { 3 }",
@@ -421,6 +422,128 @@ func TesEQtIfStmtsArithmetic(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestForStmts(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			label:             "infinite loop",
+			input:             "for { 1 }",
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.Const, 0),
+				code.Make(code.Pop),
+				code.Make(code.Jump, 0),
+			},
+		},
+		{
+			label:             "conditional loop",
+			input:             "for true { 1 }",
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.ConstTrue),
+				code.Make(code.JumpFalse, 11),
+				code.Make(code.Const, 0),
+				code.Make(code.Pop),
+				code.Make(code.Jump, 0),
+			},
+		},
+		{
+			label:             "break and continue",
+			input:             "for true { if false { break } continue }",
+			expectedConstants: []any{},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.ConstTrue),
+				code.Make(code.JumpFalse, 17),
+				code.Make(code.ConstFalse),
+				code.Make(code.JumpFalse, 11),
+				code.Make(code.Jump, 17),
+				code.Make(code.Jump, 0),
+				code.Make(code.Jump, 0),
+			},
+		},
+		{
+			label:             "collection loop",
+			input:             "for x <- [1] { x }",
+			expectedConstants: []any{1, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.Const, 0),
+				code.Make(code.Const, 1),
+				code.Make(code.Array),
+				code.Make(code.Iterate),
+				code.Make(code.IterNext, 21),
+				code.Make(code.SetLocal, 0),
+				code.Make(code.GetLocal, 0),
+				code.Make(code.Pop),
+				code.Make(code.Jump, 8),
+				code.Make(code.IterEnd),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestForExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			label:             "collection expression",
+			input:             "(for x <- [] { x })",
+			expectedConstants: []any{0, 0},
+			expectedInstructions: []code.Instructions{
+				// hidden accumulator
+				code.Make(code.Const, 0),
+				code.Make(code.Array),
+				code.Make(code.SetLocal, 0),
+				// collection
+				code.Make(code.Const, 1),
+				code.Make(code.Array),
+				code.Make(code.Iterate),
+				code.Make(code.IterNext, 31),
+				// element
+				code.Make(code.SetLocal, 1),
+				// yield
+				code.Make(code.GetLocal, 0),
+				code.Make(code.GetLocal, 1),
+				code.Make(code.Append),
+				code.Make(code.SetLocal, 0),
+				code.Make(code.Jump, 12),
+				code.Make(code.IterEnd),
+				code.Make(code.GetLocal, 0),
+				code.Make(code.Pop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestSwitchExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			label:             "value and wildcard case",
+			input:             "(switch 1 { case 2: 3 case _: 4 })",
+			expectedConstants: []any{1, 2, 3, 4},
+			expectedInstructions: []code.Instructions{
+				// hidden subject
+				code.Make(code.Const, 0),
+				code.Make(code.SetLocal, 0),
+				// case 2
+				code.Make(code.GetLocal, 0),
+				code.Make(code.Const, 1),
+				code.Make(code.Equal),
+				code.Make(code.JumpFalse, 22),
+				code.Make(code.Const, 2),
+				code.Make(code.Jump, 28),
+				// case _
+				code.Make(code.Const, 3),
+				code.Make(code.Jump, 28),
+				code.Make(code.Pop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestIfExpressionsArithmetic(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -701,6 +824,114 @@ func TestDeclFunction(t *testing.T) {
 			},
 			expectedInstructions: []code.Instructions{},
 		},
+		{
+			label: "closure capturing a parameter",
+			input: "func adder(x) {\n func add(a) { return a + x }\n return add\n}",
+			expectedConstants: []any{
+				compiledFunction{
+					name:   "adder",
+					params: 1,
+					ins: []code.Instructions{
+						code.Make(code.CaptureLocal, 0),
+						code.Make(code.Closure, 1, 1),
+						code.Make(code.Return),
+					},
+				},
+				compiledFunction{
+					name:   "add",
+					params: 1,
+					ins: []code.Instructions{
+						code.Make(code.GetLocal, 0),
+						code.Make(code.GetFree, 0),
+						code.Make(code.Add),
+						code.Make(code.Return),
+					},
+				},
+			},
+			expectedInstructions: []code.Instructions{},
+		},
+		{
+			label: "closure assigning a captured local",
+			input: "func counter() {\n let n = 0\n func increment() { n = n + 1 }\n n = 1\n return increment\n}",
+			expectedConstants: []any{
+				compiledFunction{
+					name:   "counter",
+					params: 0,
+					ins: []code.Instructions{
+						code.Make(code.Const, 2),
+						code.Make(code.SetLocal, 0),
+						code.Make(code.Const, 4),
+						code.Make(code.AssignLocal, 0),
+						code.Make(code.CaptureLocal, 0),
+						code.Make(code.Closure, 1, 1),
+						code.Make(code.Return),
+					},
+				},
+				compiledFunction{
+					name:   "increment",
+					params: 0,
+					ins: []code.Instructions{
+						code.Make(code.GetFree, 0),
+						code.Make(code.Const, 3),
+						code.Make(code.Add),
+						code.Make(code.SetFree, 0),
+						code.Make(code.ConstNull),
+						code.Make(code.Return),
+					},
+				},
+				0,
+				1,
+				1,
+			},
+			expectedInstructions: []code.Instructions{},
+		},
+		{
+			label: "function literal",
+			input: "{ a -> a }(42)",
+			expectedConstants: []any{
+				42,
+				compiledFunction{
+					name:   "func#1",
+					params: 1,
+					ins: []code.Instructions{
+						code.Make(code.GetLocal, 0),
+						code.Make(code.Return),
+					},
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.Const, 0),
+				code.Make(code.Const, 1),
+				code.Make(code.Call, 1),
+				code.Make(code.Pop),
+			},
+		},
+		{
+			label: "function literal capturing a parameter",
+			input: "func adder(x) {\n return { a -> a + x }\n}",
+			expectedConstants: []any{
+				compiledFunction{
+					name:   "adder",
+					params: 1,
+					ins: []code.Instructions{
+						code.Make(code.CaptureLocal, 0),
+						code.Make(code.Closure, 1, 1),
+						code.Make(code.Return),
+					},
+				},
+				compiledFunction{
+					name:   "func#1",
+					params: 1,
+					ins: []code.Instructions{
+						code.Make(code.GetLocal, 0),
+						code.Make(code.GetFree, 0),
+						code.Make(code.Add),
+						code.Make(code.Return),
+					},
+				},
+			},
+			expectedInstructions: []code.Instructions{},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -721,6 +952,22 @@ func TestVariables(t *testing.T) {
 				code.Make(code.Pop),
 			},
 		},
+		{
+			input: "let a = 42\na = 2\n_ = a",
+			expectedConstants: []any{
+				42,
+				2,
+			},
+			expectedGlobals: [][]code.Instructions{
+				{code.Make(code.Const, 0)},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.Const, 1),
+				code.Make(code.SetGlobal, 0),
+				code.Make(code.GetGlobal, 0),
+				code.Make(code.Pop),
+			},
+		},
 	}
 
 	runCompilerTests(t, tests)
@@ -748,6 +995,19 @@ func TestDeclData(t *testing.T) {
 				},
 			},
 		},
+		{
+			label: "data declaration with annotated field",
+			input: `data Example {
+				@Unknown
+				field
+			}`,
+			expectedConstants: []any{
+				compiledDataType{
+					name:   "Example",
+					fields: []compiledField{{name: "field"}},
+				},
+			},
+		},
 		{
 			label: "data declaration and call",
 			input: `
@@ -809,6 +1069,115 @@ func TestDeclData(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestUnboundExtern(t *testing.T) {
+	program := prepareSourceFileParsing(t, "extern func missing()")
+
+	err := compiler.New().Compile(program)
+	if err == nil || err.Error() != "extern missing is not provided by any plugin" {
+		t.Fatalf("expected unbound extern error, got %v", err)
+	}
+}
+
+func TestAssignmentErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"missing = 1", `cannot assign to undeclared variable "missing"`},
+		{"func f() {}\nf = 1", `cannot assign to "f", only variables can be assigned`},
+		{"data Point {}\nPoint = 1", `cannot assign to "Point", only variables can be assigned`},
+		{"let x = 1\nx + 1 = 2", "cannot assign to (x+1)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err, func(t *testing.T) {
+			program := prepareSourceFileParsing(t, tt.input)
+
+			err := compiler.New().Compile(program)
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestUndefinedIdentifier(t *testing.T) {
+	tests := []struct {
+		input string
+		ident string
+	}{
+		{"func f() {\n return 1 + missing\n}", "missing"},
+		{"func f() {\n return missing.member\n}", "missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			program := prepareSourceFileParsing(t, tt.input)
+
+			err := compiler.New().Compile(program)
+			var srcErr compiler.SourceError
+			if !errors.As(err, &srcErr) {
+				t.Fatalf("expected source error, got %v", err)
+			}
+			want := fmt.Sprintf("undefined identifier %q", tt.ident)
+			if srcErr.Message != want || srcErr.Token.Literal != tt.ident {
+				t.Errorf("expected %s at %s, got %q at %q", want, tt.ident, srcErr.Message, srcErr.Token.Literal)
+			}
+		})
+	}
+}
+
+func TestTypeExpressionErrors(t *testing.T) {
+	enum := "enum Result { data Ok {}\n data Err {} }\n"
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{enum + "type 1 = Result { Ok: f }", "type expression of enum Result misses cases Err"},
+		{enum + "type 1 = Result { Ok: f, Err: f, Other: f }", "Other is not a case of enum Result"},
+		{enum + "type 1 = Result { Any: f, Ok: f }", "case Any of enum Result must be the last case"},
+		{enum + "type 1 = Ok { Ok: f }", "type expression requires an enum, got Ok"},
+		{"enum Broken { Missing }", "case Missing of enum Broken is not a type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err, func(t *testing.T) {
+			program := prepareSourceFileParsing(t, tt.input)
+
+			err := compiler.New().Compile(program)
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestBytecodeMarshalBinary(t *testing.T) {
+	program := prepareSourceFileParsing(t, `
+	data Person { name }
+	func greet(person) { return person.name }
+	greet(Person("Max"))
+	`)
+
+	comp := compiler.New()
+	err := comp.Compile(program)
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	data, err := comp.Bytecode().MarshalBinary()
+	if err != nil {
+		t.Fatalf("encoding error: %s", err)
+	}
+	if !strings.HasPrefix(string(data), "BLSHBC\x07") {
+		t.Errorf("expected versioned header, got %q", data)
+	}
+	for _, name := range []string{"Person", "greet", "name", "Max"} {
+		if !strings.Contains(string(data), name) {
+			t.Errorf("expected %q to be encoded", name)
+		}
+	}
+}
+
 func runCompilerTests(t *testing.T, tests []compilerTestCase) {
 	t.Helper()
 
@@ -11,38 +11,67 @@ type emittedInstruction struct {
 	Position int
 }
 
+// loopContext tracks the jumps of break and continue statements within a loop
+// until their target addresses are known.
+type loopContext struct {
+	breaks    []int
+	continues []int
+}
+
 type CompilationScope struct {
 	Instructions op.Instructions
 	symbols      *ast.SymbolTable
 	locals       []*ast.Symbol
+	loops        []*loopContext
 
 	lastInstruction     emittedInstruction
 	previousInstruction emittedInstruction
 }
 
+// NumLocals returns the amount of local slots a frame running the scope requires.
+func (s *CompilationScope) NumLocals() int {
+	return len(s.locals)
+}
+
 type Bytecode struct {
 	Instructions op.Instructions
+	NumLocals    int
 	Constants    []runtime.RuntimeValue
 	Globals      []*CompilationScope
+	// positions of the pops of the trailing top level expression statement,
+	// one per branch of trailing if and switch statements
+	Results []int
 }
 
 type Compiler struct {
 	constants []runtime.RuntimeValue
 	globals   []*CompilationScope
 	plugins   *runtime.ExternPluginRegistry
+	// modules, which have already been compiled as prelude
+	linked map[*ast.ContextModule]bool
+	// the last file of the compiled program, whose trailing expression statement is the result
+	resultFile *ast.SourceFile
+	results    []int
 
 	scopes   []*CompilationScope
 	scopeIdx int
 }
 
-func New() *Compiler {
+// New creates a compiler, which binds extern declarations using the given plugins.
+// The prelude plugin is always registered.
+func New(plugins ...runtime.ExternPlugin) *Compiler {
 	mainScope := &CompilationScope{
 		Instructions: op.Instructions{},
 		symbols:      ast.MakeSymbolTable(nil, nil),
 	}
+	registry := runtime.MakeExternPluginRegistry(&runtime.Prelude{})
+	for _, p := range plugins {
+		registry.Register(p)
+	}
 	return &Compiler{
 		constants: []runtime.RuntimeValue{},
-		plugins:   &runtime.ExternPluginRegistry{},
+		plugins:   registry,
+		linked:    make(map[*ast.ContextModule]bool),
 		scopes:    []*CompilationScope{mainScope},
 		scopeIdx:  0,
 	}
@@ -55,8 +84,10 @@ func (c *Compiler) currentInstructions() op.Instructions {
 func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
+		NumLocals:    c.scopes[c.scopeIdx].NumLocals(),
 		Constants:    c.constants,
 		Globals:      c.globals,
+		Results:      c.results,
 	}
 }
 
@@ -104,6 +135,73 @@ func (c *Compiler) leaveScope() *CompilationScope {
 	return scope
 }
 
+func (c *Compiler) enterLoop() {
+	scope := c.scopes[c.scopeIdx]
+	scope.loops = append(scope.loops, &loopContext{})
+}
+
+// leaveLoop patches all pending break and continue jumps of the innermost loop.
+func (c *Compiler) leaveLoop(continuePos, breakPos int) {
+	scope := c.scopes[c.scopeIdx]
+	loop := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	for _, pos := range loop.continues {
+		c.changeOperand(pos, continuePos)
+	}
+	for _, pos := range loop.breaks {
+		c.changeOperand(pos, breakPos)
+	}
+}
+
+func (c *Compiler) currentLoop() *loopContext {
+	scope := c.scopes[c.scopeIdx]
+	if len(scope.loops) == 0 {
+		return nil
+	}
+	return scope.loops[len(scope.loops)-1]
+}
+
+// reserveHiddenLocal reserves a local slot, which cannot be referenced by any symbol.
+func (c *Compiler) reserveHiddenLocal() int {
+	scope := c.scopes[c.scopeIdx]
+	id := len(scope.locals)
+	scope.locals = append(scope.locals, nil)
+	return id
+}
+
+// useSymbols resolves identifiers within the given table until restored.
+// In contrast to enterScope, the instructions and locals will be shared.
+func (c *Compiler) useSymbols(syms *ast.SymbolTable) (restore func()) {
+	scope := c.scopes[c.scopeIdx]
+	previous := scope.symbols
+	scope.symbols = syms
+	return func() {
+		scope.symbols = previous
+	}
+}
+
+// isJumpTarget reports whether any jump within the current scope targets the given position.
+func (c *Compiler) isJumpTarget(pos int) bool {
+	ins := c.currentInstructions()
+	for i := 0; i < len(ins); i++ {
+		def, err := op.LookupDefinition(ins[i])
+		if err != nil {
+			return false
+		}
+		operands, read := op.ReadOperands(def, ins[i+1:])
+
+		switch op.Opcode(ins[i]) {
+		case op.Jump, op.JumpTrue, op.JumpFalse, op.IterNext:
+			if operands[0] == pos {
+				return true
+			}
+		}
+		i += read
+	}
+	return false
+}
+
 func (c *Compiler) isLastInstruction(opcodes ...op.Opcode) bool {
 	if len(c.currentInstructions()) == 0 {
 		return false
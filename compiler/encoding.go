@@ -0,0 +1,133 @@
+package compiler
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/vknabel/blush/op"
+	"github.com/vknabel/blush/runtime"
+)
+
+var _ encoding.BinaryMarshaler = &Bytecode{}
+
+// bytecodeMagic prefixes all encoded bytecode, followed by the format version.
+const (
+	bytecodeMagic   = "BLSHBC"
+	bytecodeVersion = 7
+)
+
+// Tags of encoded constants.
+const (
+	constantTagUnbound byte = iota
+	constantTagNull
+	constantTagBool
+	constantTagInt
+	constantTagFloat
+	constantTagChar
+	constantTagString
+	constantTagFunction
+	constantTagData
+	constantTagExtern
+	constantTagEnum
+	constantTagAnnotation
+)
+
+// MarshalBinary encodes the bytecode.
+//
+// Extern declarations are only encoded by name,
+// as their Go implementations need to be bound again when loaded.
+func (b *Bytecode) MarshalBinary() ([]byte, error) {
+	buf := append([]byte(bytecodeMagic), bytecodeVersion)
+	buf = appendInstructions(buf, b.Instructions, b.NumLocals)
+	buf = binary.AppendUvarint(buf, uint64(len(b.Results)))
+	for _, pos := range b.Results {
+		buf = binary.AppendUvarint(buf, uint64(pos))
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(b.Constants)))
+	for i, c := range b.Constants {
+		var err error
+		buf, err = appendConstant(buf, c)
+		if err != nil {
+			return nil, fmt.Errorf("constant %d: %w", i, err)
+		}
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(b.Globals)))
+	for _, g := range b.Globals {
+		buf = appendInstructions(buf, g.Instructions, g.NumLocals())
+	}
+	return buf, nil
+}
+
+func appendInstructions(buf []byte, ins op.Instructions, numLocals int) []byte {
+	buf = binary.AppendUvarint(buf, uint64(numLocals))
+	buf = binary.AppendUvarint(buf, uint64(len(ins)))
+	return append(buf, ins...)
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendConstant(buf []byte, c runtime.RuntimeValue) ([]byte, error) {
+	switch c := c.(type) {
+	case nil:
+		return append(buf, constantTagUnbound), nil
+	case runtime.Null:
+		return append(buf, constantTagNull), nil
+	case runtime.Bool:
+		if c {
+			return append(buf, constantTagBool, 1), nil
+		}
+		return append(buf, constantTagBool, 0), nil
+	case runtime.Int:
+		return binary.AppendVarint(append(buf, constantTagInt), int64(c)), nil
+	case runtime.Float:
+		return binary.BigEndian.AppendUint64(append(buf, constantTagFloat), math.Float64bits(float64(c))), nil
+	case runtime.Char:
+		return binary.AppendVarint(append(buf, constantTagChar), int64(c)), nil
+	case runtime.String:
+		return appendString(append(buf, constantTagString), string(c)), nil
+
+	case *runtime.CompiledFunction:
+		buf = appendString(append(buf, constantTagFunction), c.Symbol.Name)
+		buf = binary.AppendUvarint(buf, uint64(c.Params))
+		return appendInstructions(buf, c.Instructions, c.Locals), nil
+
+	case *runtime.DataType:
+		buf = appendString(append(buf, constantTagData), c.Symbol.Name)
+		buf = binary.AppendUvarint(buf, uint64(len(c.FieldSymbols)))
+		for _, f := range c.FieldSymbols {
+			buf = appendString(buf, f.Name)
+		}
+		iterate := int64(-1)
+		if c.Iterate != nil {
+			iterate = int64(*c.Iterate)
+		}
+		return binary.AppendVarint(buf, iterate), nil
+
+	case *runtime.EnumType:
+		buf = appendString(append(buf, constantTagEnum), c.Name())
+		buf = binary.AppendUvarint(buf, uint64(len(c.Cases)))
+		for _, id := range c.Cases {
+			buf = binary.AppendUvarint(buf, uint64(id))
+		}
+		return buf, nil
+	case *runtime.AnnotationType:
+		return appendString(append(buf, constantTagAnnotation), c.Name()), nil
+
+	case runtime.ExternFunc:
+		return appendString(append(buf, constantTagExtern), c.Name()), nil
+	case runtime.SimpleType:
+		return appendString(append(buf, constantTagExtern), c.Decl.Name), nil
+	case *runtime.AnyType:
+		return appendString(append(buf, constantTagExtern), "Any"), nil
+
+	default:
+		return nil, fmt.Errorf("cannot encode %T %q", c, c.Inspect())
+	}
+}
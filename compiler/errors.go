@@ -0,0 +1,27 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/vknabel/blush/ast"
+	"github.com/vknabel/blush/token"
+)
+
+// SourceError is a compile error located at a token of the source.
+type SourceError struct {
+	Token   token.Token
+	Message string
+}
+
+// Error implements error.
+func (e SourceError) Error() string {
+	return e.Message
+}
+
+// errUndefined reports an identifier, which could not be resolved.
+func errUndefined(ident ast.Identifier) error {
+	return SourceError{
+		Token:   ident.Token,
+		Message: fmt.Sprintf("undefined identifier %q", ident.Value),
+	}
+}
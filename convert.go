@@ -0,0 +1,160 @@
+package blush
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/vknabel/blush/ast"
+	"github.com/vknabel/blush/runtime"
+)
+
+// ToValue converts a Go value into a runtime value.
+//
+// Booleans, numbers and strings become Bool, Int, Float and String.
+// Slices and arrays become Array, maps become Dict and nil becomes Null.
+// Runtime values are passed through unchanged.
+func ToValue(v any) (runtime.RuntimeValue, error) {
+	switch v := v.(type) {
+	case nil:
+		return runtime.Null{}, nil
+	case runtime.RuntimeValue:
+		return v, nil
+	case bool:
+		return runtime.Bool(v), nil
+	case string:
+		return runtime.String(v), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return runtime.Int(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return runtime.Int(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return runtime.Float(rv.Float()), nil
+	case reflect.Bool:
+		return runtime.Bool(rv.Bool()), nil
+	case reflect.String:
+		return runtime.String(rv.String()), nil
+
+	case reflect.Slice, reflect.Array:
+		arr := make(runtime.Array, rv.Len())
+		for i := range arr {
+			el, err := ToValue(rv.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("at index %d: %w", i, err)
+			}
+			arr[i] = el
+		}
+		return arr, nil
+
+	case reflect.Map:
+		dict := make(runtime.Dict, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := ToValue(iter.Key().Interface())
+			if err != nil {
+				return nil, fmt.Errorf("at key %v: %w", iter.Key(), err)
+			}
+			val, err := ToValue(iter.Value().Interface())
+			if err != nil {
+				return nil, fmt.Errorf("at key %v: %w", iter.Key(), err)
+			}
+			dict[key] = val
+		}
+		return dict, nil
+
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return runtime.Null{}, nil
+		}
+		return ToValue(rv.Elem().Interface())
+
+	default:
+		return nil, fmt.Errorf("cannot convert %T into a runtime value", v)
+	}
+}
+
+// FromValue converts a runtime value into a Go value.
+//
+// Int becomes int64, Float becomes float64 and Char becomes rune.
+// Arrays become []any, dicts become map[any]any and data values map[string]any of their fields.
+// Null becomes nil.
+func FromValue(v runtime.RuntimeValue) (any, error) {
+	switch v := v.(type) {
+	case nil, runtime.Null:
+		return nil, nil
+	case runtime.Bool:
+		return bool(v), nil
+	case runtime.Int:
+		return int64(v), nil
+	case runtime.Float:
+		return float64(v), nil
+	case runtime.Char:
+		return rune(v), nil
+	case runtime.String:
+		return string(v), nil
+
+	case runtime.Array:
+		arr := make([]any, len(v))
+		for i, el := range v {
+			val, err := FromValue(el)
+			if err != nil {
+				return nil, fmt.Errorf("at index %d: %w", i, err)
+			}
+			arr[i] = val
+		}
+		return arr, nil
+
+	case runtime.Dict:
+		dict := make(map[any]any, len(v))
+		for k, el := range v {
+			key, err := FromValue(k)
+			if err != nil {
+				return nil, fmt.Errorf("at key %s: %w", k.Inspect(), err)
+			}
+			if key != nil && !reflect.TypeOf(key).Comparable() {
+				return nil, fmt.Errorf("at key %s: cannot be used as map key", k.Inspect())
+			}
+			val, err := FromValue(el)
+			if err != nil {
+				return nil, fmt.Errorf("at key %s: %w", k.Inspect(), err)
+			}
+			dict[key] = val
+		}
+		return dict, nil
+
+	case *runtime.DataValue:
+		fields := make(map[string]any, len(v.Fields))
+		for name, idx := range v.Fields {
+			val, err := FromValue(v.Values[idx])
+			if err != nil {
+				return nil, fmt.Errorf("at field %s: %w", name, err)
+			}
+			fields[name] = val
+		}
+		return fields, nil
+
+	default:
+		return nil, fmt.Errorf("cannot convert %T into a Go value", v)
+	}
+}
+
+var _ runtime.ExternPlugin = Funcs{}
+
+// Funcs is a plugin, which implements extern funcs by their name.
+type Funcs map[string]runtime.ExternFuncImpl
+
+// Bind implements runtime.ExternPlugin.
+func (f Funcs) Bind(module *ast.SymbolTable, decl *ast.Symbol) runtime.RuntimeValue {
+	impl, ok := f[decl.Name]
+	if !ok {
+		return nil
+	}
+	fn, err := runtime.MakeExternFunc(decl, impl)
+	if err != nil {
+		return nil
+	}
+	return fn
+}
@@ -60,10 +60,13 @@ func (l *Lexer) NextToken() token.Token {
 	case '%': // PERCENT
 		tok = l.newToken(token.PERCENT, l.ch)
 
-	case '<': // LT, LTE
+	case '<': // LT, LTE, LEFT_ARROW
 		if l.peekChar() == '=' {
 			tok = token.Token{Type: token.LTE, Literal: "<="}
 			l.advance()
+		} else if l.peekChar() == '-' {
+			tok = token.Token{Type: token.LEFT_ARROW, Literal: "<-"}
+			l.advance()
 		} else {
 			tok = l.newToken(token.LT, l.ch)
 		}
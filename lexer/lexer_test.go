@@ -207,6 +207,17 @@ func TestAllTokens(t *testing.T) {
 				{token.EOF, ""},
 			},
 		},
+		{
+			name:  "left arrow",
+			input: `<-`,
+			expected: []struct {
+				expectedType    token.TokenType
+				expectedLiteral string
+			}{
+				{token.LEFT_ARROW, "<-"},
+				{token.EOF, ""},
+			},
+		},
 		{
 			name:  "gt",
 			input: `>`,
@@ -0,0 +1,204 @@
+// Package loader parses modules together with all modules they import.
+//
+// The first segment of an import names a package and the remaining segments a submodule within it:
+// import strings.unicode refers to the unicode directory of the strings package.
+// Imports are resolved within the project first, thus submodules of the project shadow packages.
+package loader
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vknabel/blush/ast"
+	"github.com/vknabel/blush/parser"
+	"github.com/vknabel/blush/registry"
+)
+
+// Loader parses each module once, even if it is imported by multiple modules.
+// Import cycles are reported as errors of the import declaration.
+type Loader struct {
+	prelude  *ast.ContextModule
+	project  *packageModules
+	packages map[string]*packageModules
+
+	parsers map[registry.LogicalURI]*parser.ModuleParser
+	loading map[registry.LogicalURI]bool
+	// parsed modules in the order they have been completed
+	parsed  []*parser.ModuleParser
+	modules []registry.ResolvedModule
+}
+
+type Option func(*Loader)
+
+// ModuleResolver is implemented by packages, which resolve single submodules without discovering all of their modules.
+type ModuleResolver interface {
+	// ResolveModule returns the submodule at the slash separated path relative to the root of the package or nil.
+	ResolveModule(path string) (registry.ResolvedModule, error)
+}
+
+// New creates a loader, which implicitly imports the given prelude into every module.
+func New(prelude *ast.ContextModule, opts ...Option) *Loader {
+	l := &Loader{
+		prelude:  prelude,
+		packages: make(map[string]*packageModules),
+		parsers:  make(map[registry.LogicalURI]*parser.ModuleParser),
+		loading:  make(map[registry.LogicalURI]bool),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// WithPackage makes the modules of the package importable by the given import name.
+func WithPackage(name string, pkg registry.ResolvedPackage) Option {
+	return func(l *Loader) {
+		l.packages[name] = &packageModules{pkg: pkg}
+	}
+}
+
+// WithProject resolves imports relative to the root of the given package before resolving packages.
+func WithProject(pkg registry.ResolvedPackage) Option {
+	return func(l *Loader) {
+		l.project = &packageModules{pkg: pkg}
+	}
+}
+
+// Load parses the module and all modules it imports transitively.
+// Syntax and declaration errors of all modules are returned by Errors.
+func (l *Loader) Load(module registry.ResolvedModule) (*ast.ContextModule, error) {
+	return l.parse(module)
+}
+
+// Modules returns all loaded modules in the order they have been parsed.
+func (l *Loader) Modules() []registry.ResolvedModule {
+	return l.modules
+}
+
+// Errors returns the syntax and declaration errors of all loaded modules.
+// Errors of imported modules precede the errors of the importing module.
+func (l *Loader) Errors() []parser.ParseError {
+	var errs []parser.ParseError
+	for _, mp := range l.parsed {
+		errs = append(errs, mp.Errors()...)
+		errs = append(errs, mp.SymbolErrors()...)
+	}
+	return errs
+}
+
+func (l *Loader) parse(module registry.ResolvedModule) (*ast.ContextModule, error) {
+	uri := module.URI()
+	if mp, ok := l.parsers[uri]; ok {
+		if l.loading[uri] {
+			return nil, fmt.Errorf("import cycle through %s", uri)
+		}
+		return mp.Module(), nil
+	}
+
+	mp := parser.NewModuleParse(module).WithImports(l.resolve)
+	if l.prelude != nil {
+		mp.WithPrelude(l.prelude)
+	}
+	l.parsers[uri] = mp
+	l.loading[uri] = true
+	defer delete(l.loading, uri)
+
+	_, err := mp.Parse(module)
+	if err != nil {
+		delete(l.parsers, uri)
+		return nil, err
+	}
+	l.parsed = append(l.parsed, mp)
+	l.modules = append(l.modules, module)
+	return mp.Module(), nil
+}
+
+// resolve implements parser.ImportResolver.
+func (l *Loader) resolve(ref ast.StaticReference) (*ast.ContextModule, error) {
+	if l.project != nil {
+		mod, err := l.project.module(ref)
+		if err != nil {
+			return nil, err
+		}
+		if mod != nil {
+			return l.parse(mod)
+		}
+	}
+
+	pkg, ok := l.packages[ref[0].Value]
+	if !ok {
+		return nil, fmt.Errorf("no module or package named %s", ref)
+	}
+	mod, err := pkg.module(ref[1:])
+	if err != nil {
+		return nil, err
+	}
+	if mod == nil {
+		return nil, fmt.Errorf("package %s has no module %s", ref[0].Value, ref)
+	}
+	return l.parse(mod)
+}
+
+// packageModules lazily resolves the modules of a package.
+type packageModules struct {
+	pkg     registry.ResolvedPackage
+	root    registry.LogicalURI
+	modules map[registry.LogicalURI]registry.ResolvedModule
+}
+
+// module returns the submodule at the given path relative to the root of the package or nil.
+func (p *packageModules) module(path ast.StaticReference) (registry.ResolvedModule, error) {
+	segments := make([]string, len(path))
+	for i, ident := range path {
+		segments[i] = ident.Value
+	}
+	if resolver, ok := p.pkg.(ModuleResolver); ok {
+		return resolver.ResolveModule(strings.Join(segments, "/"))
+	}
+
+	if p.modules == nil {
+		mods, err := p.pkg.ResolveModules()
+		if err != nil {
+			return nil, err
+		}
+		p.root = packageRoot(p.pkg, mods)
+		p.modules = make(map[registry.LogicalURI]registry.ResolvedModule, len(mods))
+		for _, mod := range mods {
+			p.modules[mod.URI()] = mod
+		}
+	}
+
+	uri := p.root
+	if len(segments) > 0 {
+		uri = uri.Join(strings.Join(segments, "/"))
+	}
+	return p.modules[uri], nil
+}
+
+// packageRoot returns the logical URI of the root directory of the package.
+// Packages on the file system are rooted at their source, others at the common prefix of their modules.
+func packageRoot(pkg registry.ResolvedPackage, mods []registry.ResolvedModule) registry.LogicalURI {
+	source := registry.LogicalURI(pkg.Source())
+	if len(mods) == 0 {
+		return source
+	}
+	root := mods[0].URI()
+	for _, mod := range mods[1:] {
+		for !within(mod.URI(), root) {
+			idx := strings.LastIndex(string(root), "/")
+			if idx < 0 {
+				return source
+			}
+			root = root[:idx]
+		}
+	}
+	if within(root, source) {
+		return source
+	}
+	return root
+}
+
+// within reports whether the uri equals the root or is nested within it.
+func within(uri, root registry.LogicalURI) bool {
+	return uri == root || strings.HasPrefix(string(uri), strings.TrimSuffix(string(root), "/")+"/")
+}
@@ -0,0 +1,168 @@
+package loader_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	"github.com/vknabel/blush/ast"
+	"github.com/vknabel/blush/loader"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/localreg"
+	"github.com/vknabel/blush/registry/staticmodule"
+)
+
+func TestLoader(t *testing.T) {
+	fs := memfs.New()
+	files := map[string]string{
+		"/project/main.blush":            "let answer = 42",
+		"/project/utils/utils.blush":     "import strings.unicode\nfunc util() {}",
+		"/project/cyclic/cyclic.blush":   "import other",
+		"/project/other/other.blush":     "import cyclic",
+		"/strings/strings.blush":         "func join() {}",
+		"/strings/unicode/unicode.blush": "import strings\nfunc upper() {}",
+	}
+	for name, contents := range files {
+		if err := billyutil.WriteFile(fs, name, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ld := loader.New(nil,
+		loader.WithProject(resolvePackage(t, fs, "/project")),
+		loader.WithPackage("strings", resolvePackage(t, fs, "/strings")),
+	)
+
+	main := staticmodule.NewModule("testing:///main", []registry.Source{
+		staticmodule.NewSourceString("testing:///main/main.blush", `
+		import utils
+		import strings { join }
+		import uni = strings.unicode
+		import unicode = strings.unicode
+		import missing
+		import cyclic
+		`),
+	})
+	mod, err := ld.Load(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	symbols := mod.Files[0].Symbols.Symbols
+	for alias, uri := range map[string]registry.LogicalURI{
+		"utils":   "/project/utils",
+		"uni":     "/strings/unicode",
+		"unicode": "/strings/unicode",
+		"cyclic":  "/project/cyclic",
+	} {
+		imported := importedModule(t, symbols[alias])
+		if imported.Name != uri {
+			t.Errorf("expected %s to import %s, got %s", alias, uri, imported.Name)
+		}
+	}
+	if importedModule(t, symbols["uni"]) != importedModule(t, symbols["unicode"]) {
+		t.Error("expected modules to be parsed once")
+	}
+	join := symbols["join"]
+	if join == nil || join.Original().Decl == nil || join.Original().Decl.DeclName().Value != "join" {
+		t.Errorf("expected join to refer to the member of strings, got %v", join)
+	}
+	if sym := symbols["missing"]; sym == nil || sym.ChildTable != nil {
+		t.Errorf("expected missing import to stay unresolved, got %v", sym)
+	}
+
+	var details []string
+	for _, err := range ld.Errors() {
+		details = append(details, err.Summary+", "+err.Details)
+	}
+	want := []string{
+		"cannot import cyclic, import cycle through /project/cyclic",
+		"cannot import missing, no module or package named missing",
+	}
+	if len(details) != len(want) {
+		t.Fatalf("expected errors %q, got %q", want, details)
+	}
+	for i, w := range want {
+		if details[i] != w {
+			t.Errorf("expected error %q, got %q", w, details[i])
+		}
+	}
+}
+
+func resolvePackage(t *testing.T, fs billy.Filesystem, dir string) registry.ResolvedPackage {
+	t.Helper()
+	ctx := context.Background()
+	pkgs, err := localreg.New(fs, "/").DiscoverPackageVersions(ctx, dir)
+	if err != nil || len(pkgs) != 1 {
+		t.Fatalf("expected package at %s, got %v, %v", dir, pkgs, err)
+	}
+	pkg, err := pkgs[0].Resolve(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
+
+func importedModule(t *testing.T, sym *ast.Symbol) *ast.ContextModule {
+	t.Helper()
+	if sym == nil || sym.ChildTable == nil {
+		t.Fatalf("expected resolved import, got %v", sym)
+	}
+	mod, ok := sym.ChildTable.OpenedBy.(*ast.ContextModule)
+	if !ok {
+		t.Fatalf("expected imported module, got %T", sym.ChildTable.OpenedBy)
+	}
+	return mod
+}
+
+// resolvingPackage resolves its submodules by path only.
+type resolvingPackage struct {
+	registry.ResolvedPackage
+	modules map[string]registry.ResolvedModule
+}
+
+func (p resolvingPackage) ResolveModules() ([]registry.ResolvedModule, error) {
+	return nil, errors.New("expected modules to be resolved by path")
+}
+
+func (p resolvingPackage) ResolveModule(path string) (registry.ResolvedModule, error) {
+	return p.modules[path], nil
+}
+
+func TestLoaderModuleResolver(t *testing.T) {
+	fs := memfs.New()
+	if err := fs.MkdirAll("/project", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	utils := staticmodule.NewModule("/project/utils", []registry.Source{
+		staticmodule.NewSourceString("/project/utils/utils.blush", "func util() {}"),
+	})
+	ld := loader.New(nil, loader.WithProject(resolvingPackage{
+		ResolvedPackage: resolvePackage(t, fs, "/project"),
+		modules:         map[string]registry.ResolvedModule{"utils": utils},
+	}))
+
+	main := staticmodule.NewModule("testing:///main", []registry.Source{
+		staticmodule.NewSourceString("testing:///main/main.blush", "import utils\nimport missing"),
+	})
+	mod, err := ld.Load(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported := importedModule(t, mod.Files[0].Symbols.Symbols["utils"]); imported.Name != "/project/utils" {
+		t.Errorf("expected utils to import /project/utils, got %s", imported.Name)
+	}
+	if errs := ld.Errors(); len(errs) != 1 || errs[0].Details != "no module or package named missing" {
+		t.Errorf("expected missing import to fail, got %v", errs)
+	}
+
+	var uris []registry.LogicalURI
+	for _, m := range ld.Modules() {
+		uris = append(uris, m.URI())
+	}
+	if len(uris) != 2 || uris[0] != "/project/utils" || uris[1] != "testing:///main" {
+		t.Errorf("expected loaded modules in parse order, got %v", uris)
+	}
+}
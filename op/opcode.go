@@ -10,17 +10,36 @@ const (
 
 	Array
 	Dict
+	// pops a value and an array, pushes the array with the value appended
+	Append
 
 	GetIndex
 	GetField
+	// pops a value, an index and a collection, replaces the element at the index
+	SetIndex
+	// pops a value and a data value, replaces the field
+	SetField
 
 	// does not consume, just assert top value's type
 	AssertType
+	// pops a type and a value, pushes whether the value is of the type
+	IsType
+	// pops an annotation type and a value, pushes whether the value's type has the annotation
+	HasAnnotation
+	// pops a value, which matches no case of the enum type, and fails
+	Unmatched
 
 	Jump
 	JumpTrue
 	JumpFalse
 
+	// pops a collection and pushes an iterator over its elements
+	Iterate
+	// pushes the next element of the iterator on top or jumps when exhausted
+	IterNext
+	// pops the iterator and lets it finish, even if the loop was left early
+	IterEnd
+
 	Negate
 	Invert
 
@@ -42,7 +61,20 @@ const (
 	GetGlobal
 	SetGlobal
 	GetLocal
+	// pops a value and declares it as a new local, captured or not
 	SetLocal
+	// pops a value and replaces the local or the value of its cell, if it has been captured
+	AssignLocal
+
+	// creates a closure of a function constant capturing the topmost cells
+	Closure
+	// pushes the cell of a local, which will be shared by the function and its closures
+	CaptureLocal
+	// pushes the cell of a variable captured by the current closure
+	CaptureFree
+	GetFree
+	// pops a value and replaces the value of a cell of the current closure
+	SetFree
 
 	// Serves as instruction to optionally pause on breakpoints.
 	// Will not be compiled for non debugging sessions.
@@ -59,15 +91,26 @@ var definitions = map[Opcode]*Definition{
 	Array: {"array", []int{}},
 	Dict:  {"dict", []int{}},
 
+	Append: {"append", []int{}},
+
 	GetIndex: {"getindex", []int{}},
 	GetField: {"getfield", []int{2}}, // name id
+	SetIndex: {"setindex", []int{}},
+	SetField: {"setfield", []int{2}}, // name id
 
-	AssertType: {"asserttype", []int{2}}, // type id
+	AssertType:    {"asserttype", []int{2}}, // type id
+	IsType:        {"istype", []int{}},
+	HasAnnotation: {"hasannotation", []int{}},
+	Unmatched:     {"unmatched", []int{2}}, // type id
 
 	Jump:      {"jump", []int{2}},      // address
 	JumpTrue:  {"jumptrue", []int{2}},  // address
 	JumpFalse: {"jumpfalse", []int{2}}, // address
 
+	Iterate:  {"iterate", []int{}},
+	IterNext: {"iternext", []int{2}}, // address when exhausted
+	IterEnd:  {"iterend", []int{}},
+
 	Negate: {"negate", []int{}},
 	Invert: {"invert", []int{}},
 
@@ -75,6 +118,7 @@ var definitions = map[Opcode]*Definition{
 	Sub: {"sub", []int{}},
 	Mul: {"mul", []int{}},
 	Div: {"div", []int{}},
+	Mod: {"mod", []int{}},
 
 	Equal:              {"eq", []int{}},
 	NotEqual:           {"neq", []int{}},
@@ -83,12 +127,19 @@ var definitions = map[Opcode]*Definition{
 	LessThan:           {"lt", []int{}},
 	LessThanOrEqual:    {"lte", []int{}},
 
-	Call:      {"call", []int{2}}, // arg count
-	Return:    {"return", []int{}},
-	GetGlobal: {"getglobal", []int{2}},
-	SetGlobal: {"setglobal", []int{2}},
-	GetLocal:  {"getlocal", []int{2}},
-	SetLocal:  {"setlocal", []int{2}},
+	Call:        {"call", []int{2}}, // arg count
+	Return:      {"return", []int{}},
+	GetGlobal:   {"getglobal", []int{2}},
+	SetGlobal:   {"setglobal", []int{2}},
+	GetLocal:    {"getlocal", []int{2}},
+	SetLocal:    {"setlocal", []int{2}},
+	AssignLocal: {"assignlocal", []int{2}},
+
+	Closure:      {"closure", []int{2, 1}}, // const id, free count
+	CaptureLocal: {"capturelocal", []int{2}},
+	CaptureFree:  {"capturefree", []int{2}},
+	GetFree:      {"getfree", []int{2}},
+	SetFree:      {"setfree", []int{2}},
 
 	Debug: {"debug", []int{}},
 }
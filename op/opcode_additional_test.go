@@ -46,6 +46,7 @@ func TestMakeOpcodes(t *testing.T) {
 	}{
 		{"const", Const, []int{65535}, []byte{byte(Const), 255, 255}},
 		{"add", Add, nil, []byte{byte(Add)}},
+		{"closure", Closure, []int{65534, 255}, []byte{byte(Closure), 255, 254, 255}},
 		{"undefined", Opcode(255), nil, []byte{}},
 	}
 
@@ -92,6 +93,7 @@ func TestInstructionsString(t *testing.T) {
 	}{
 		{"const+add", append(append(Instructions{}, Make(Const, 2)...), Make(Add)...), "0000 const 2\n0003 add\n"},
 		{"jump", Instructions(Make(Jump, 5)), "0000 jump 5\n"},
+		{"closure+getfree", append(append(Instructions{}, Make(Closure, 2, 1)...), Make(GetFree, 0)...), "0000 closure 2 1\n0004 getfree 0\n"},
 		{"unknown", append(append(Instructions{}, Make(Const, 1)...), 255), "0000 const 1\nERROR: opcode 255 undefined\n"},
 	}
 
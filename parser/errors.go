@@ -62,6 +62,10 @@ func (p *Parser) errStatementMisplaced(pos StatementPosition) {
 	switch p.curToken.Type {
 	case token.RETURN:
 		summary = "return must be inside function"
+	case token.BREAK:
+		summary = "break must be inside loop"
+	case token.CONTINUE:
+		summary = "continue must be inside loop"
 	case token.IMPORT:
 		summary = "imports must be global"
 	case token.EXTERN:
@@ -91,7 +95,7 @@ func (p *Parser) errStatementMisplaced(pos StatementPosition) {
 	case IN_FOR:
 		details = "not allowed in for loop"
 	case IN_SWITCH:
-		details = "not allowed in switch statement"
+		details = "not allowed in switch expression"
 	}
 	p.detectError(ParseError{
 		Token:   p.curToken,
@@ -105,3 +109,35 @@ func (p *Parser) errCannotBeAnnotated() {
 		Summary: fmt.Sprintf("%s cannot be annotated", strings.ToLower(string(p.curToken.Type))),
 	})
 }
+
+func (p *Parser) errUnreachableSwitchCase(tok token.Token) {
+	p.detectError(ParseError{
+		Token:   tok,
+		Summary: "unreachable case",
+		Details: "cases after case _ never match",
+	})
+}
+
+func (p *Parser) errSwitchWithoutWildcard(tok token.Token) {
+	p.detectError(ParseError{
+		Token:   tok,
+		Summary: "switch expression requires case _",
+		Details: "add case _ as the last case",
+	})
+}
+
+func (p *Parser) errSwitchCaseWithoutExpr(tok token.Token) {
+	p.detectError(ParseError{
+		Token:   tok,
+		Summary: "switch expression case must end with an expression",
+		Details: "got no trailing expression",
+	})
+}
+
+func (p *Parser) errDuplicateTypeCase(tok token.Token) {
+	p.detectError(ParseError{
+		Token:   tok,
+		Summary: "duplicate case",
+		Details: fmt.Sprintf("case %s is already handled", tok.Literal),
+	})
+}
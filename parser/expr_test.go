@@ -37,6 +37,11 @@ func TestExprIdentifier(t *testing.T) {
 		{"some()", "some(some)"},
 		{"call(1, 2)", "call(1, 2call)"},
 		{"{}", "{->/* 0 stmts */}"},
+		{"(for x <- xs { x })", "(for x <- xs { /* 1 stmts */ })"},
+		{"(for c { 1 })", "(for c { /* 1 stmts */ })"},
+		{"(for { break })", "(for { /* 1 stmts */ })"},
+		{"type r = Result { Ok: { ok -> ok.value }, Err: f }", "(type r = Result { Ok: {ok->/* 1 stmts */}, Err: f })"},
+		{"type r = Result {\n  Ok: a,\n  Err: b,\n}", "(type r = Result { Ok: a, Err: b })"},
 	}
 
 	for i, tt := range tests {
@@ -12,11 +12,13 @@ func TestImportDecl(t *testing.T) {
 		alias   string
 		module  []string
 		members []string
+		full    string
 	}{
-		{"import alias = foo.bar { one, two }", "alias", []string{"foo", "bar"}, []string{"one", "two"}},
-		{"import alias = foo.bar", "alias", []string{"foo", "bar"}, nil},
-		{"import foo.bar { one }", "bar", []string{"foo"}, []string{"one"}},
-		{"import foo.bar", "bar", []string{"foo"}, nil},
+		{"import alias = foo.bar { one, two }", "alias", []string{"foo", "bar"}, []string{"one", "two"}, "foo.bar"},
+		{"import alias = foo.bar", "alias", []string{"foo", "bar"}, nil, "foo.bar"},
+		{"import foo.bar { one }", "bar", []string{"foo"}, []string{"one"}, "foo.bar"},
+		{"import foo.bar", "bar", []string{"foo"}, nil, "foo.bar"},
+		{"import foo", "foo", []string{}, nil, "foo"},
 	}
 
 	for _, tt := range tests {
@@ -54,6 +56,9 @@ func TestImportDecl(t *testing.T) {
 			if decl.Alias.Value != tt.alias {
 				t.Fatalf("unexpected alias %q", decl.Alias.Value)
 			}
+			if decl.Module().String() != tt.full {
+				t.Fatalf("unexpected imported module %q", decl.Module())
+			}
 		})
 	}
 }
@@ -6,9 +6,13 @@ import (
 	"github.com/vknabel/blush/registry"
 )
 
+// ImportResolver returns the parsed module for the fully qualified name of an import.
+type ImportResolver func(module ast.StaticReference) (*ast.ContextModule, error)
+
 type ModuleParser struct {
 	module        registry.ResolvedModule
 	contextModule *ast.ContextModule
+	resolveImport ImportResolver
 
 	srcp []*Parser
 }
@@ -33,6 +37,7 @@ func (mp *ModuleParser) Parse(module registry.ResolvedModule) (*ast.ContextModul
 			return nil, err
 		}
 		prs := NewSourceParser(lex, mp.contextModule.Symbols, string(src.URI()))
+		prs.resolveImport = mp.resolveImport
 		mp.srcp = append(mp.srcp, prs)
 	}
 
@@ -51,6 +56,33 @@ func (mp *ModuleParser) Errors() []ParseError {
 	return errs
 }
 
+// Module returns the parsed module, which is populated by Parse.
+func (mp *ModuleParser) Module() *ast.ContextModule {
+	return mp.contextModule
+}
+
 func (mp *ModuleParser) Symbols() *ast.SymbolTable {
 	return mp.contextModule.Symbols
 }
+
+// SymbolErrors returns the declaration and usage errors of the module and all of its files.
+func (mp *ModuleParser) SymbolErrors() []ParseError {
+	errs := symerrs(mp.contextModule.Symbols)
+	for _, src := range mp.contextModule.Files {
+		errs = append(errs, symerrs(src.Symbols)...)
+	}
+	return errs
+}
+
+// WithPrelude implicitly imports the prelude into the parsed module.
+func (mp *ModuleParser) WithPrelude(prelude *ast.ContextModule) *ModuleParser {
+	mp.contextModule.UsePrelude(prelude)
+	return mp
+}
+
+// WithImports resolves imports while parsing, which links the import declarations to the imported modules.
+// Without a resolver, imports stay unresolved.
+func (mp *ModuleParser) WithImports(resolve ImportResolver) *ModuleParser {
+	mp.resolveImport = resolve
+	return mp
+}
@@ -17,6 +17,9 @@ type Parser struct {
 	peekToken token.Token
 
 	curSymbolTable *ast.SymbolTable
+	resolveImport  ImportResolver
+	// depth of nested functions, returns are only allowed within functions
+	inFunc int
 
 	prefixParsers map[token.TokenType]prefixParser
 	infixParsers  map[token.TokenType]infixParser
@@ -42,8 +45,9 @@ func NewSourceParser(lex *lexer.Lexer, parent *ast.SymbolTable, path string) *Pa
 	p.registerPrefix(token.LPAREN, p.parsePrattExprGroup)
 	p.registerPrefix(token.IF, p.parsePrattExprIfElse) // only exactly one expr per if / else if / else, else mandatory, later we eventually want to allow assignments and local vars
 	p.registerPrefix(token.LBRACE, p.parsePrattExprFunc)
-	// p.registerPrefix(token.TYPE, p.parseExprType) // only exactly one expr per case
-	// p.registerPrefix(token.SWITCH / MATCH, p.parseExprSwitch) // only exactly one expr per case
+	p.registerPrefix(token.FOR, p.parsePrattExprFor)
+	p.registerPrefix(token.TYPE, p.parsePrattExprType)     // only exactly one expr per case
+	p.registerPrefix(token.SWITCH, p.parsePrattExprSwitch) // variables allowed, but exactly one trailing expr per case, _ mandatory
 	p.registerPrefix(token.LBRACKET, p.parseExprListOrDict)
 	p.registerPrefix(token.STRING, p.parsePrattExprString)
 	p.registerPrefix(token.CHAR, p.parsePrattExprChar)
@@ -112,9 +116,17 @@ func symerrs(st *ast.SymbolTable) []ParseError {
 			}
 		}
 
-		if s.ChildTable != nil {
-			errs = append(errs, symerrs(s.ChildTable)...)
+		if s.ChildTable == nil {
+			continue
+		}
+		if _, ok := s.ChildTable.OpenedBy.(*ast.ContextModule); ok {
+			// imported modules report their own errors
+			continue
 		}
+		errs = append(errs, symerrs(s.ChildTable)...)
+	}
+	for _, block := range st.Blocks {
+		errs = append(errs, symerrs(block)...)
 	}
 	return errs
 }
@@ -183,6 +195,15 @@ func (p *Parser) expect(tokTypes ...token.TokenType) (token.Token, bool) {
 	return cur, true
 }
 
+// expectName expects the name of a field or member.
+// Other than declarations, fields may be named by keywords like type or module.
+func (p *Parser) expectName() (token.Token, bool) {
+	if p.curToken.Type != token.IDENT && p.curToken.Type != token.BLANK && token.LookupIdent(p.curToken.Literal) == p.curToken.Type {
+		return p.nextToken(), true
+	}
+	return p.expect(token.IDENT)
+}
+
 func (p *Parser) skip(tokTypes ...token.TokenType) {
 	if !p.curIs(tokTypes...) {
 		return
@@ -247,10 +268,12 @@ func (p *Parser) parseEnumDecl(pos StatementPosition, annos ast.AnnotationChain)
 	p.expect(token.LBRACE)
 
 	var childDecls []ast.StatementDeclaration
-	for !p.curIs(token.RBRACE) {
+	for !p.curIs(token.RBRACE, token.EOF) {
 		enumCase, children := p.parseEnumDeclCase(pos)
 		childDecls = append(childDecls, children...)
-		enum.AddCase(enumCase)
+		if enumCase != nil {
+			enum.AddCase(enumCase)
+		}
 	}
 	p.expect(token.RBRACE)
 
@@ -278,6 +301,8 @@ func (p *Parser) parseEnumDeclCase(pos StatementPosition) (*ast.DeclEnumCase, []
 		return ast.MakeDeclEnumCase(enumDecl.Token, ast.StaticReference{enumDecl.DeclName()}), append(childDecls, enumDecl)
 	default:
 		p.errUnexpectedToken(token.DATA, token.ENUM)
+		// skip the token to continue with the next case
+		p.nextToken()
 		return nil, nil
 	}
 }
@@ -352,13 +377,22 @@ func (p *Parser) parseDataDecl(_ StatementPosition, annos ast.AnnotationChain) *
 //	@Annotation() method()
 func (p *Parser) parseDataDeclField() *ast.DeclField {
 	annotations := p.parseAnnotationChain()
-	identTok, _ := p.expect(token.IDENT)
+	identTok, ok := p.expectName()
+	if !ok {
+		// skip the token to continue with the next field
+		p.nextToken()
+		return nil
+	}
 	name := ast.MakeIdentifier(identTok)
 
 	if !p.curIs(token.LPAREN) {
 		return ast.MakeDeclField(name, nil, annotations)
 	}
 
+	// parameters of different fields may share their names
+	p.curSymbolTable = ast.MakeSymbolTable(p.curSymbolTable, name)
+	defer p.popSymbolTable()
+
 	p.expect(token.LPAREN)
 	params := p.parseDeclParameterList()
 	p.expect(token.RPAREN)
@@ -381,6 +415,9 @@ func (p *Parser) parseAnnotationDecl(_ StatementPosition, annos ast.AnnotationCh
 	if !p.curIs(token.LBRACE) {
 		return declAnno
 	}
+	p.curSymbolTable = ast.MakeSymbolTable(p.curSymbolTable, declAnno)
+	defer p.popSymbolTable()
+
 	p.expect(token.LBRACE)
 	fields := p.parsePropertyDeclarationList()
 	for _, f := range fields {
@@ -483,7 +520,8 @@ func (p *Parser) parseExternFuncDecl(externTok token.Token, annos ast.Annotation
 // parseExternValueDecl parses extern let declarations
 func (p *Parser) parseExternValueDecl(externTok token.Token, annos ast.AnnotationChain) *ast.DeclExternValue {
 	p.expect(token.LET)
-	nameTok, _ := p.expect(token.IDENT)
+	// the prelude declares the literals true, false and null
+	nameTok, _ := p.expect(token.IDENT, token.TRUE, token.FALSE, token.NULL)
 	nameIdent := ast.MakeIdentifier(nameTok)
 
 	extern := ast.MakeDeclExternValue(externTok, nameIdent)
@@ -508,7 +546,9 @@ func (p *Parser) parseFunctionDecl(_ StatementPosition, annos ast.AnnotationChai
 		p.expect(token.RPAREN)
 
 		fexprTok, _ := p.expect(token.LBRACE)
+		p.inFunc++
 		block := p.parseStmtBlock(IN_FUNC)
+		p.inFunc--
 		p.expect(token.RBRACE)
 
 		impl.SetImplBlock(block)
@@ -546,41 +586,90 @@ func (p *Parser) parseImportDecl(pos StatementPosition, annos ast.AnnotationChai
 		importDecl = ast.MakeDeclImport(importTok, moduleName)
 	}
 
-	if !p.curIs(token.LBRACE) {
-		return importDecl
+	if p.curIs(token.LBRACE) {
+		p.expect(token.LBRACE)
+		for !p.curIs(token.RBRACE) {
+			memberTok, _ := p.expect(token.IDENT)
+			member := ast.MakeDeclImportMember(memberTok, importDecl.ModuleName, ast.MakeIdentifier(memberTok))
+			importDecl.AddMember(member)
+
+			if p.curIs(token.COMMA) {
+				p.expect(token.COMMA)
+			}
+		}
+		p.expect(token.RBRACE)
 	}
-	p.expect(token.LBRACE)
-	for !p.curIs(token.RBRACE) {
-		memberTok, _ := p.expect(token.IDENT)
-		member := ast.MakeDeclImportMember(memberTok, importDecl.ModuleName, ast.MakeIdentifier(memberTok))
-		importDecl.AddMember(member)
 
-		if p.curIs(token.COMMA) {
-			p.expect(token.COMMA)
+	sym := p.curSymbolTable.Insert(importDecl)
+	var module *ast.ContextModule
+	if p.resolveImport != nil {
+		var err error
+		module, err = p.resolveImport(importDecl.Module())
+		if err != nil {
+			p.detectError(ParseError{
+				Token:   importTok,
+				Summary: fmt.Sprintf("cannot import %s", importDecl.Module()),
+				Details: err.Error(),
+			})
+		} else {
+			sym.ChildTable = module.Symbols
 		}
 	}
-	p.expect(token.RBRACE)
+	for i := range importDecl.Members {
+		member := &importDecl.Members[i]
+		memberSym := p.curSymbolTable.Insert(member)
+		if module == nil {
+			continue
+		}
+		// members refer to the declarations of the imported module
+		target, ok := module.Symbols.Symbols[member.Name.Value]
+		if !ok || target.Decl == nil || target.Scope == ast.FreeScope {
+			p.detectError(ParseError{
+				Token:   member.Token,
+				Summary: fmt.Sprintf("unknown member %s", member.Name.Value),
+				Details: fmt.Sprintf("not declared by module %s", importDecl.Module()),
+			})
+			continue
+		}
+		memberSym.Parent = target
+	}
 	return importDecl
 }
 
 func (p *Parser) parseVariableDecl(pos StatementPosition, annos ast.AnnotationChain) *ast.DeclVariable {
 	letTok, _ := p.expect(token.LET)
-	nameTok, _ := p.expect(token.IDENT)
+	// the prelude declares the literals true, false and null
+	nameTok, _ := p.expect(token.IDENT, token.TRUE, token.FALSE, token.NULL)
 	name := ast.MakeIdentifier(nameTok)
 	p.expect(token.ASSIGN)
+
+	isGlobal := pos < IN_FUNC
+	var initializer *ast.SymbolTable
+	if isGlobal {
+		// like function bodies, initializers of globals declare their own locals
+		initializer = ast.MakeSymbolTable(p.curSymbolTable, name)
+		p.curSymbolTable = initializer
+	}
 	expr := p.parseExpr()
+	if isGlobal {
+		p.popSymbolTable()
+	}
+
 	let := ast.MakeDeclVariable(letTok, name, expr)
-	let.IsGlobal = pos < IN_FUNC
+	let.IsGlobal = isGlobal
 	let.Annotations = annos
 
-	p.curSymbolTable.Insert(let)
+	sym := p.curSymbolTable.Insert(let)
+	if isGlobal {
+		sym.ChildTable = initializer
+	}
 	return let
 }
 
 func (p *Parser) parsePropertyDeclarationList() []ast.DeclField {
 	var fields []ast.DeclField
 	for {
-		if p.curToken.Type == token.RBRACE {
+		if p.curIs(token.RBRACE, token.EOF) {
 			return fields
 		}
 		field := p.parseDataDeclField()
@@ -643,7 +732,7 @@ func (p *Parser) parseDeclParameterList() []ast.DeclParameter {
 }
 
 func (p *Parser) parseStatementReturn(pos StatementPosition) *ast.StmtReturn {
-	if pos != IN_FUNC {
+	if p.inFunc == 0 {
 		p.errStatementMisplaced(pos)
 	}
 	retTok, _ := p.expect(token.RETURN)
@@ -661,6 +750,38 @@ func (p *Parser) parseStatementReturn(pos StatementPosition) *ast.StmtReturn {
 	return ast.MakeStmtReturn(retTok, expr)
 }
 
+// parseStatementAssign parses the assignment of a value to the already parsed target.
+// A nil target discards the value.
+//
+//	<expr> = <expr>
+//	_ = <expr>
+func (p *Parser) parseStatementAssign(tok token.Token, target ast.Expr) *ast.StmtAssign {
+	p.expect(token.ASSIGN)
+	return ast.MakeStmtAssign(tok, target, p.parseExpr())
+}
+
+// parseStatementBreak parses a break out of the innermost loop
+//
+//	break
+func (p *Parser) parseStatementBreak(pos StatementPosition) *ast.StmtBreak {
+	if pos != IN_FOR {
+		p.errStatementMisplaced(pos)
+	}
+	tok, _ := p.expect(token.BREAK)
+	return ast.MakeStmtBreak(tok)
+}
+
+// parseStatementContinue parses a skip to the next iteration of the innermost loop
+//
+//	continue
+func (p *Parser) parseStatementContinue(pos StatementPosition) *ast.StmtContinue {
+	if pos != IN_FOR {
+		p.errStatementMisplaced(pos)
+	}
+	tok, _ := p.expect(token.CONTINUE)
+	return ast.MakeStmtContinue(tok)
+}
+
 func (p *Parser) parseStatementIf(pos StatementPosition) ast.StmtIf {
 	ifTok, _ := p.expect(token.IF)
 	cond := p.parseExpr()
@@ -697,6 +818,219 @@ func (p *Parser) parseStatementElseIf(pos StatementPosition) ast.StmtElseIf {
 	return ast.MakeStmtIfElse(elseTok, cond, block)
 }
 
+// parseStatementFor parses all loop forms:
+//
+//	for { <block> }
+//	for <expr> { <block> }
+//	for <identifier> <- <expr> { <block> }
+func (p *Parser) parseStatementFor(_ StatementPosition) *ast.StmtFor {
+	forTok, _ := p.expect(token.FOR)
+	forStmt := ast.MakeStmtFor(forTok)
+
+	element, collection, ok := p.parseForCollection()
+	if ok {
+		forStmt.SetCollection(element, collection)
+	} else if !p.curIs(token.LBRACE) {
+		forStmt.SetCondition(p.parseExpr())
+	}
+
+	forStmt.SetBlock(p.parseForBlock(forStmt, element))
+	return forStmt
+}
+
+// parseExprFor parses all loop forms in expression position:
+//
+//	for { <block> }
+//	for <expr> { <block> }
+//	for <identifier> <- <expr> { <block> }
+func (p *Parser) parseExprFor() *ast.ExprFor {
+	forTok, _ := p.expect(token.FOR)
+	forExpr := ast.MakeExprFor(forTok)
+
+	element, collection, ok := p.parseForCollection()
+	if ok {
+		forExpr.SetCollection(element, collection)
+	} else if !p.curIs(token.LBRACE) {
+		forExpr.SetCondition(p.parseExpr())
+	}
+
+	forExpr.SetBlock(p.parseForBlock(forExpr, element))
+	return forExpr
+}
+
+// parseForBlock parses the block of a loop within its own symbol table,
+// which declares the optional element and all locals of the block.
+//
+//	{ <block> }
+func (p *Parser) parseForBlock(loop ast.Node, element *ast.DeclParameter) (ast.Block, *ast.SymbolTable) {
+	p.curSymbolTable = ast.MakeBlockSymbolTable(p.curSymbolTable, loop)
+	defer p.popSymbolTable()
+
+	if element != nil {
+		p.curSymbolTable.Insert(element)
+	}
+	p.expect(token.LBRACE)
+	block := p.parseStmtBlock(IN_FOR)
+	p.expect(token.RBRACE)
+	return block, p.curSymbolTable
+}
+
+// parseForCollection parses the optional element and collection of a loop.
+//
+//	<identifier> <- <expr>
+func (p *Parser) parseForCollection() (*ast.DeclParameter, ast.Expr, bool) {
+	if !p.curIs(token.IDENT) || !p.peekIs(token.LEFT_ARROW) {
+		return nil, nil, false
+	}
+	identTok, _ := p.expect(token.IDENT)
+	p.expect(token.LEFT_ARROW)
+	collection := p.parseExpr()
+
+	element := ast.MakeDeclParameter(ast.MakeIdentifier(identTok), nil)
+	return element, collection, true
+}
+
+// parseStatementSwitch parses switch statements, which run the block of the first matching case:
+//
+//	switch <expr> {
+//	case <expr>: <block>
+//	case <annotation>: <block>
+//	case _: <block>
+//	}
+func (p *Parser) parseStatementSwitch(pos StatementPosition) *ast.StmtSwitch {
+	switchTok, _ := p.expect(token.SWITCH)
+	switchStmt := ast.MakeStmtSwitch(switchTok, p.parseExpr())
+
+	p.expect(token.LBRACE)
+	for _, c := range p.parseSwitchCases(pos) {
+		switchStmt.AddCase(c)
+	}
+	p.expect(token.RBRACE)
+	return switchStmt
+}
+
+// parseExprSwitch parses switch expressions.
+// Each case may declare variables, but must end with exactly one expression or return.
+// The last case must be the wildcard case.
+//
+//	switch <expr> {
+//	case <expr>: <block> <expr>
+//	case <annotation>: <block> <expr>
+//	case _: <block> <expr>
+//	}
+func (p *Parser) parseExprSwitch() *ast.ExprSwitch {
+	switchTok, _ := p.expect(token.SWITCH)
+	switchExpr := ast.MakeExprSwitch(switchTok, p.parseExpr())
+
+	p.expect(token.LBRACE)
+	for _, c := range p.parseSwitchCases(IN_SWITCH) {
+		switchExpr.AddCase(c)
+	}
+	closeTok, _ := p.expect(token.RBRACE)
+
+	p.checkSwitchExprCases(switchExpr.Cases, closeTok)
+	return switchExpr
+}
+
+// checkSwitchExprCases reports cases, which do not produce a value, and a missing wildcard case.
+// Trailing switch statements produce the value of their cases.
+func (p *Parser) checkSwitchExprCases(cases []*ast.SwitchCase, closeTok token.Token) {
+	for _, c := range cases {
+		if len(c.Block) == 0 {
+			p.errSwitchCaseWithoutExpr(c.Token)
+			continue
+		}
+		// returning cases never produce a value
+		switch last := c.Block[len(c.Block)-1].(type) {
+		case *ast.StmtExpr, *ast.StmtReturn:
+		case *ast.StmtSwitch:
+			p.checkSwitchExprCases(last.Cases, last.Token)
+		default:
+			p.errSwitchCaseWithoutExpr(last.TokenLiteral())
+		}
+	}
+	if len(cases) == 0 || !cases[len(cases)-1].IsWildcard() {
+		p.errSwitchWithoutWildcard(closeTok)
+	}
+}
+
+// parseSwitchCases parses all cases of a switch.
+// Case blocks are parsed at the given position.
+//
+//	case <expr>: <block>
+//	case <annotation>: <block>
+//	case _: <block>
+func (p *Parser) parseSwitchCases(pos StatementPosition) []*ast.SwitchCase {
+	var (
+		cases    []*ast.SwitchCase
+		wildcard bool
+	)
+	for p.curIs(token.CASE) {
+		caseTok, _ := p.expect(token.CASE)
+		switchCase := ast.MakeSwitchCase(caseTok)
+		if wildcard {
+			p.errUnreachableSwitchCase(caseTok)
+		}
+
+		switch {
+		case p.curIs(token.BLANK):
+			p.expect(token.BLANK)
+			wildcard = true
+		case p.curIs(token.AT):
+			switchCase.SetAnnotation(p.parseAnnotationInstance())
+		default:
+			switchCase.SetValue(p.parseExpr())
+		}
+		p.expect(token.COLON)
+
+		switchCase.SetBlock(p.parseStmtBlock(pos))
+		cases = append(cases, switchCase)
+	}
+	return cases
+}
+
+// parseExprType parses type expressions.
+// Each case names a case of the enum type and is followed by exactly one expression.
+// Cases are separated by commas, a trailing comma is allowed.
+//
+//	type <expr> = <expr> {
+//	  <identifier>: <expr>,
+//	}
+func (p *Parser) parseExprType() *ast.ExprTypeSwitch {
+	typeTok, _ := p.expect(token.TYPE)
+	subject := p.parseExpr()
+	p.expect(token.ASSIGN)
+	typeSwitch := ast.MakeExprTypeSwitch(subject, p.parseExpr(), typeTok)
+
+	p.expect(token.LBRACE)
+	for !p.curIs(token.RBRACE, token.EOF) {
+		caseTok, ok := p.expect(token.IDENT)
+		if !ok {
+			// skip the token to continue with the next case
+			p.nextToken()
+			continue
+		}
+		key := ast.MakeIdentifier(caseTok)
+		p.expect(token.COLON)
+		value := p.parseExpr()
+		if value == nil {
+			break
+		}
+		if _, ok := typeSwitch.Cases[key.Value]; ok {
+			p.errDuplicateTypeCase(caseTok)
+		} else {
+			typeSwitch.AddCase(key, value)
+		}
+
+		if !p.curIs(token.COMMA) {
+			break
+		}
+		p.expect(token.COMMA)
+	}
+	p.expect(token.RBRACE)
+	return typeSwitch
+}
+
 func (p *Parser) parseExprArgumentList() []ast.Expr {
 	var args []ast.Expr
 	for !p.curIs(token.RPAREN) {
@@ -714,13 +1048,25 @@ func (p *Parser) parseExpr() ast.Expr {
 	return expr
 }
 
-func (p *Parser) parseStmtBlock(_ StatementPosition) ast.Block {
+func (p *Parser) parseStmtBlock(pos StatementPosition) ast.Block {
 	block := make([]ast.Statement, 0)
 
-	for !p.curIs(token.RBRACE, token.RBRACKET, token.RPAREN) {
-		stmt, decls := p.parseAnnotatedStatementDeclaration(IN_FUNC)
+	// nested blocks stay within their loop or switch,
+	// all other blocks declare locals like function bodies
+	if pos != IN_FOR && pos != IN_SWITCH {
+		pos = IN_FUNC
+	}
+
+	// blocks of switch cases end with the next case
+	for !p.curIs(token.RBRACE, token.RBRACKET, token.RPAREN, token.CASE, token.EOF) {
+		stmt, decls := p.parseAnnotatedStatementDeclaration(pos)
 		if len(decls) > 0 {
-			p.errStatementMisplaced(IN_FUNC)
+			p.errStatementMisplaced(pos)
+		}
+		if stmt == nil {
+			// skip the token to continue with the next statement
+			p.nextToken()
+			continue
 		}
 		block = append(block, stmt)
 	}
@@ -740,7 +1086,10 @@ func (p *Parser) parseExprFunction() *ast.ExprFunc {
 	} else {
 		p.expect(token.RIGHT_ARROW)
 	}
+	p.inFunc++
 	fun.SetImplBlock(p.parseStmtBlock(IN_FUNC))
+	p.inFunc--
 	p.expect(token.RBRACE)
+	p.popSymbolTable()
 	return fun
 }
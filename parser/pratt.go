@@ -258,6 +258,18 @@ func (p *Parser) parsePrattExprFunc() ast.Expr {
 	return p.parseExprFunction()
 }
 
+func (p *Parser) parsePrattExprFor() ast.Expr {
+	return p.parseExprFor()
+}
+
+func (p *Parser) parsePrattExprSwitch() ast.Expr {
+	return p.parseExprSwitch()
+}
+
+func (p *Parser) parsePrattExprType() ast.Expr {
+	return p.parseExprType()
+}
+
 func (p *Parser) parsePrattExprCall(fn ast.Expr) ast.Expr {
 	fnExpr := ast.MakeExprInvocation(fn)
 	p.nextToken()
@@ -283,7 +295,7 @@ func (p *Parser) parsePrattExprCall(fn ast.Expr) ast.Expr {
 
 func (p *Parser) parsePrattExprMember(owner ast.Expr) ast.Expr {
 	dotTok := p.nextToken()
-	identTok, ok := p.expect(token.IDENT)
+	identTok, ok := p.expectName()
 	if !ok {
 		return nil
 	}
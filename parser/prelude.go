@@ -0,0 +1,28 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/vknabel/blush/ast"
+	"github.com/vknabel/blush/registry"
+)
+
+// ParsePrelude parses the prelude module, which is implicitly imported into other modules.
+// As the prelude is not part of the parsed program, all of its errors are joined.
+func ParsePrelude(module registry.ResolvedModule) (*ast.ContextModule, error) {
+	mp := NewModuleParse(module)
+	prelude, err := mp.Parse(module)
+	if err != nil {
+		return nil, err
+	}
+	diagnostics := append(mp.Errors(), mp.SymbolErrors()...)
+	if len(diagnostics) == 0 {
+		return prelude, nil
+	}
+	errs := make([]error, len(diagnostics))
+	for i, d := range diagnostics {
+		errs[i] = d
+	}
+	return nil, fmt.Errorf("invalid prelude %s: %w", module.URI(), errors.Join(errs...))
+}
@@ -4,6 +4,9 @@ import (
 	"testing"
 
 	"github.com/vknabel/blush/ast"
+	"github.com/vknabel/blush/lexer"
+	"github.com/vknabel/blush/parser"
+	"github.com/vknabel/blush/registry/staticmodule"
 )
 
 func TestParseStatementElseIf(t *testing.T) {
@@ -12,9 +15,9 @@ func TestParseStatementElseIf(t *testing.T) {
 		elseIfLen int
 		elseLen   int
 	}{
-		{"if true { return 1 } else if false { return 2 } else { return 3 }", 1, 1},
-		{"if true { return 1 } else if false { return 2 }", 1, 0},
-		{"if true { return 1 } else if false { return 2 } else if true { return 3 } else { return 4 }", 2, 1},
+		{"if true { 1 } else if false { 2 } else { 3 }", 1, 1},
+		{"if true { 1 } else if false { 2 }", 1, 0},
+		{"if true { 1 } else if false { 2 } else if true { 3 } else { 4 }", 2, 1},
 	}
 
 	for _, tt := range tests {
@@ -37,3 +40,270 @@ func TestParseStatementElseIf(t *testing.T) {
 		})
 	}
 }
+
+func TestParseStatementFor(t *testing.T) {
+	tests := []struct {
+		input      string
+		condition  bool
+		element    string
+		collection bool
+		blockLen   int
+	}{
+		{"for { break }", false, "", false, 1},
+		{"for true { break }", true, "", false, 1},
+		{"for x <- [1, 2] { x\n x }", false, "x", true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			srcFile := prepareSourceFileParsing(t, tt.input)
+
+			if len(srcFile.Statements) != 1 {
+				t.Fatalf("expected one statement, got %d", len(srcFile.Statements))
+			}
+			stmt, ok := srcFile.Statements[0].(*ast.StmtFor)
+			if !ok {
+				t.Fatalf("statement is %T, want *ast.StmtFor", srcFile.Statements[0])
+			}
+			if (stmt.Condition != nil) != tt.condition {
+				t.Errorf("expected condition %t, got %v", tt.condition, stmt.Condition)
+			}
+			if (stmt.Collection != nil) != tt.collection {
+				t.Errorf("expected collection %t, got %v", tt.collection, stmt.Collection)
+			}
+			if tt.element != "" && (stmt.Element == nil || stmt.Element.Name.Value != tt.element) {
+				t.Errorf("expected element %q, got %v", tt.element, stmt.Element)
+			}
+			if len(stmt.Block) != tt.blockLen {
+				t.Errorf("expected block with %d stmt, got %d", tt.blockLen, len(stmt.Block))
+			}
+		})
+	}
+}
+
+func TestParseStatementForScope(t *testing.T) {
+	srcFile := prepareSourceFileParsing(t, "for x <- [1] { let y = x }\nfor x <- [2] { let y = x }")
+
+	for _, name := range []string{"x", "y"} {
+		if sym, ok := srcFile.Symbols.Symbols[name]; ok {
+			t.Errorf("expected %s to be scoped to the loops, got %v", name, sym)
+		}
+	}
+	for _, stmt := range srcFile.Statements {
+		loop := stmt.(*ast.StmtFor)
+		if loop.Symbols == nil || loop.Symbols.Symbols["x"] == nil || loop.Symbols.Symbols["y"] == nil {
+			t.Errorf("expected loop to declare x and y, got %v", loop.Symbols)
+		}
+	}
+}
+
+func TestParseStatementBreakContinue(t *testing.T) {
+	tests := []struct {
+		input   string
+		summary string
+	}{
+		{"for { break }", ""},
+		{"for { continue }", ""},
+		{"for x <- xs { if x { break } else { continue } }", ""},
+		{"func f() { for { return 1 } }", ""},
+		{"break", "break must be inside loop"},
+		{"continue", "continue must be inside loop"},
+		{"if true { break }", "break must be inside loop"},
+		{"func f() { continue }", "continue must be inside loop"},
+		{"for { func f() { break } }", "break must be inside loop"},
+		{"for { { -> break } }", "break must be inside loop"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l, err := lexer.New(staticmodule.NewSourceString("testing:///test.blush", tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := parser.NewSourceParser(l, ast.MakeSymbolTable(nil, ast.Identifier{Value: "test"}), "test.blush")
+			p.ParseSourceFile()
+
+			if tt.summary == "" {
+				for _, err := range p.Errors() {
+					t.Errorf("unexpected error: %s", err.Summary)
+				}
+				return
+			}
+			if len(p.Errors()) == 0 || p.Errors()[0].Summary != tt.summary {
+				t.Errorf("expected error %q, got %v", tt.summary, p.Errors())
+			}
+		})
+	}
+}
+
+func TestParseStatementReturn(t *testing.T) {
+	tests := []struct {
+		input   string
+		summary string
+	}{
+		{"func f() { return 1 }", ""},
+		{"let f = { -> for { return 1 } }", ""},
+		{"func f(x) { switch x { case _: return 1 } }", ""},
+		{"return 1", "return must be inside function"},
+		{"if true { return 1 }", "return must be inside function"},
+		{"for { if true { return 3 } }", "return must be inside function"},
+		{"let y = switch 1 { case 1: return 5 case _: 7 }", "return must be inside function"},
+		{"switch 1 { case _: return 5 }", "return must be inside function"},
+		{"let f = { -> 1 }\nreturn 2", "return must be inside function"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l, err := lexer.New(staticmodule.NewSourceString("testing:///test.blush", tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := parser.NewSourceParser(l, ast.MakeSymbolTable(nil, ast.Identifier{Value: "test"}), "test.blush")
+			p.ParseSourceFile()
+
+			if tt.summary == "" {
+				for _, err := range p.Errors() {
+					t.Errorf("unexpected error: %s", err.Summary)
+				}
+				return
+			}
+			if len(p.Errors()) == 0 || p.Errors()[0].Summary != tt.summary {
+				t.Errorf("expected error %q, got %v", tt.summary, p.Errors())
+			}
+		})
+	}
+}
+
+func TestParseStatementAssign(t *testing.T) {
+	tests := []struct {
+		input   string
+		target  string
+		discard bool
+	}{
+		{"x = 2", "x", false},
+		{"_ = x", "", true},
+		{"person.name = 2", "person.name", false},
+		{"items[0] = 1", "(items[0])", false},
+		{"x = y = 2", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l, err := lexer.New(staticmodule.NewSourceString("testing:///test.blush", tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := parser.NewSourceParser(l, ast.MakeSymbolTable(nil, ast.Identifier{Value: "test"}), "test.blush")
+			srcFile := p.ParseSourceFile()
+
+			if tt.target == "" && !tt.discard {
+				if len(p.Errors()) == 0 {
+					t.Errorf("expected chained assignment to fail")
+				}
+				return
+			}
+			for _, err := range p.Errors() {
+				t.Errorf("unexpected error: %s", err.Summary)
+			}
+			assign, ok := srcFile.Statements[0].(*ast.StmtAssign)
+			if !ok {
+				t.Fatalf("expected *ast.StmtAssign, got %T", srcFile.Statements[0])
+			}
+			if assign.IsDiscard() != tt.discard {
+				t.Errorf("expected discard %v, got %v", tt.discard, assign.IsDiscard())
+			}
+			if !tt.discard && assign.Target.Expression() != tt.target {
+				t.Errorf("expected target %q, got %q", tt.target, assign.Target.Expression())
+			}
+		})
+	}
+}
+
+func TestParseStatementSwitch(t *testing.T) {
+	tests := []struct {
+		input     string
+		kinds     []string
+		blockLens []int
+	}{
+		{"switch x {}", nil, nil},
+		{"switch x { case 1: 1 case 2: case _: x\n x }", []string{"value", "value", "_"}, []int{1, 0, 2}},
+		{"switch x { case @String: 1 case @Has(Countable): 2 case [1][0]: 3 }", []string{"@String", "@Has", "value"}, []int{1, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			srcFile := prepareSourceFileParsing(t, tt.input)
+
+			if len(srcFile.Statements) != 1 {
+				t.Fatalf("expected one statement, got %d", len(srcFile.Statements))
+			}
+			stmt, ok := srcFile.Statements[0].(*ast.StmtSwitch)
+			if !ok {
+				t.Fatalf("statement is %T, want *ast.StmtSwitch", srcFile.Statements[0])
+			}
+			if len(stmt.Cases) != len(tt.kinds) {
+				t.Fatalf("expected %d cases, got %d", len(tt.kinds), len(stmt.Cases))
+			}
+			for i, c := range stmt.Cases {
+				var kind string
+				switch {
+				case c.IsWildcard():
+					kind = "_"
+				case c.Annotation != nil:
+					kind = "@" + c.Annotation.Reference.String()
+				default:
+					kind = "value"
+				}
+				if kind != tt.kinds[i] {
+					t.Errorf("case %d: expected %s, got %s", i, tt.kinds[i], kind)
+				}
+				if len(c.Block) != tt.blockLens[i] {
+					t.Errorf("case %d: expected block with %d stmt, got %d", i, tt.blockLens[i], len(c.Block))
+				}
+			}
+		})
+	}
+}
+
+func TestParseSwitchErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		summary string
+	}{
+		{"let a = switch x { case 1: let b = 2\n b case _: 3 }", ""},
+		{"for { switch x { case 1: break case _: continue } }", ""},
+		{"func f(x) { let a = switch x { case _: return 1 } }", ""},
+		{"switch x { case _: 1 case 2: 2 }", "unreachable case"},
+		{"let a = switch x { case 1: 2 }", "switch expression requires case _"},
+		{"let a = switch x { }", "switch expression requires case _"},
+		{"let a = switch x { case 1: case _: 2 }", "switch expression case must end with an expression"},
+		{"let a = switch x { case 1: let b = 2 case _: 2 }", "switch expression case must end with an expression"},
+		{"for { let a = switch x { case _: break } }", "break must be inside loop"},
+		{"let a = switch x { case _: switch x { case 1: 2 case _: 3 } }", ""},
+		{"let a = switch x { case _: switch x { case 1: 2 } }", "switch expression requires case _"},
+		{"let a = type x = Result { Ok: f, Err: g, }", ""},
+		{"let a = type x = Result { Ok: f, Ok: g }", "duplicate case"},
+		{"let a = type x = Result { Ok f }", `unexpected "f"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l, err := lexer.New(staticmodule.NewSourceString("testing:///test.blush", tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := parser.NewSourceParser(l, ast.MakeSymbolTable(nil, ast.Identifier{Value: "test"}), "test.blush")
+			p.ParseSourceFile()
+
+			if tt.summary == "" {
+				for _, err := range p.Errors() {
+					t.Errorf("unexpected error: %s", err.Summary)
+				}
+				return
+			}
+			if len(p.Errors()) == 0 || p.Errors()[0].Summary != tt.summary {
+				t.Errorf("expected error %q, got %v", tt.summary, p.Errors())
+			}
+		})
+	}
+}
@@ -46,16 +46,34 @@ func (p *Parser) parseStatementInContext(pos StatementPosition, annos ast.Annota
 		return p.parseStatementIf(pos), nil
 	case token.RETURN:
 		return p.parseStatementReturn(pos), nil
+	case token.FOR:
+		return p.parseStatementFor(pos), nil
+	case token.SWITCH:
+		return p.parseStatementSwitch(pos), nil
+	case token.BREAK:
+		return p.parseStatementBreak(pos), nil
+	case token.CONTINUE:
+		return p.parseStatementContinue(pos), nil
+	case token.BLANK:
+		if annos != nil {
+			p.errCannotBeAnnotated()
+		}
+		blankTok, _ := p.expect(token.BLANK)
+		return p.parseStatementAssign(blankTok, nil), nil
 	default:
 		if _, ok := p.prefixParsers[p.curToken.Type]; ok {
 			if annos != nil {
 				p.errCannotBeAnnotated()
 			}
-			return p.parseExprStmt(), nil
+			stmt := p.parseExprStmt()
+			if p.curIs(token.ASSIGN) {
+				return p.parseStatementAssign(stmt.Token, stmt.Expr), nil
+			}
+			return stmt, nil
 		}
 
 		prefixes := []token.TokenType{
-			token.ENUM, token.DATA, token.MODULE, token.EXTERN, token.FUNCTION, token.IMPORT, token.AT, token.LET, token.IF, token.FOR,
+			token.ENUM, token.DATA, token.MODULE, token.EXTERN, token.FUNCTION, token.IMPORT, token.AT, token.LET, token.IF, token.FOR, token.SWITCH, token.BREAK, token.CONTINUE, token.BLANK,
 		}
 		for t := range p.prefixParsers {
 			prefixes = append(prefixes, t)
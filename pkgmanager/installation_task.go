@@ -2,8 +2,12 @@ package pkgmanager
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path"
+	"strings"
 
+	"github.com/go-git/go-billy/v5"
 	"github.com/vknabel/blush/cavefile"
 	"github.com/vknabel/blush/registry"
 )
@@ -14,16 +18,48 @@ type InstallationTask struct {
 
 	completed []registry.ResolvedPackage
 	queue     []cavefile.Dependency
+	// the kind of dependency of each completed source
+	kinds map[string]cavefile.SourceKind
+
+	lockfs    billy.Filesystem
+	lockPath  string
+	updates   []string
+	updateAll bool
+}
+
+type InstallOption func(*InstallationTask)
+
+// WithLockfile installs the versions pinned by the lockfile at the given path.
+// After a successful run, all installed packages are written back to the lockfile.
+func WithLockfile(fs billy.Filesystem, path string) InstallOption {
+	return func(t *InstallationTask) {
+		t.lockfs = fs
+		t.lockPath = path
+	}
 }
 
-// TODO: Recursively install dependencies!
+// WithUpdate re-resolves the given sources regardless of their pins.
+// Without any sources, all dependencies are re-resolved.
+func WithUpdate(sources ...string) InstallOption {
+	return func(t *InstallationTask) {
+		t.updates = append(t.updates, sources...)
+		t.updateAll = len(sources) == 0
+	}
+}
+
+// Run installs all dependencies of the Cavefile and their transitive dependencies.
+// Exactly one version per source is selected, which satisfies all requirements.
 func (t *InstallationTask) Run(ctx context.Context) error {
 	if t.queue == nil {
 		t.queue = t.cave.Dependencies
 	}
+	pins, err := t.pins()
+	if err != nil {
+		return err
+	}
 	availables := make(map[string][]registry.ResolvedPackage, 0)
 
-	for _, reg := range t.pkgmanager.registries {
+	for _, reg := range t.pkgmanager.providers(cavefile.SourceGit) {
 		available, err := reg.Discover(ctx)
 		if err != nil {
 			return err
@@ -33,42 +69,112 @@ func (t *InstallationTask) Run(ctx context.Context) error {
 		}
 	}
 
-	for _, dependency := range t.queue {
-		if available, ok := availables[dependency.Source]; ok {
-			hasFound := false
-			for _, pkg := range available {
-				if pkg.Version().Matches(dependency.Predicate) {
-					t.completed = append(t.completed, pkg)
-					hasFound = true
-					break
-				}
+	solver := newSolver(t, availables, pins)
+	completed, err := solver.Solve(ctx, t.queue)
+	if err != nil {
+		return err
+	}
+	t.completed = append(t.completed, completed...)
+	t.kinds = solver.kinds
+	return t.writeLockfile(solver.kinds)
+}
+
+// Imports returns the completed packages by the import names declared for them.
+// The import names of the Cavefile take precedence over those of transitive dependencies.
+func (t *InstallationTask) Imports() (map[string]registry.ResolvedPackage, error) {
+	bySource := make(map[string]registry.ResolvedPackage, len(t.completed))
+	for _, pkg := range t.completed {
+		bySource[pkg.Source()] = pkg
+	}
+	imports := make(map[string]registry.ResolvedPackage)
+	declare := func(dependent string, deps []cavefile.Dependency) {
+		for _, dep := range deps {
+			source := dep.Source
+			if dep.Kind == cavefile.SourceLocal {
+				// local dependencies are relative to the Cavefile declaring them
+				source = path.Join(dependent, dep.Source)
 			}
-			if hasFound {
-				continue
+			pkg, ok := bySource[source]
+			if _, taken := imports[dep.ImportName]; ok && !taken {
+				imports[dep.ImportName] = pkg
 			}
 		}
+	}
+	declare("", t.cave.Dependencies)
+	for _, pkg := range t.completed {
+		cave, err := loadCavefile(pkg)
+		if err != nil {
+			return nil, err
+		}
+		declare(pkg.Source(), cave.Dependencies)
+	}
+	return imports, nil
+}
 
-		hasFound := false
-		for _, reg := range t.pkgmanager.registries {
-			pkgs, err := reg.DiscoverPackageVersions(ctx, dependency.Source, dependency.Predicate)
-			if err != nil {
-				return err
-			}
-			if len(pkgs) == 0 {
-				continue
-			}
-			pkg := pkgs[0]
-			localPkg, err := pkg.Resolve(ctx)
-			if err != nil {
-				return err
-			}
-			t.completed = append(t.completed, localPkg)
-			hasFound = true
-			break
+// pins returns the locked packages, which shall not be updated.
+func (t *InstallationTask) pins() (map[string]LockedPackage, error) {
+	pins := make(map[string]LockedPackage)
+	if t.lockfs == nil || t.updateAll {
+		return pins, nil
+	}
+	lock, err := ReadLockfile(t.lockfs, t.lockPath)
+	if err != nil || lock == nil {
+		return pins, err
+	}
+	for _, pkg := range lock.Packages {
+		pins[pkg.Source] = pkg
+	}
+	for _, source := range t.updates {
+		delete(pins, source)
+	}
+	return pins, nil
+}
+
+// writeLockfile pins all completed packages.
+// Local packages are not pinned as they may change at any time.
+// The standard library is versioned with the toolchain instead.
+func (t *InstallationTask) writeLockfile(kinds map[string]cavefile.SourceKind) error {
+	if t.lockfs == nil {
+		return nil
+	}
+	var lock Lockfile
+	for _, pkg := range t.completed {
+		if kind := kinds[pkg.Source()]; kind == cavefile.SourceLocal || kind == cavefile.SourceStdlib {
+			continue
 		}
-		if !hasFound {
-			return fmt.Errorf("no registry can provide package %s", dependency.Source)
+		locked, err := lockPackage(pkg)
+		if err != nil {
+			return err
 		}
+		lock.Packages = append(lock.Packages, locked)
+	}
+	return WriteLockfile(t.lockfs, t.lockPath, lock)
+}
+
+// loadCavefile parses the Cavefile of the package, if any.
+func loadCavefile(pkg registry.ResolvedPackage) (cavefile.Cavefile, error) {
+	src, err := pkg.Cavefile()
+	if err != nil || src == nil {
+		return cavefile.Cavefile{}, err
+	}
+	cave, diagnostics, err := cavefile.Load(src)
+	if err != nil {
+		return cavefile.Cavefile{}, err
+	}
+	if len(diagnostics) > 0 {
+		errs := make([]error, len(diagnostics))
+		for i, d := range diagnostics {
+			errs[i] = d
+		}
+		return cavefile.Cavefile{}, fmt.Errorf("invalid Cavefile of %s: %w", pkg.Source(), errors.Join(errs...))
+	}
+	return cave, nil
+}
+
+// requiredBy describes the dependency path for error messages.
+func requiredBy(path []string) string {
+	if len(path) == 0 {
+		return ""
 	}
-	return nil
+	return ", required by " + strings.Join(path, " -> ")
 }
@@ -3,11 +3,13 @@ package pkgmanager
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
 	"testing"
 
 	"github.com/vknabel/blush/cavefile"
 	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/staticmodule"
 	"github.com/vknabel/blush/version"
 )
 
@@ -37,6 +39,7 @@ type stubResolvedPackage struct {
 	resolveErr          error
 	resolveModulesErr   error
 	resolvedModulesResp []registry.ResolvedModule
+	manifest            registry.Source
 }
 
 func (p *stubResolvedPackage) Source() string {
@@ -61,6 +64,10 @@ func (p *stubResolvedPackage) ResolveModules() ([]registry.ResolvedModule, error
 	return p.resolvedModulesResp, nil
 }
 
+func (p *stubResolvedPackage) Cavefile() (registry.Source, error) {
+	return p.manifest, nil
+}
+
 type stubPackage struct {
 	source     string
 	version    version.Version
@@ -108,7 +115,7 @@ func TestInstallationTaskRun(t *testing.T) {
 			cave: cavefile.Cavefile{Dependencies: []cavefile.Dependency{{
 				ImportName: "pkg",
 				Source:     "local/pkg",
-				Predicate:  predicateExactOne,
+				Constraint: version.ConstraintOf(predicateExactOne),
 			}}},
 			provider: &stubProvider{
 				discoverFn: func(context.Context) ([]registry.ResolvedPackage, error) {
@@ -119,7 +126,7 @@ func TestInstallationTaskRun(t *testing.T) {
 			wantQueue: []cavefile.Dependency{{
 				ImportName: "pkg",
 				Source:     "local/pkg",
-				Predicate:  predicateExactOne,
+				Constraint: version.ConstraintOf(predicateExactOne),
 			}},
 		},
 		{
@@ -127,12 +134,12 @@ func TestInstallationTaskRun(t *testing.T) {
 			cave: cavefile.Cavefile{Dependencies: []cavefile.Dependency{{
 				ImportName: "pkg",
 				Source:     "remote/pkg",
-				Predicate:  predicateExactOne,
+				Constraint: version.ConstraintOf(predicateExactOne),
 			}}},
 			initialQueue: []cavefile.Dependency{{
 				ImportName: "pkg",
 				Source:     "remote/pkg",
-				Predicate:  predicateExactOne,
+				Constraint: version.ConstraintOf(predicateExactOne),
 			}},
 			provider: &stubProvider{
 				discoverFn: func(context.Context) ([]registry.ResolvedPackage, error) {
@@ -159,7 +166,7 @@ func TestInstallationTaskRun(t *testing.T) {
 			wantQueue: []cavefile.Dependency{{
 				ImportName: "pkg",
 				Source:     "remote/pkg",
-				Predicate:  predicateExactOne,
+				Constraint: version.ConstraintOf(predicateExactOne),
 			}},
 		},
 		{
@@ -167,7 +174,7 @@ func TestInstallationTaskRun(t *testing.T) {
 			cave: cavefile.Cavefile{Dependencies: []cavefile.Dependency{{
 				ImportName: "pkg",
 				Source:     "local/pkg",
-				Predicate:  predicateExactOne,
+				Constraint: version.ConstraintOf(predicateExactOne),
 			}}},
 			provider: &stubProvider{
 				discoverFn: func(context.Context) ([]registry.ResolvedPackage, error) {
@@ -177,7 +184,7 @@ func TestInstallationTaskRun(t *testing.T) {
 			wantQueue: []cavefile.Dependency{{
 				ImportName: "pkg",
 				Source:     "local/pkg",
-				Predicate:  predicateExactOne,
+				Constraint: version.ConstraintOf(predicateExactOne),
 			}},
 			wantErr: errDiscover,
 		},
@@ -186,7 +193,7 @@ func TestInstallationTaskRun(t *testing.T) {
 			cave: cavefile.Cavefile{Dependencies: []cavefile.Dependency{{
 				ImportName: "pkg",
 				Source:     "remote/pkg",
-				Predicate:  predicateExactOne,
+				Constraint: version.ConstraintOf(predicateExactOne),
 			}}},
 			provider: &stubProvider{
 				discoverFn: func(context.Context) ([]registry.ResolvedPackage, error) {
@@ -199,7 +206,7 @@ func TestInstallationTaskRun(t *testing.T) {
 			wantQueue: []cavefile.Dependency{{
 				ImportName: "pkg",
 				Source:     "remote/pkg",
-				Predicate:  predicateExactOne,
+				Constraint: version.ConstraintOf(predicateExactOne),
 			}},
 			wantErr: errDiscoverVersions,
 		},
@@ -208,7 +215,7 @@ func TestInstallationTaskRun(t *testing.T) {
 			cave: cavefile.Cavefile{Dependencies: []cavefile.Dependency{{
 				ImportName: "pkg",
 				Source:     "remote/pkg",
-				Predicate:  predicateExactOne,
+				Constraint: version.ConstraintOf(predicateExactOne),
 			}}},
 			provider: &stubProvider{
 				discoverFn: func(context.Context) ([]registry.ResolvedPackage, error) {
@@ -226,7 +233,7 @@ func TestInstallationTaskRun(t *testing.T) {
 			wantQueue: []cavefile.Dependency{{
 				ImportName: "pkg",
 				Source:     "remote/pkg",
-				Predicate:  predicateExactOne,
+				Constraint: version.ConstraintOf(predicateExactOne),
 			}},
 			wantErr: errResolve,
 		},
@@ -235,7 +242,7 @@ func TestInstallationTaskRun(t *testing.T) {
 			cave: cavefile.Cavefile{Dependencies: []cavefile.Dependency{{
 				ImportName: "pkg",
 				Source:     "missing/pkg",
-				Predicate:  predicateExactOne,
+				Constraint: version.ConstraintOf(predicateExactOne),
 			}}},
 			provider: &stubProvider{
 				discoverFn: func(context.Context) ([]registry.ResolvedPackage, error) {
@@ -248,7 +255,7 @@ func TestInstallationTaskRun(t *testing.T) {
 			wantQueue: []cavefile.Dependency{{
 				ImportName: "pkg",
 				Source:     "missing/pkg",
-				Predicate:  predicateExactOne,
+				Constraint: version.ConstraintOf(predicateExactOne),
 			}},
 			wantErr: errors.New("no registry can provide package missing/pkg"),
 		},
@@ -295,3 +302,228 @@ func TestInstallationTaskRun(t *testing.T) {
 		})
 	}
 }
+
+// graphProvider provides packages with Cavefiles declaring their dependencies.
+// Dependencies are looked up by source and version like a@1.0.0 or by source only.
+type graphProvider struct {
+	versions map[string][]string
+	deps     map[string]string
+}
+
+func (g graphProvider) Discover(ctx context.Context) ([]registry.ResolvedPackage, error) {
+	return nil, nil
+}
+
+func (g graphProvider) DiscoverPackageVersions(ctx context.Context, name string, preds ...version.Predicate) ([]registry.Package, error) {
+	var pkgs []registry.Package
+	for _, v := range g.versions[name] {
+		pkg := &stubResolvedPackage{source: name, version: version.Parse(v)}
+		fields, ok := g.deps[name+"@"+v]
+		if !ok {
+			fields, ok = g.deps[name]
+		}
+		if ok {
+			pkg.manifest = staticmodule.NewSourceString(registry.LogicalURI(name+"/Cavefile"), "import cave\n@cave.Dependencies()\ndata Deps {\n"+fields+"\n}\n")
+		}
+		matches := true
+		for _, pred := range preds {
+			matches = matches && pkg.version.Matches(pred)
+		}
+		if matches {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs, nil
+}
+
+func TestInstallationTaskRunTransitive(t *testing.T) {
+	tests := []struct {
+		name    string
+		deps    []string
+		graph   graphProvider
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "installs transitive dependencies",
+			deps: []string{"a"},
+			graph: graphProvider{
+				versions: map[string][]string{"a": {"1.0.0"}, "b": {"1.0.0"}, "c": {"1.0.0"}},
+				deps: map[string]string{
+					"a": "@cave.Git(\"b\")\nb",
+					"b": "@cave.Git(\"c\")\nc",
+				},
+			},
+			want: []string{"a@1.0.0", "b@1.0.0", "c@1.0.0"},
+		},
+		{
+			name: "deduplicates by source",
+			deps: []string{"a", "b"},
+			graph: graphProvider{
+				versions: map[string][]string{"a": {"1.0.0"}, "b": {"1.0.0"}, "c": {"1.0.0"}},
+				deps: map[string]string{
+					"a": "@cave.Git(\"c\")\nc",
+					"b": "@cave.Git(\"c\")\n@cave.Version(\"^1.0.0\")\nc",
+				},
+			},
+			want: []string{"a@1.0.0", "c@1.0.0", "b@1.0.0"},
+		},
+		{
+			name: "detects cycles",
+			deps: []string{"a"},
+			graph: graphProvider{
+				versions: map[string][]string{"a": {"1.0.0"}, "b": {"1.0.0"}},
+				deps: map[string]string{
+					"a": "@cave.Git(\"b\")\nb",
+					"b": "@cave.Git(\"a\")\na",
+				},
+			},
+			wantErr: "dependency cycle a -> b -> a",
+		},
+		{
+			name: "reports the path of missing dependencies",
+			deps: []string{"a"},
+			graph: graphProvider{
+				versions: map[string][]string{"a": {"1.0.0"}, "b": {"1.0.0"}},
+				deps: map[string]string{
+					"a": "@cave.Git(\"b\")\nb",
+					"b": "@cave.Git(\"missing\")\nmissing",
+				},
+			},
+			wantErr: "no registry can provide package missing, required by a -> b",
+		},
+		{
+			name: "explains conflicting versions",
+			deps: []string{"a", "b"},
+			graph: graphProvider{
+				versions: map[string][]string{"a": {"1.0.0"}, "b": {"1.0.0"}, "c": {"1.0.0", "2.0.0"}},
+				deps: map[string]string{
+					"a": "@cave.Git(\"c\")\n@cave.Version(\"1.0.0\")\nc",
+					"b": "@cave.Git(\"c\")\n@cave.Version(\"2.0.0\")\nc",
+				},
+			},
+			wantErr: "no version of c satisfies all requirements:\n  a requires c ==1.0.0\n  b requires c ==2.0.0",
+		},
+		{
+			name: "selects a version satisfying all requirements",
+			deps: []string{"a", "b"},
+			graph: graphProvider{
+				versions: map[string][]string{"a": {"1.0.0"}, "b": {"1.0.0"}, "c": {"1.2.0", "1.3.0", "1.3.5", "1.4.0", "2.0.0"}},
+				deps: map[string]string{
+					"a": "@cave.Git(\"c\")\n@cave.Version(\"^1.2.0\")\nc",
+					"b": "@cave.Git(\"c\")\n@cave.Version(\"~1.3.1\")\nc",
+				},
+			},
+			want: []string{"a@1.0.0", "c@1.3.5", "b@1.0.0"},
+		},
+		{
+			name: "selects a version satisfying compound constraints",
+			deps: []string{"a", "b"},
+			graph: graphProvider{
+				versions: map[string][]string{"a": {"1.0.0"}, "b": {"1.0.0"}, "c": {"1.2.0", "1.9.0", "2.1.0", "3.0.0-beta", "3.1.0"}},
+				deps: map[string]string{
+					"a": "@cave.Git(\"c\")\n@cave.Version(\"^1.2 || ^2.0\")\nc",
+					"b": "@cave.Git(\"c\")\n@cave.Version(\">=1.5.0 <3.0.0\")\nc",
+				},
+			},
+			want: []string{"a@1.0.0", "c@2.1.0", "b@1.0.0"},
+		},
+		{
+			name: "backtracks to older versions of dependents",
+			deps: []string{"a", "d"},
+			graph: graphProvider{
+				versions: map[string][]string{"a": {"1.0.0", "2.0.0"}, "c": {"1.0.0", "2.0.0"}, "d": {"1.0.0"}},
+				deps: map[string]string{
+					"a@1.0.0": "@cave.Git(\"c\")\n@cave.Version(\"^1.0.0\")\nc",
+					"a@2.0.0": "@cave.Git(\"c\")\n@cave.Version(\"^2.0.0\")\nc",
+					"d":       "@cave.Git(\"c\")\n@cave.Version(\"1.0.0\")\nc",
+				},
+			},
+			want: []string{"a@1.0.0", "c@1.0.0", "d@1.0.0"},
+		},
+		{
+			name: "explains transitive conflicts",
+			deps: []string{"a", "d"},
+			graph: graphProvider{
+				versions: map[string][]string{"a": {"1.0.0"}, "b": {"1.0.0"}, "c": {"1.0.0", "2.0.0"}, "d": {"1.0.0"}},
+				deps: map[string]string{
+					"a": "@cave.Git(\"b\")\nb",
+					"b": "@cave.Git(\"c\")\n@cave.Version(\"^1.0.0\")\nc",
+					"d": "@cave.Git(\"c\")\n@cave.Version(\"2.0.0\")\nc",
+				},
+			},
+			wantErr: "no version of c satisfies all requirements:\n  a -> b requires c ^1.0.0\n  d requires c ==2.0.0",
+		},
+		{
+			name: "reports invalid Cavefiles",
+			deps: []string{"a"},
+			graph: graphProvider{
+				versions: map[string][]string{"a": {"1.0.0"}},
+				deps:     map[string]string{"a": "b"},
+			},
+			wantErr: "invalid Cavefile of a: syntax error: dependency b, requires one of @cave.Git, @cave.Local or @cave.Stdlib",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deps []cavefile.Dependency
+			for _, source := range tt.deps {
+				deps = append(deps, cavefile.Dependency{ImportName: source, Source: source, Constraint: cavefile.AnyVersion})
+			}
+			task := &InstallationTask{
+				cave:       cavefile.Cavefile{Dependencies: deps},
+				pkgmanager: &PackageManager{registries: []registry.Provider{tt.graph}},
+			}
+
+			err := task.Run(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, pkg := range task.completed {
+				got = append(got, pkg.Source()+"@"+pkg.Version().String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("completed packages mismatch: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// tamperedPackage fails its integrity check.
+type tamperedPackage struct {
+	stubResolvedPackage
+}
+
+func (p *tamperedPackage) Verify() error {
+	return fmt.Errorf("%w: tampered", registry.ErrIntegrity)
+}
+
+func TestInstallationTaskRunVerifiesIntegrity(t *testing.T) {
+	tampered := &tamperedPackage{stubResolvedPackage{source: "a", version: version.Parse("1.0.0")}}
+	provider := &stubProvider{
+		discoverFn: func(context.Context) ([]registry.ResolvedPackage, error) {
+			return []registry.ResolvedPackage{tampered}, nil
+		},
+	}
+	task := &InstallationTask{
+		cave: cavefile.Cavefile{Dependencies: []cavefile.Dependency{
+			{ImportName: "a", Source: "a", Constraint: cavefile.AnyVersion},
+		}},
+		pkgmanager: &PackageManager{registries: []registry.Provider{provider}},
+	}
+	err := task.Run(context.Background())
+	if !errors.Is(err, registry.ErrIntegrity) {
+		t.Fatalf("expected ErrIntegrity, got %v", err)
+	}
+	if len(task.completed) != 0 {
+		t.Errorf("expected no completed packages, got %v", task.completed)
+	}
+}
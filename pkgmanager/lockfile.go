@@ -0,0 +1,128 @@
+package pkgmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/go-git/go-billy/v5"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/world"
+)
+
+// LockfileName is the name of the lockfile next to the Cavefile.
+const LockfileName = "Cavefile.lock"
+
+const lockfileVersion = 1
+
+// Lockfile pins the resolved version of every installed package.
+type Lockfile struct {
+	Version  int             `json:"version"`
+	Packages []LockedPackage `json:"packages"`
+}
+
+// LockedPackage is a pinned package.
+type LockedPackage struct {
+	Source  string `json:"source"`
+	Version string `json:"version"`
+	// The commit of packages from version control, if any.
+	Commit string `json:"commit,omitempty"`
+	// The content hash of the package sources and its Cavefile.
+	Hash string `json:"hash"`
+}
+
+// Lookup returns the pin of the given source.
+func (l Lockfile) Lookup(source string) (LockedPackage, bool) {
+	for _, pkg := range l.Packages {
+		if pkg.Source == source {
+			return pkg, true
+		}
+	}
+	return LockedPackage{}, false
+}
+
+// ParseLockfile decodes a lockfile.
+func ParseLockfile(data []byte) (Lockfile, error) {
+	var lock Lockfile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return Lockfile{}, fmt.Errorf("invalid %s, %w", LockfileName, err)
+	}
+	if lock.Version != lockfileVersion {
+		return Lockfile{}, fmt.Errorf("unsupported %s version %d", LockfileName, lock.Version)
+	}
+	return lock, nil
+}
+
+// Marshal encodes the lockfile with packages sorted by source.
+func (l Lockfile) Marshal() ([]byte, error) {
+	l.Version = lockfileVersion
+	l.Packages = append([]LockedPackage(nil), l.Packages...)
+	sort.Slice(l.Packages, func(i, j int) bool {
+		return l.Packages[i].Source < l.Packages[j].Source
+	})
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// ReadLockfile reads the lockfile at the given path.
+// Returns nil if there is no lockfile.
+func ReadLockfile(fs billy.Filesystem, path string) (*Lockfile, error) {
+	data, err := billyutil.ReadFile(fs, path)
+	if errors.Is(err, world.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lock, err := ParseLockfile(data)
+	if err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+// WriteLockfile writes the lockfile to the given path.
+func WriteLockfile(fs billy.Filesystem, path string, lock Lockfile) error {
+	data, err := lock.Marshal()
+	if err != nil {
+		return err
+	}
+	return billyutil.WriteFile(fs, path, data, 0o644)
+}
+
+// lockPackage pins the version, commit and contents of the package.
+func lockPackage(pkg registry.ResolvedPackage) (LockedPackage, error) {
+	hash, err := registry.ContentHash(pkg)
+	if err != nil {
+		return LockedPackage{}, err
+	}
+	locked := LockedPackage{
+		Source:  pkg.Source(),
+		Version: pkg.Version().String(),
+		Hash:    hash,
+	}
+	if committed, ok := pkg.(registry.CommittedPackage); ok {
+		locked.Commit = committed.Commit()
+	}
+	return locked, nil
+}
+
+// verify fails if the package differs from its pin.
+func (l LockedPackage) verify(pkg registry.ResolvedPackage) error {
+	locked, err := lockPackage(pkg)
+	if err != nil {
+		return err
+	}
+	if l.Commit != "" && locked.Commit != l.Commit {
+		return fmt.Errorf("package %s %s resolved to commit %s, but %s locks %s", l.Source, l.Version, locked.Commit, LockfileName, l.Commit)
+	}
+	if locked.Hash != l.Hash {
+		return fmt.Errorf("package %s %s has content hash %s, but %s locks %s", l.Source, l.Version, locked.Hash, LockfileName, l.Hash)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package pkgmanager
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/vknabel/blush/cavefile"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/version"
+)
+
+type stubCommittedPackage struct {
+	*stubResolvedPackage
+	commit string
+}
+
+func (p *stubCommittedPackage) Resolve(ctx context.Context) (registry.ResolvedPackage, error) {
+	return p, nil
+}
+
+func (p *stubCommittedPackage) Commit() string {
+	return p.commit
+}
+
+func TestLockfileMarshal(t *testing.T) {
+	lock := Lockfile{Packages: []LockedPackage{
+		{Source: "b", Version: "1.0.0", Hash: "sha256:b"},
+		{Source: "a", Version: "main", Commit: "abc", Hash: "sha256:a"},
+	}}
+	data, err := lock.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "version": 1,
+  "packages": [
+    {
+      "source": "a",
+      "version": "main",
+      "commit": "abc",
+      "hash": "sha256:a"
+    },
+    {
+      "source": "b",
+      "version": "1.0.0",
+      "hash": "sha256:b"
+    }
+  ]
+}
+`
+	if string(data) != want {
+		t.Errorf("unexpected lockfile:\n%s", data)
+	}
+
+	parsed, err := ParseLockfile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg, ok := parsed.Lookup("a"); !ok || pkg.Commit != "abc" {
+		t.Errorf("expected to lookup a, got %+v", pkg)
+	}
+
+	if _, err := ParseLockfile([]byte(`{"version": 2}`)); err == nil || err.Error() != "unsupported Cavefile.lock version 2" {
+		t.Errorf("expected unsupported version, got %v", err)
+	}
+}
+
+func TestInstallationTaskLockfile(t *testing.T) {
+	graph := graphProvider{
+		versions: map[string][]string{"a": {"1.0.0"}, "c": {"1.0.0", "2.0.0"}},
+		deps:     map[string]string{"a": "@cave.Git(\"c\")\nc"},
+	}
+	pm := &PackageManager{registries: []registry.Provider{graph}}
+	cave := cavefile.Cavefile{Dependencies: []cavefile.Dependency{
+		{ImportName: "a", Source: "a", Constraint: cavefile.AnyVersion},
+	}}
+	fs := memfs.New()
+	install := func(opts ...InstallOption) ([]string, error) {
+		task := pm.Install(cave, append([]InstallOption{WithLockfile(fs, LockfileName)}, opts...)...)
+		err := task.Run(context.Background())
+		var got []string
+		for _, pkg := range task.completed {
+			got = append(got, pkg.Source()+"@"+pkg.Version().String())
+		}
+		return got, err
+	}
+
+	got, err := install()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a@1.0.0", "c@2.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	lock, err := ReadLockfile(fs, LockfileName)
+	if err != nil || lock == nil {
+		t.Fatalf("expected lockfile, got %v", err)
+	}
+	if pkg, ok := lock.Lookup("c"); !ok || pkg.Version != "2.0.0" || !strings.HasPrefix(pkg.Hash, "sha256:") {
+		t.Fatalf("expected c to be locked, got %+v", lock)
+	}
+
+	// pin an older version
+	c1, err := registry.ContentHash(&stubResolvedPackage{source: "c", version: version.Parse("1.0.0")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.Packages[1] = LockedPackage{Source: "c", Version: "1.0.0", Hash: c1}
+	if err := WriteLockfile(fs, LockfileName, *lock); err != nil {
+		t.Fatal(err)
+	}
+	got, err = install()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a@1.0.0", "c@1.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected pinned versions %v, got %v", want, got)
+	}
+
+	got, err = install(WithUpdate("c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a@1.0.0", "c@2.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected updated versions %v, got %v", want, got)
+	}
+	lock, err = ReadLockfile(fs, LockfileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg, _ := lock.Lookup("c"); pkg.Version != "2.0.0" {
+		t.Fatalf("expected updated lockfile, got %+v", lock)
+	}
+
+	// tampered contents
+	lock.Packages[1].Hash = "sha256:tampered"
+	if err := WriteLockfile(fs, LockfileName, *lock); err != nil {
+		t.Fatal(err)
+	}
+	_, err = install()
+	if err == nil || !strings.Contains(err.Error(), "package c 2.0.0 has content hash sha256:") {
+		t.Fatalf("expected content hash mismatch, got %v", err)
+	}
+	if _, err = install(WithUpdate()); err != nil {
+		t.Fatalf("expected update of all packages to ignore the lockfile, got %v", err)
+	}
+}
+
+func TestInstallationTaskLockfileMissingPin(t *testing.T) {
+	graph := graphProvider{
+		versions: map[string][]string{"a": {"1.0.0", "2.0.0"}},
+	}
+	pm := &PackageManager{registries: []registry.Provider{graph}}
+	cave := cavefile.Cavefile{Dependencies: []cavefile.Dependency{
+		{ImportName: "a", Source: "a", Constraint: cavefile.AnyVersion},
+	}}
+	fs := memfs.New()
+	lock := Lockfile{Packages: []LockedPackage{{Source: "a", Version: "1.5.0", Hash: "sha256:a"}}}
+	if err := WriteLockfile(fs, LockfileName, lock); err != nil {
+		t.Fatal(err)
+	}
+
+	err := pm.Install(cave, WithLockfile(fs, LockfileName)).Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "locked version 1.5.0 of a is not available anymore") {
+		t.Fatalf("expected missing pin error, got %v", err)
+	}
+
+	task := pm.Install(cave, WithLockfile(fs, LockfileName), WithUpdate("a"))
+	if err := task.Run(context.Background()); err != nil {
+		t.Fatalf("expected update to ignore the missing pin, got %v", err)
+	}
+	if len(task.completed) != 1 || task.completed[0].Version().String() != "2.0.0" {
+		t.Fatalf("expected a@2.0.0, got %v", task.completed)
+	}
+}
+
+func TestInstallationTaskLockfileCommit(t *testing.T) {
+	pkg := &stubCommittedPackage{
+		stubResolvedPackage: &stubResolvedPackage{source: "a", version: version.Parse("1.0.0")},
+		commit:              "moved",
+	}
+	hash, err := registry.ContentHash(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := memfs.New()
+	err = WriteLockfile(fs, LockfileName, Lockfile{Packages: []LockedPackage{
+		{Source: "a", Version: "1.0.0", Commit: "original", Hash: hash},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &stubProvider{
+		discoverFn: func(context.Context) ([]registry.ResolvedPackage, error) {
+			return []registry.ResolvedPackage{pkg}, nil
+		},
+	}
+	pm := &PackageManager{registries: []registry.Provider{provider}}
+	cave := cavefile.Cavefile{Dependencies: []cavefile.Dependency{
+		{ImportName: "a", Source: "a", Constraint: cavefile.AnyVersion},
+	}}
+	err = pm.Install(cave, WithLockfile(fs, LockfileName)).Run(context.Background())
+	want := "package a 1.0.0 resolved to commit moved, but Cavefile.lock locks original"
+	if err == nil || err.Error() != want {
+		t.Fatalf("expected %q, got %v", want, err)
+	}
+}
@@ -5,27 +5,92 @@ import (
 	"github.com/vknabel/blush/cavefile"
 	"github.com/vknabel/blush/registry"
 	"github.com/vknabel/blush/registry/gitreg"
+	"github.com/vknabel/blush/registry/localreg"
+	"github.com/vknabel/blush/registry/stdlibreg"
+	"github.com/vknabel/blush/registry/vendorreg"
 )
 
 type PackageManager struct {
 	registries []registry.Provider
+	// local serves @cave.Local dependencies
+	local registry.Provider
+	// stdlib serves @cave.Stdlib dependencies
+	stdlib registry.Provider
+	// options of the git registry serving all other dependencies
+	gitOptions []gitreg.Option
 }
 
-func New(fs billy.Filesystem) (*PackageManager, error) {
+type Option func(*PackageManager)
+
+func New(fs billy.Filesystem, opts ...Option) (*PackageManager, error) {
 	gitregfs, err := fs.Chroot("git")
 	if err != nil {
 		return nil, err
 	}
-	return &PackageManager{
-		registries: []registry.Provider{
-			gitreg.New(gitregfs),
-		},
-	}, nil
+	pm := &PackageManager{
+		stdlib: stdlibreg.New(),
+	}
+	for _, opt := range opts {
+		opt(pm)
+	}
+	pm.registries = append(pm.registries, gitreg.New(gitregfs, pm.gitOptions...))
+	return pm, nil
+}
+
+// WithProject resolves @cave.Local dependencies relative to the project root within the file system.
+func WithProject(fs billy.Filesystem, root string) Option {
+	return func(pm *PackageManager) {
+		pm.local = localreg.New(fs, root)
+	}
 }
 
-func (pm *PackageManager) Install(cf cavefile.Cavefile) *InstallationTask {
-	return &InstallationTask{
+// WithVendored prefers the packages vendored into the directory over all other registries.
+func WithVendored(fs billy.Filesystem, dir string) Option {
+	return func(pm *PackageManager) {
+		pm.registries = append([]registry.Provider{vendorreg.New(fs, dir)}, pm.registries...)
+	}
+}
+
+// WithOffline only serves vendored and previously installed packages and never contacts remotes.
+func WithOffline() Option {
+	return func(pm *PackageManager) {
+		pm.gitOptions = append(pm.gitOptions, gitreg.WithOffline(true))
+	}
+}
+
+// WithStdlib serves @cave.Stdlib dependencies from the given registry instead of the embedded standard library.
+func WithStdlib(stdlib registry.Provider) Option {
+	return func(pm *PackageManager) {
+		pm.stdlib = stdlib
+	}
+}
+
+// providers returns the registries for the kind of dependency.
+func (pm *PackageManager) providers(kind cavefile.SourceKind) []registry.Provider {
+	switch kind {
+	case cavefile.SourceLocal:
+		if pm.local == nil {
+			return nil
+		}
+		return []registry.Provider{pm.local}
+	case cavefile.SourceStdlib:
+		if pm.stdlib == nil {
+			return nil
+		}
+		return []registry.Provider{pm.stdlib}
+	default:
+		return pm.registries
+	}
+}
+
+// Install creates a task installing all dependencies of the Cavefile.
+func (pm *PackageManager) Install(cf cavefile.Cavefile, opts ...InstallOption) *InstallationTask {
+	task := &InstallationTask{
 		pkgmanager: pm,
 		cave:       cf,
 	}
+	for _, opt := range opts {
+		opt(task)
+	}
+	return task
 }
@@ -2,8 +2,11 @@ package pkgmanager
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
+	"github.com/go-git/go-billy/v5/memfs"
+	billyutil "github.com/go-git/go-billy/v5/util"
 	"github.com/vknabel/blush/cavefile"
 	"github.com/vknabel/blush/registry"
 	"github.com/vknabel/blush/version"
@@ -32,10 +35,11 @@ func (p mockResolvedPackage) Resolve(ctx context.Context) (registry.ResolvedPack
 	return p, nil
 }
 func (p mockResolvedPackage) ResolveModules() ([]registry.ResolvedModule, error) { return nil, nil }
+func (p mockResolvedPackage) Cavefile() (registry.Source, error)                 { return nil, nil }
 
 func TestPkgManagerInstallationTaskRun(t *testing.T) {
 	ver := version.SemverVersion{Major: 1, Minor: 0, Patch: 0}
-	dep := cavefile.Dependency{Source: "example/pkg", Predicate: version.Predicate{Comparison: version.ComparisonExact, Version: ver}}
+	dep := cavefile.Dependency{Source: "example/pkg", Constraint: version.ConstraintOf(version.Predicate{Comparison: version.ComparisonExact, Version: ver})}
 	pot := cavefile.Cavefile{Dependencies: []cavefile.Dependency{dep}}
 	pkg := mockResolvedPackage{source: dep.Source, ver: ver}
 	pm := &PackageManager{registries: []registry.Provider{mockRegistry{pkgs: []registry.ResolvedPackage{pkg}}}}
@@ -50,3 +54,54 @@ func TestPkgManagerInstallationTaskRun(t *testing.T) {
 		t.Fatalf("expected source %s, got %s", dep.Source, task.completed[0].Source())
 	}
 }
+
+func TestPkgManagerInstallLocalPackages(t *testing.T) {
+	fs := memfs.New()
+	files := map[string]string{
+		"/libs/a/Cavefile":   "import cave\n@cave.Dependencies()\n@cave.Version(\"1.2.0\")\ndata Deps {\n@cave.Local(\"../b\")\nb\n@cave.Git(\"c\")\nc\n}",
+		"/libs/b/main.blush": "let b = 1",
+	}
+	for name, contents := range files {
+		if err := billyutil.WriteFile(fs, name, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	graph := graphProvider{versions: map[string][]string{"c": {"1.0.0"}}}
+	local := func(source, pred string) cavefile.Dependency {
+		return cavefile.Dependency{ImportName: source, Kind: cavefile.SourceLocal, Source: source, Constraint: version.ConstraintOf(version.ParsePredicate(pred))}
+	}
+	pm := &PackageManager{registries: []registry.Provider{graph}}
+	WithProject(fs, "/project")(pm)
+
+	lockfs := memfs.New()
+	task := pm.Install(cavefile.Cavefile{Dependencies: []cavefile.Dependency{local("../libs/a/", "^1.0.0")}}, WithLockfile(lockfs, LockfileName))
+	if err := task.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, pkg := range task.completed {
+		got = append(got, pkg.Source()+"@"+pkg.Version().String())
+	}
+	if want := []string{"../libs/a@1.2.0", "../libs/b@0.0.0", "c@1.0.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	lock, err := ReadLockfile(lockfs, LockfileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Packages) != 1 || lock.Packages[0].Source != "c" {
+		t.Errorf("expected only c to be locked, got %+v", lock.Packages)
+	}
+
+	err = pm.Install(cavefile.Cavefile{Dependencies: []cavefile.Dependency{local("../libs/a", "^2.0.0")}}).Run(context.Background())
+	if err == nil || err.Error() != "no registry can provide package ../libs/a" {
+		t.Errorf("expected version conflict, got %v", err)
+	}
+
+	graph.deps = map[string]string{"c": "@cave.Local(\"../d\")\nd"}
+	pm.registries = []registry.Provider{graph}
+	err = pm.Install(cavefile.Cavefile{Dependencies: []cavefile.Dependency{local("../libs/a", "^1.0.0")}}).Run(context.Background())
+	if want := "package c cannot declare the local dependency ../d, required by ../libs/a"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
@@ -0,0 +1,317 @@
+package pkgmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/vknabel/blush/cavefile"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/version"
+)
+
+// Requirement is a dependency declared by the Cavefile or one of the installed packages.
+type Requirement struct {
+	Dependency cavefile.Dependency
+	// The sources of all packages requiring the dependency.
+	// Empty for dependencies of the root Cavefile.
+	Path []string
+}
+
+func (r Requirement) String() string {
+	requiredBy := "Cavefile"
+	if len(r.Path) > 0 {
+		requiredBy = strings.Join(r.Path, " -> ")
+	}
+	return fmt.Sprintf("%s requires %s %s", requiredBy, r.Dependency.Source, r.Dependency.Constraint)
+}
+
+// ConflictError reports a source, which cannot satisfy all of its requirements.
+type ConflictError struct {
+	Source       string
+	Requirements []Requirement
+	// All known versions of the source.
+	Versions []version.Version
+}
+
+// Error implements error.
+func (e *ConflictError) Error() string {
+	if len(e.Versions) == 0 {
+		return fmt.Sprintf("no registry can provide package %s%s", e.Source, requiredBy(e.Requirements[0].Path))
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "no version of %s satisfies all requirements:", e.Source)
+	for _, req := range e.Requirements {
+		fmt.Fprintf(&msg, "\n  %s", req)
+	}
+	return msg.String()
+}
+
+// solver selects exactly one version per source, which satisfies all requirements.
+// Newer versions are preferred. On conflicts, it backtracks to older versions of previously selected packages.
+type solver struct {
+	task       *InstallationTask
+	availables map[string][]registry.ResolvedPackage
+
+	selected     map[string]registry.ResolvedPackage
+	order        []registry.ResolvedPackage
+	kinds        map[string]cavefile.SourceKind
+	requirements map[string][]Requirement
+	versions     map[string][]version.Version
+	// pinned packages are preferred and verified
+	pins map[string]LockedPackage
+	// the first conflict explains failures better than the conflicts caused by backtracking
+	conflict *ConflictError
+}
+
+func newSolver(task *InstallationTask, availables map[string][]registry.ResolvedPackage, pins map[string]LockedPackage) *solver {
+	for _, pkgs := range availables {
+		sort.SliceStable(pkgs, func(i, j int) bool {
+			return version.Less(pkgs[j].Version(), pkgs[i].Version())
+		})
+	}
+	return &solver{
+		task:         task,
+		availables:   availables,
+		selected:     make(map[string]registry.ResolvedPackage),
+		kinds:        make(map[string]cavefile.SourceKind),
+		requirements: make(map[string][]Requirement),
+		versions:     make(map[string][]version.Version),
+		pins:         pins,
+	}
+}
+
+// Solve returns the selected packages in the order of their selection.
+func (s *solver) Solve(ctx context.Context, deps []cavefile.Dependency) ([]registry.ResolvedPackage, error) {
+	pending := make([]Requirement, len(deps))
+	for i, dep := range deps {
+		if dep.Kind == cavefile.SourceLocal {
+			dep.Source = path.Clean(dep.Source)
+		}
+		pending[i] = Requirement{Dependency: dep}
+	}
+	err := s.solve(ctx, pending)
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		return nil, s.conflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.order, nil
+}
+
+func (s *solver) solve(ctx context.Context, pending []Requirement) error {
+	if len(pending) == 0 {
+		return nil
+	}
+	req, rest := pending[0], pending[1:]
+	source := req.Dependency.Source
+	for i, required := range req.Path {
+		if required == source {
+			cycle := append(req.Path[i:len(req.Path):len(req.Path)], source)
+			return fmt.Errorf("dependency cycle %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	s.requirements[source] = append(s.requirements[source], req)
+	defer func() {
+		s.requirements[source] = s.requirements[source][:len(s.requirements[source])-1]
+	}()
+
+	if pkg, ok := s.selected[source]; ok {
+		if !req.Dependency.Constraint.Matches(pkg.Version()) {
+			return s.conflictOf(source)
+		}
+		return s.solve(ctx, rest)
+	}
+
+	pinned := s.pins[source].Version
+	var remote []registry.Package
+	discovered := false
+	if pinned != "" && !hasVersion(s.availables[source], pinned) {
+		var err error
+		remote, err = s.remoteCandidates(ctx, source, req.Dependency.Kind)
+		if err != nil {
+			return err
+		}
+		discovered = true
+		// a lost pin must not be replaced silently, unless the constraints changed
+		if !hasVersion(remote, pinned) && s.satisfies(source, version.Parse(pinned)) {
+			return fmt.Errorf("locked version %s of %s is not available anymore, update %s to select another version%s", pinned, source, source, requiredBy(req.Path))
+		}
+	}
+
+	tried := make(map[string]bool)
+	for _, pkg := range pinnedFirst(s.availables[source], pinned) {
+		if !s.satisfies(source, pkg.Version()) {
+			continue
+		}
+		s.addVersion(source, pkg.Version())
+		tried[pkg.Version().String()] = true
+		if err := s.try(ctx, req, pkg, rest); !isConflict(err) {
+			return err
+		}
+	}
+
+	if !discovered {
+		var err error
+		remote, err = s.remoteCandidates(ctx, source, req.Dependency.Kind)
+		if err != nil {
+			return err
+		}
+	}
+	for _, pkg := range pinnedFirst(remote, pinned) {
+		if tried[pkg.Version().String()] {
+			continue
+		}
+		resolved, err := pkg.Resolve(ctx)
+		if err != nil {
+			return err
+		}
+		if err := s.try(ctx, req, resolved, rest); !isConflict(err) {
+			return err
+		}
+	}
+	return s.conflictOf(source)
+}
+
+// try selects the package and solves its dependencies followed by the rest.
+func (s *solver) try(ctx context.Context, req Requirement, pkg registry.ResolvedPackage, rest []Requirement) error {
+	source := req.Dependency.Source
+	if verifiable, ok := pkg.(registry.VerifiablePackage); ok {
+		if err := verifiable.Verify(); err != nil {
+			return fmt.Errorf("%w%s", err, requiredBy(req.Path))
+		}
+	}
+	if pin, ok := s.pins[source]; ok && pin.Version == pkg.Version().String() {
+		if err := pin.verify(pkg); err != nil {
+			return err
+		}
+	}
+	cave, err := loadCavefile(pkg)
+	if err != nil {
+		return fmt.Errorf("%w%s", err, requiredBy(req.Path))
+	}
+
+	reqPath := append(req.Path[:len(req.Path):len(req.Path)], source)
+	pending := make([]Requirement, 0, len(cave.Dependencies)+len(rest))
+	for _, dep := range cave.Dependencies {
+		if dep.Kind == cavefile.SourceLocal {
+			// local dependencies are relative to the Cavefile declaring them
+			if req.Dependency.Kind != cavefile.SourceLocal {
+				return fmt.Errorf("package %s cannot declare the local dependency %s%s", source, dep.Source, requiredBy(req.Path))
+			}
+			dep.Source = path.Join(source, dep.Source)
+		}
+		pending = append(pending, Requirement{Dependency: dep, Path: reqPath})
+	}
+	pending = append(pending, rest...)
+
+	s.selected[source] = pkg
+	s.kinds[source] = req.Dependency.Kind
+	s.order = append(s.order, pkg)
+
+	err = s.solve(ctx, pending)
+	if err != nil {
+		delete(s.selected, source)
+		delete(s.kinds, source)
+		s.order = s.order[:len(s.order)-1]
+	}
+	return err
+}
+
+// remoteCandidates discovers all versions of the source matching the current requirements, newest first.
+// Constraints with alternatives cannot be expressed as predicates and are only checked after discovery.
+func (s *solver) remoteCandidates(ctx context.Context, source string, kind cavefile.SourceKind) ([]registry.Package, error) {
+	var preds []version.Predicate
+	for _, req := range s.requirements[source] {
+		if reqPreds, ok := req.Dependency.Constraint.Predicates(); ok {
+			preds = append(preds, reqPreds...)
+		}
+	}
+	var candidates []registry.Package
+	for _, reg := range s.task.pkgmanager.providers(kind) {
+		pkgs, err := reg.DiscoverPackageVersions(ctx, source, preds...)
+		if err != nil {
+			return nil, err
+		}
+		for _, pkg := range pkgs {
+			s.addVersion(source, pkg.Version())
+			if s.satisfies(source, pkg.Version()) {
+				candidates = append(candidates, pkg)
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return version.Less(candidates[j].Version(), candidates[i].Version())
+	})
+	return candidates, nil
+}
+
+func (s *solver) satisfies(source string, v version.Version) bool {
+	for _, req := range s.requirements[source] {
+		if !req.Dependency.Constraint.Matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *solver) addVersion(source string, v version.Version) {
+	for _, known := range s.versions[source] {
+		if known.String() == v.String() {
+			return
+		}
+	}
+	s.versions[source] = append(s.versions[source], v)
+}
+
+func (s *solver) conflictOf(source string) *ConflictError {
+	conflict := &ConflictError{
+		Source:       source,
+		Requirements: append([]Requirement(nil), s.requirements[source]...),
+		Versions:     append([]version.Version(nil), s.versions[source]...),
+	}
+	if s.conflict == nil {
+		s.conflict = conflict
+	}
+	return conflict
+}
+
+// pinnedFirst moves the package with the pinned version to the front.
+func pinnedFirst[P registry.Package](pkgs []P, pinned string) []P {
+	if pinned == "" {
+		return pkgs
+	}
+	ordered := make([]P, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		if pkg.Version().String() == pinned {
+			ordered = append(ordered, pkg)
+		}
+	}
+	for _, pkg := range pkgs {
+		if pkg.Version().String() != pinned {
+			ordered = append(ordered, pkg)
+		}
+	}
+	return ordered
+}
+
+// hasVersion reports whether any of the packages has the given version.
+func hasVersion[P registry.Package](pkgs []P, v string) bool {
+	for _, pkg := range pkgs {
+		if pkg.Version().String() == v {
+			return true
+		}
+	}
+	return false
+}
+
+func isConflict(err error) bool {
+	var conflict *ConflictError
+	return errors.As(err, &conflict)
+}
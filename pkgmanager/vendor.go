@@ -0,0 +1,99 @@
+package pkgmanager
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	"github.com/vknabel/blush/cavefile"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/vendorreg"
+)
+
+// Vendor copies the modules and Cavefiles of all installed packages into the directory.
+// Previously vendored packages are removed.
+// Local packages are already part of the project and the standard library is part of the toolchain,
+// hence both are not vendored.
+func (t *InstallationTask) Vendor(fs billy.Filesystem, dir string) error {
+	// packages might have been served from the directory, hence they are read before it is removed
+	var manifest vendorreg.Manifest
+	files := make(map[string][]byte)
+	for _, pkg := range t.completed {
+		if kind := t.kinds[pkg.Source()]; kind == cavefile.SourceLocal || kind == cavefile.SourceStdlib {
+			continue
+		}
+		vendored, err := vendorPackage(files, dir, pkg)
+		if err != nil {
+			return fmt.Errorf("cannot vendor %s: %w", pkg.Source(), err)
+		}
+		manifest.Packages = append(manifest.Packages, vendored)
+	}
+
+	err := billyutil.RemoveAll(fs, dir)
+	if err != nil {
+		return err
+	}
+	err = fs.MkdirAll(dir, 0o755)
+	if err != nil {
+		return err
+	}
+	for name, contents := range files {
+		err = billyutil.WriteFile(fs, name, contents, 0o644)
+		if err != nil {
+			return err
+		}
+	}
+	return vendorreg.WriteManifest(fs, dir, manifest)
+}
+
+// vendorPackage reads all files of the package into the files to vendor.
+func vendorPackage(files map[string][]byte, dir string, pkg registry.ResolvedPackage) (vendorreg.VendoredPackage, error) {
+	locked, err := lockPackage(pkg)
+	if err != nil {
+		return vendorreg.VendoredPackage{}, err
+	}
+	vendored := vendorreg.VendoredPackage{
+		Source:  locked.Source,
+		Version: locked.Version,
+		Commit:  locked.Commit,
+		Hash:    locked.Hash,
+		Dir:     vendorreg.PackageDir(pkg.Source()),
+	}
+
+	mods, err := pkg.ResolveModules()
+	if err != nil {
+		return vendorreg.VendoredPackage{}, err
+	}
+	var srcs []registry.Source
+	for _, mod := range mods {
+		modSrcs, err := mod.Sources()
+		if err != nil {
+			return vendorreg.VendoredPackage{}, err
+		}
+		srcs = append(srcs, modSrcs...)
+	}
+	cave, err := pkg.Cavefile()
+	if err != nil {
+		return vendorreg.VendoredPackage{}, err
+	}
+	if cave != nil {
+		srcs = append(srcs, cave)
+	}
+
+	// sources are addressed relative to the package, which keeps the content hash intact
+	base := strings.TrimSuffix(pkg.Source(), "/") + "/"
+	for _, src := range srcs {
+		rel, ok := strings.CutPrefix(string(src.URI()), base)
+		if !ok {
+			return vendorreg.VendoredPackage{}, fmt.Errorf("source %s is outside of the package", src.URI())
+		}
+		contents, err := src.Read()
+		if err != nil {
+			return vendorreg.VendoredPackage{}, err
+		}
+		files[path.Join(dir, vendored.Dir, rel)] = contents
+	}
+	return vendored, nil
+}
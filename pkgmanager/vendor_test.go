@@ -0,0 +1,100 @@
+package pkgmanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	"github.com/vknabel/blush/cavefile"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/staticmodule"
+	"github.com/vknabel/blush/registry/vendorreg"
+	"github.com/vknabel/blush/version"
+)
+
+func TestInstallationTaskVendor(t *testing.T) {
+	pkg := &stubCommittedPackage{
+		stubResolvedPackage: &stubResolvedPackage{
+			source:  "example.com/a",
+			version: version.Parse("1.0.0"),
+			resolvedModulesResp: []registry.ResolvedModule{
+				staticmodule.NewModule("example.com/a", []registry.Source{
+					staticmodule.NewSourceString("example.com/a/main.blush", "let a = 1"),
+				}),
+				staticmodule.NewModule("example.com/a/sub", []registry.Source{
+					staticmodule.NewSourceString("example.com/a/sub/lib.blush", "let b = 2"),
+				}),
+			},
+			manifest: staticmodule.NewSourceString("example.com/a/Cavefile", "import cave"),
+		},
+		commit: "abc",
+	}
+	provider := &stubProvider{
+		discoverFn: func(context.Context) ([]registry.ResolvedPackage, error) {
+			return []registry.ResolvedPackage{pkg}, nil
+		},
+	}
+	cave := cavefile.Cavefile{Dependencies: []cavefile.Dependency{
+		{ImportName: "a", Source: "example.com/a", Constraint: cavefile.AnyVersion},
+	}}
+	ctx := context.Background()
+	fs := memfs.New()
+	pm := &PackageManager{registries: []registry.Provider{provider}}
+	task := pm.Install(cave, WithLockfile(fs, LockfileName))
+	if err := task.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := billyutil.WriteFile(fs, "/vendor/stale/main.blush", []byte("let stale = 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Vendor(fs, "/vendor"); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := vendorreg.ReadManifest(fs, "/vendor")
+	if err != nil || manifest == nil || len(manifest.Packages) != 1 {
+		t.Fatalf("expected one vendored package, got %+v, %v", manifest, err)
+	}
+	lock, err := ReadLockfile(fs, LockfileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	locked, _ := lock.Lookup("example.com/a")
+	vendored := manifest.Packages[0]
+	if vendored.Version != "1.0.0" || vendored.Commit != "abc" || vendored.Hash != locked.Hash {
+		t.Errorf("expected vendored package to match %+v, got %+v", locked, vendored)
+	}
+	for _, name := range []string{"Cavefile", "main.blush", "sub/lib.blush"} {
+		if _, err := fs.Stat("/vendor/" + vendored.Dir + "/" + name); err != nil {
+			t.Errorf("expected %s to be vendored, got %v", name, err)
+		}
+	}
+	if _, err := fs.Stat("/vendor/stale"); err == nil {
+		t.Error("expected previously vendored packages to be removed")
+	}
+
+	// without any other registry, the lockfile is satisfied by the vendored package
+	offline := &PackageManager{}
+	WithVendored(fs, "/vendor")(offline)
+	task = offline.Install(cave, WithLockfile(fs, LockfileName))
+	if err := task.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(task.completed) != 1 {
+		t.Fatalf("expected the vendored package, got %v", task.completed)
+	}
+	mods, err := task.completed[0].ResolveModules()
+	if err != nil || len(mods) != 2 {
+		t.Errorf("expected two vendored modules, got %v, %v", mods, err)
+	}
+
+	// vendoring from vendored packages keeps their contents
+	if err := task.Vendor(fs, "/vendor"); err != nil {
+		t.Fatal(err)
+	}
+	contents, err := billyutil.ReadFile(fs, "/vendor/"+vendored.Dir+"/sub/lib.blush")
+	if err != nil || string(contents) != "let b = 2" {
+		t.Errorf("expected vendored contents to be kept, got %q, %v", contents, err)
+	}
+}
@@ -0,0 +1,38 @@
+package registry
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// CavefileName is the name of the Cavefile within the root directory of a package.
+const CavefileName = "Cavefile"
+
+// Cavefile reads the Cavefile of the package with the given logical URI.
+// readFile reads files relative to the root directory of the package.
+// Returns nil if the package has no Cavefile.
+func Cavefile(pkg LogicalURI, readFile func(name string) ([]byte, error)) (Source, error) {
+	contents, err := readFile(CavefileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cavefileSource{uri: pkg.Join(CavefileName), contents: contents}, nil
+}
+
+type cavefileSource struct {
+	uri      LogicalURI
+	contents []byte
+}
+
+// URI implements Source.
+func (s cavefileSource) URI() LogicalURI {
+	return s.uri
+}
+
+// Read implements Source.
+func (s cavefileSource) Read() ([]byte, error) {
+	return s.contents, nil
+}
@@ -0,0 +1,42 @@
+package registry_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/vknabel/blush/registry"
+)
+
+func TestCavefile(t *testing.T) {
+	files := map[string]string{"Cavefile": "import cave\n"}
+	readFile := func(name string) ([]byte, error) {
+		contents, ok := files[name]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		return []byte(contents), nil
+	}
+
+	src, err := registry.Cavefile("pkg:///a", readFile)
+	if err != nil || src == nil {
+		t.Fatalf("expected Cavefile, got %v, %v", src, err)
+	}
+	if src.URI() != "pkg:///a/Cavefile" {
+		t.Errorf("expected Cavefile within the package, got %s", src.URI())
+	}
+	if contents, err := src.Read(); err != nil || string(contents) != files["Cavefile"] {
+		t.Errorf("expected contents %q, got %q, %v", files["Cavefile"], contents, err)
+	}
+
+	delete(files, "Cavefile")
+	if src, err := registry.Cavefile("pkg:///a", readFile); src != nil || err != nil {
+		t.Errorf("expected no Cavefile, got %v, %v", src, err)
+	}
+
+	failure := errors.New("failure")
+	_, err = registry.Cavefile("pkg:///a", func(string) ([]byte, error) { return nil, failure })
+	if !errors.Is(err, failure) {
+		t.Errorf("expected read error, got %v", err)
+	}
+}
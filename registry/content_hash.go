@@ -0,0 +1,45 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+)
+
+// ContentHash computes a deterministic hash over all sources of the package and its Cavefile.
+func ContentHash(pkg ResolvedPackage) (string, error) {
+	mods, err := pkg.ResolveModules()
+	if err != nil {
+		return "", err
+	}
+	var srcs []Source
+	for _, mod := range mods {
+		modSrcs, err := mod.Sources()
+		if err != nil {
+			return "", err
+		}
+		srcs = append(srcs, modSrcs...)
+	}
+	cave, err := pkg.Cavefile()
+	if err != nil {
+		return "", err
+	}
+	if cave != nil {
+		srcs = append(srcs, cave)
+	}
+	sort.Slice(srcs, func(i, j int) bool {
+		return srcs[i].URI() < srcs[j].URI()
+	})
+
+	h := sha256.New()
+	for _, src := range srcs {
+		contents, err := src.Read()
+		if err != nil {
+			return "", err
+		}
+		// length prefixes keep the boundaries between files unambiguous
+		fmt.Fprintf(h, "%d:%s%d:", len(src.URI()), src.URI(), len(contents))
+		h.Write(contents)
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+}
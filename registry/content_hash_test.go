@@ -0,0 +1,53 @@
+package registry_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/staticmodule"
+	"github.com/vknabel/blush/version"
+)
+
+type hashedPackage struct {
+	mods     []registry.ResolvedModule
+	manifest registry.Source
+}
+
+func (p hashedPackage) Source() string           { return "pkg" }
+func (p hashedPackage) Version() version.Version { return version.Parse("1.0.0") }
+func (p hashedPackage) Resolve(ctx context.Context) (registry.ResolvedPackage, error) {
+	return p, nil
+}
+func (p hashedPackage) ResolveModules() ([]registry.ResolvedModule, error) { return p.mods, nil }
+func (p hashedPackage) Cavefile() (registry.Source, error)                 { return p.manifest, nil }
+
+func TestContentHash(t *testing.T) {
+	pkg := func(contents string, manifest registry.Source) registry.ResolvedPackage {
+		mod := staticmodule.NewModule("pkg", []registry.Source{staticmodule.NewSourceString("pkg/main.blush", contents)})
+		return hashedPackage{mods: []registry.ResolvedModule{mod}, manifest: manifest}
+	}
+	lhs, err := registry.ContentHash(pkg("let a = 1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rhs, err := registry.ContentHash(pkg("let a = 1", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := registry.ContentHash(pkg("let a = 2", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lhs != rhs || lhs == other || !strings.HasPrefix(lhs, "sha256:") {
+		t.Errorf("expected deterministic hashes, got %s, %s and %s", lhs, rhs, other)
+	}
+	withCavefile, err := registry.ContentHash(pkg("let a = 1", staticmodule.NewSourceString("pkg/Cavefile", "import cave")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withCavefile == lhs {
+		t.Errorf("expected the Cavefile to be hashed, got %s for both", lhs)
+	}
+}
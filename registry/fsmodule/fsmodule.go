@@ -52,7 +52,10 @@ func DiscoverModules(base registry.LogicalURI, fs billy.Filesystem) ([]*FSModule
 	for _, m := range matches {
 		name := filepath.Base(m)
 
-		moduleURI := base.Join(filepath.Dir(m))
+		moduleURI := base
+		if dir := filepath.Dir(m); dir != "." {
+			moduleURI = base.Join(dir)
+		}
 		mod, ok := rootSrcs[moduleURI]
 		if !ok {
 			subfs, err := fs.Chroot(filepath.Dir(m))
@@ -15,6 +15,15 @@ type FSSource struct {
 	fs         billy.Filesystem
 }
 
+// NewSource creates a source for the file with the given name within the file system.
+func NewSource(name string, logicalURI registry.LogicalURI, fs billy.Filesystem) *FSSource {
+	return &FSSource{
+		name:       name,
+		logicalURI: logicalURI,
+		fs:         fs,
+	}
+}
+
 // URI implements registry.Source.
 func (f *FSSource) URI() registry.LogicalURI {
 	return f.logicalURI
@@ -0,0 +1,68 @@
+package gitreg
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/hash"
+	"github.com/vknabel/blush/version"
+)
+
+// commitVersion is a package version pinned by a branch name or a commit hash.
+// Besides the pin itself, it matches abbreviated hashes of the resolved commit.
+type commitVersion struct {
+	version.VerbalVersion
+	commit plumbing.Hash
+}
+
+func newCommitVersion(pin string, commit plumbing.Hash) commitVersion {
+	return commitVersion{
+		VerbalVersion: version.ParseVerbal(pin),
+		commit:        commit,
+	}
+}
+
+// Matches implements version.Version.
+func (v commitVersion) Matches(cond version.Predicate) bool {
+	if v.VerbalVersion.Matches(cond) {
+		return true
+	}
+	pin, ok := exactPin(cond)
+	return ok && !v.commit.IsZero() && matchesCommit(pin, v.commit)
+}
+
+// exactPin returns the branch name or commit hash the predicate pins.
+func exactPin(pred version.Predicate) (string, bool) {
+	verbal, ok := pred.Version.(version.VerbalVersion)
+	if !ok || pred.Comparison != version.ComparisonExact || verbal.Verbal == "" {
+		return "", false
+	}
+	return verbal.Verbal, true
+}
+
+// pinFromPredicates returns the first branch name or commit hash pinned by the predicates.
+func pinFromPredicates(predicates []version.Predicate) (string, bool) {
+	for _, pred := range predicates {
+		if pin, ok := exactPin(pred); ok {
+			return pin, true
+		}
+	}
+	return "", false
+}
+
+// isCommitPin reports whether the pin might be a full or abbreviated commit hash.
+func isCommitPin(pin string) bool {
+	if len(pin) < 4 || len(pin) > hash.HexSize {
+		return false
+	}
+	for _, r := range strings.ToLower(pin) {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesCommit(pin string, commit plumbing.Hash) bool {
+	return isCommitPin(pin) && strings.HasPrefix(commit.String(), strings.ToLower(pin))
+}
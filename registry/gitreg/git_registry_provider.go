@@ -8,12 +8,15 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/hash"
 	"github.com/go-git/go-git/v5/storage"
 	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/vknabel/blush/registry"
@@ -25,22 +28,36 @@ import (
 // It produces the following structure:
 //
 //	 <root>/
+//	 ├── <package>.refs.json
 //	 └── <package>/
-//		 └── <version>/
+//		 ├── <commit>.sum
+//		 └── <commit>/
 //			 ├── Cavefile
 //	 		 └── <submodule>/
 //
-// TODO: Add some in-memory caching to avoid discovering remote versions on every run.
-// TODO: How to handle git commits and branch names from remote?
+// The content hash of each clone is recorded in <commit>.sum and verified before it is used.
+//
+// Versions are tags of the remote. Exact verbal versions may also pin
+// branch names or full and abbreviated commit hashes.
+//
+// The references of remotes are cached for a TTL to avoid listing them on every run.
+// In offline mode, only the local clones are discovered.
 type GitRegistry struct {
 	rootfs billy.Filesystem
 
+	offline  bool
+	cacheTTL time.Duration
+	now      func() time.Time
+
 	remoteStorage     func() storage.Storer
 	repositoryStorage func(worktree billy.Filesystem) (storage.Storer, error)
 }
 
 type Option func(*GitRegistry)
 
+// ErrOffline is returned when a package cannot be discovered without contacting its remote.
+var ErrOffline = errors.New("offline")
+
 func New(regrootfs billy.Filesystem, opts ...Option) *GitRegistry {
 	reg := &GitRegistry{
 		rootfs: regrootfs,
@@ -86,8 +103,13 @@ func (r *GitRegistry) Discover(ctx context.Context) ([]registry.ResolvedPackage,
 }
 
 // DiscoverPackageVersions implements Registry
+// In offline mode, only local clones are considered.
 func (r *GitRegistry) DiscoverPackageVersions(ctx context.Context, repoUrl string, predicates ...version.Predicate) ([]registry.Package, error) {
-	gitvs, err := r.remotePackageVersions(ctx, repoUrl, predicates)
+	discover := r.remotePackageVersions
+	if r.offline {
+		discover = r.offlinePackageVersions
+	}
+	gitvs, err := discover(ctx, repoUrl, predicates)
 	if err != nil {
 		return nil, err
 	}
@@ -102,41 +124,115 @@ func (r *GitRegistry) DiscoverPackageVersions(ctx context.Context, repoUrl strin
 }
 
 func (r *GitRegistry) remotePackageVersions(ctx context.Context, repoUrl string, predicates []version.Predicate) ([]registry.Package, error) {
-	rem := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
-		Name: git.DefaultRemoteName,
-		URLs: []string{repoUrl},
-	})
-
-	refs, err := rem.ListContext(ctx, &git.ListOptions{
-		PeelingOption: git.IgnorePeeled,
-	})
+	refs, err := r.remoteReferences(ctx, repoUrl)
 	if err != nil {
 		return nil, err
 	}
+	commits := peeledCommits(refs)
 
 	var pkgs []registry.Package
 	for _, ref := range refs {
-		if !ref.Name().IsTag() {
+		if !ref.Name().IsTag() || isPeeled(ref) {
 			continue
 		}
 		v := versionFromReference(ref)
-		shouldAdd := true
-		for _, predicate := range predicates {
-			if !v.Matches(predicate) {
-				shouldAdd = false
-				break
-			}
-		}
-
-		if shouldAdd {
+		if matchesAll(v, predicates) {
 			pkgs = append(pkgs, &remoteGitPackage{
 				provider:     r,
 				source:       repoUrl,
 				gitReference: ref,
 				version:      v,
+				commit:       commits[ref.Name()],
 			})
 		}
 	}
+	if pin, ok := pinFromPredicates(predicates); ok && len(pkgs) == 0 {
+		pkg := r.pinnedPackage(repoUrl, pin, refs, commits)
+		if pkg != nil && matchesAll(pkg.version, predicates) {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs, nil
+}
+
+// pinnedPackage resolves a branch name or commit hash of the remote.
+// Abbreviated hashes, which are not the tip of any branch or tag, are resolved after cloning.
+func (r *GitRegistry) pinnedPackage(repoUrl string, pin string, refs []*plumbing.Reference, commits map[plumbing.ReferenceName]plumbing.Hash) *remoteGitPackage {
+	pkg := &remoteGitPackage{
+		provider: r,
+		source:   repoUrl,
+	}
+	for _, ref := range refs {
+		if ref.Name() == plumbing.NewBranchReferenceName(pin) {
+			pkg.gitReference = ref
+			pkg.commit = ref.Hash()
+			pkg.version = newCommitVersion(pin, ref.Hash())
+			return pkg
+		}
+	}
+	if !isCommitPin(pin) {
+		return nil
+	}
+	for _, ref := range refs {
+		if (!ref.Name().IsBranch() && !ref.Name().IsTag()) || isPeeled(ref) {
+			continue
+		}
+		if commit := commits[ref.Name()]; matchesCommit(pin, commit) {
+			pkg.gitReference = ref
+			pkg.commit = commit
+			pkg.version = newCommitVersion(pin, commit)
+			return pkg
+		}
+	}
+	if len(pin) == hash.HexSize {
+		pkg.commit = plumbing.NewHash(pin)
+	}
+	pkg.version = newCommitVersion(pin, pkg.commit)
+	return pkg
+}
+
+// remoteReferences lists the references of the remote or returns the cached ones.
+func (r *GitRegistry) remoteReferences(ctx context.Context, repoUrl string) ([]*plumbing.Reference, error) {
+	refs, ok, err := r.cachedReferences(repoUrl)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return refs, nil
+	}
+
+	rem := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{repoUrl},
+	})
+	refs, err = rem.ListContext(ctx, &git.ListOptions{
+		PeelingOption: git.AppendPeeled,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := r.cacheReferences(repoUrl, refs); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// offlinePackageVersions returns the local clones of the package matching all predicates.
+// Fails if there are none, as the remote must not be contacted.
+func (r *GitRegistry) offlinePackageVersions(ctx context.Context, repoUrl string, predicates []version.Predicate) ([]registry.Package, error) {
+	locals, err := r.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []registry.Package
+	for _, local := range locals {
+		if local.Source() == repoUrl && matchesAll(local.Version(), predicates) {
+			pkgs = append(pkgs, local)
+		}
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("%w: no local clone of %s matches %s", ErrOffline, repoUrl, formatPredicates(predicates))
+	}
 	return pkgs, nil
 }
 
@@ -148,16 +244,40 @@ func (r *GitRegistry) localPackageVersionClones(ctx context.Context, unversioned
 		errs = append(errs, err)
 	}
 	for _, versionEntry := range versionEntries {
+		if !versionEntry.IsDir() {
+			continue
+		}
+		integrity, err := readIntegrity(unversionedPackageFS, versionEntry.Name())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if integrity == "" {
+			// incomplete clones will be fetched again
+			continue
+		}
 		packagefs, err := unversionedPackageFS.Chroot(versionEntry.Name())
 		if err != nil {
 			errs = append(errs, err)
 			continue
 		}
-		ps, err := r.localPackageVersionAliasesInWorktree(ctx, packagefs)
+		ps, err := r.localPackageVersionAliasesInWorktree(ctx, packagefs, integrity)
 		if err != nil {
 			errs = append(errs, err)
+			continue
+		}
+		err = ps[0].Verify()
+		if errors.Is(err, registry.ErrIntegrity) {
+			// tampered clones will be fetched again
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, p := range ps {
+			providables = append(providables, p)
 		}
-		providables = append(providables, ps...)
 	}
 
 	if len(errs) > 0 {
@@ -166,7 +286,7 @@ func (r *GitRegistry) localPackageVersionClones(ctx context.Context, unversioned
 	return providables, nil
 }
 
-func (r *GitRegistry) localPackageVersionAliasesInWorktree(ctx context.Context, worktree billy.Filesystem) ([]registry.ResolvedPackage, error) {
+func (r *GitRegistry) localPackageVersionAliasesInWorktree(ctx context.Context, worktree billy.Filesystem, integrity string) ([]*localGitPackage, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
@@ -188,36 +308,56 @@ func (r *GitRegistry) localPackageVersionAliasesInWorktree(ctx context.Context,
 		return nil, err
 	}
 
-	refs, err := r.relevantReferences(repo)
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	refs, err := r.relevantReferences(repo, head)
 	if err != nil {
 		return nil, err
 	}
 
-	packages := make([]registry.ResolvedPackage, len(refs))
 	packageName := remote.Config().URLs[0]
-	for i, ref := range refs {
+	// every clone is available by its commit and the branch it has been cloned from
+	pin := head.Hash().String()
+	if head.Name().IsBranch() {
+		pin = head.Name().Short()
+	}
+	packages := []*localGitPackage{
+		{
+			fs:        worktree,
+			commit:    head.Hash(),
+			integrity: integrity,
+			remoteGitPackage: &remoteGitPackage{
+				provider:     r,
+				source:       packageName,
+				gitReference: head,
+				version:      newCommitVersion(pin, head.Hash()),
+				commit:       head.Hash(),
+			},
+		},
+	}
+	for _, ref := range refs {
 		if !ref.Name().IsTag() {
 			continue
 		}
-		packages[i] = &localGitPackage{
-			fs: worktree,
+		packages = append(packages, &localGitPackage{
+			fs:        worktree,
+			commit:    head.Hash(),
+			integrity: integrity,
 			remoteGitPackage: &remoteGitPackage{
 				provider:     r,
 				source:       packageName,
 				gitReference: ref,
 				version:      versionFromReference(ref),
+				commit:       head.Hash(),
 			},
-		}
+		})
 	}
 	return packages, nil
 }
 
-func (r *GitRegistry) relevantReferences(repo *git.Repository) ([]*plumbing.Reference, error) {
-	ref, err := repo.Head()
-	if err != nil {
-		return nil, err
-	}
-
+func (r *GitRegistry) relevantReferences(repo *git.Repository, ref *plumbing.Reference) ([]*plumbing.Reference, error) {
 	versions := []*plumbing.Reference{ref}
 
 	tags, err := repo.Tags()
@@ -241,44 +381,234 @@ func (r *GitRegistry) relevantReferences(repo *git.Repository) ([]*plumbing.Refe
 	return versions, nil
 }
 
+// clone checks out the package into a directory keyed by its commit.
+// Commits, which have already been cloned, are reused.
 func (r *GitRegistry) clone(ctx context.Context, pkg *remoteGitPackage) (registry.ResolvedPackage, error) {
-	repoPath := path.Join(mangle(pkg.source), mangle(pkg.version.String()))
-	err := r.rootfs.MkdirAll(repoPath, 0755)
+	commit := pkg.commit
+	if commit.IsZero() {
+		resolved, err := r.resolveCommit(ctx, pkg)
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve %s of %s: %w", pkg.version, pkg.source, err)
+		}
+		commit = resolved
+	}
+	if _, ok := pkg.version.(commitVersion); ok {
+		resolved := *pkg
+		resolved.commit = commit
+		resolved.version = newCommitVersion(pkg.version.String(), commit)
+		pkg = &resolved
+	}
+	local, err := r.existingClone(pkg, commit)
+	if local != nil || err != nil {
+		return local, err
+	}
+
+	clonePath := path.Join(Mangle(pkg.source), commit.String())
+	// shallow clones only succeed while the reference still points to the commit
+	shallow := pkg.gitReference != nil
+	if shallow {
+		err = r.cloneCommit(ctx, clonePath, pkg, commit, true)
+	}
+	if !shallow || err != nil {
+		err = r.cloneCommit(ctx, clonePath, pkg, commit, false)
+	}
 	if err != nil {
 		return nil, err
 	}
+	return r.recordIntegrity(pkg, commit)
+}
 
-	worktreefs, err := r.rootfs.Chroot(repoPath)
+// cloneCommit clones the repository and checks out the commit.
+// Incomplete clones are removed.
+func (r *GitRegistry) cloneCommit(ctx context.Context, clonePath string, pkg *remoteGitPackage, commit plumbing.Hash, shallow bool) (err error) {
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, util.RemoveAll(r.rootfs, clonePath))
+		}
+	}()
+	err = r.rootfs.MkdirAll(clonePath, 0755)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	worktreefs, err := r.rootfs.Chroot(clonePath)
+	if err != nil {
+		return err
 	}
 	storer, err := r.repositoryStorage(worktreefs)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	_, err = git.CloneContext(ctx, storer, worktreefs, &git.CloneOptions{
+	opts := &git.CloneOptions{
 		URL:               pkg.source,
 		RemoteName:        git.DefaultRemoteName,
-		SingleBranch:      true,
-		Depth:             1,
-		ReferenceName:     pkg.gitReference.Name(),
 		Tags:              git.AllTags,
 		RecurseSubmodules: git.NoRecurseSubmodules,
+	}
+	if shallow {
+		opts.ReferenceName = pkg.gitReference.Name()
+		opts.SingleBranch = true
+		opts.Depth = 1
+	}
+	repo, err := git.CloneContext(ctx, storer, worktreefs, opts)
+	if err != nil {
+		return err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	if head.Hash() == commit {
+		return nil
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return wt.Checkout(&git.CheckoutOptions{Hash: commit})
+}
+
+// resolveCommit fetches the history of the remote into memory to resolve abbreviated commit hashes,
+// which are not the tip of any branch or tag.
+func (r *GitRegistry) resolveCommit(ctx context.Context, pkg *remoteGitPackage) (plumbing.Hash, error) {
+	repo, err := git.CloneContext(ctx, r.remoteStorage(), nil, &git.CloneOptions{
+		URL:        pkg.source,
+		RemoteName: git.DefaultRemoteName,
+		Tags:       git.NoTags,
 	})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	commit, err := repo.ResolveRevision(plumbing.Revision(pkg.version.String()))
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return *commit, nil
+}
+
+// existingClone returns the verified clone of the commit if there is one.
+// Incomplete or tampered clones are removed to be fetched again.
+func (r *GitRegistry) existingClone(pkg *remoteGitPackage, commit plumbing.Hash) (*localGitPackage, error) {
+	packagefs, err := r.rootfs.Chroot(Mangle(pkg.source))
+	if err != nil {
+		return nil, err
+	}
+	integrity, err := readIntegrity(packagefs, commit.String())
+	if err != nil {
+		return nil, err
+	}
+	if integrity != "" {
+		local, err := r.openClone(pkg, commit, integrity)
+		if err != nil {
+			return nil, err
+		}
+		err = local.Verify()
+		if err == nil || !errors.Is(err, registry.ErrIntegrity) {
+			return local, err
+		}
+	}
+	return nil, errors.Join(
+		util.RemoveAll(packagefs, commit.String()+integrityExt),
+		util.RemoveAll(packagefs, commit.String()),
+	)
+}
+
+// recordIntegrity stores the content hash of a fresh clone next to it.
+// Clones without recorded content hash are considered incomplete.
+func (r *GitRegistry) recordIntegrity(pkg *remoteGitPackage, commit plumbing.Hash) (*localGitPackage, error) {
+	local, err := r.openClone(pkg, commit, "")
+	if err != nil {
+		return nil, err
+	}
+	local.integrity, err = registry.ContentHash(local)
+	if err != nil {
+		return nil, err
+	}
+	integrityPath := path.Join(Mangle(pkg.source), commit.String()+integrityExt)
+	err = util.WriteFile(r.rootfs, integrityPath, []byte(local.integrity+"\n"), 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return local, nil
+}
+
+func (r *GitRegistry) openClone(pkg *remoteGitPackage, commit plumbing.Hash, integrity string) (*localGitPackage, error) {
+	worktreefs, err := r.rootfs.Chroot(path.Join(Mangle(pkg.source), commit.String()))
 	if err != nil {
 		return nil, err
 	}
 	return &localGitPackage{
 		remoteGitPackage: pkg,
 		fs:               worktreefs,
+		commit:           commit,
+		integrity:        integrity,
 	}, nil
 }
 
+// integrityExt is appended to the clone directory to record its content hash.
+const integrityExt = ".sum"
+
+// readIntegrity returns the recorded content hash of the clone or an empty string.
+func readIntegrity(packagefs billy.Filesystem, clone string) (string, error) {
+	data, err := util.ReadFile(packagefs, clone+integrityExt)
+	if errors.Is(err, world.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// peeledCommits maps references to the commits they point to.
+// Annotated tags are resolved to their peeled commit.
+func peeledCommits(refs []*plumbing.Reference) map[plumbing.ReferenceName]plumbing.Hash {
+	commits := make(map[plumbing.ReferenceName]plumbing.Hash, len(refs))
+	for _, ref := range refs {
+		if !isPeeled(ref) {
+			commits[ref.Name()] = ref.Hash()
+		}
+	}
+	for _, ref := range refs {
+		if isPeeled(ref) {
+			commits[plumbing.ReferenceName(strings.TrimSuffix(ref.Name().String(), peeledSuffix))] = ref.Hash()
+		}
+	}
+	return commits
+}
+
+const peeledSuffix = "^{}"
+
+func isPeeled(ref *plumbing.Reference) bool {
+	return strings.HasSuffix(ref.Name().String(), peeledSuffix)
+}
+
+func matchesAll(v version.Version, predicates []version.Predicate) bool {
+	for _, predicate := range predicates {
+		if !v.Matches(predicate) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatPredicates(predicates []version.Predicate) string {
+	if len(predicates) == 0 {
+		return "any version"
+	}
+	strs := make([]string, len(predicates))
+	for i, predicate := range predicates {
+		strs[i] = predicate.String()
+	}
+	return strings.Join(strs, " ")
+}
+
 func versionFromReference(ref *plumbing.Reference) version.Version {
-	return version.Parse(strings.TrimSuffix(ref.Name().Short(), "^{}"))
+	return version.Parse(strings.TrimSuffix(ref.Name().Short(), peeledSuffix))
 }
 
-func mangle(str string) string {
+// Mangle derives a directory name from the source of a package.
+// Special characters are replaced by dashes and a hash keeps sources apart, which only differ in them.
+func Mangle(str string) string {
 	var mangled string
 	for _, r := range str {
 		if unicode.IsLetter(r) || unicode.IsNumber(r) || r == '.' {
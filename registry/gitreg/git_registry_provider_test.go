@@ -3,11 +3,24 @@ package gitreg_test
 import (
 	"cmp"
 	"context"
+	"errors"
 	"net/http"
+	"os"
+	"os/exec"
+	"path"
 	"slices"
 	"testing"
+	"time"
 
+	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
 	"github.com/vknabel/blush/registry"
 	"github.com/vknabel/blush/registry/gitreg"
 	"github.com/vknabel/blush/version"
@@ -61,6 +74,398 @@ func TestIntegrationGitRegistryResolveLatestBlushInMemory(t *testing.T) {
 	}
 }
 
+// bareRemote is a bare repository on disk, which serves as remote.
+type bareRemote struct {
+	t    *testing.T
+	url  string
+	repo *git.Repository
+}
+
+func newBareRemote(t *testing.T) *bareRemote {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skipf("git is required to serve local remotes: %s", err)
+	}
+	dir := t.TempDir()
+	storer := filesystem.NewStorage(osfs.New(dir), cache.NewObjectLRUDefault())
+	repo, err := git.Init(storer, memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &bareRemote{t: t, url: dir, repo: repo}
+}
+
+// release commits the contents and tags the commit.
+func (r *bareRemote) release(tag string, contents string) {
+	r.t.Helper()
+	hash := r.commit(contents)
+	if _, err := r.repo.CreateTag(tag, hash, nil); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// commit commits the contents on the current branch.
+func (r *bareRemote) commit(contents string) plumbing.Hash {
+	r.t.Helper()
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if err := billyutil.WriteFile(wt.Filesystem, "main.blush", []byte(contents), 0o644); err != nil {
+		r.t.Fatal(err)
+	}
+	if _, err := wt.Add("main.blush"); err != nil {
+		r.t.Fatal(err)
+	}
+	hash, err := wt.Commit(contents, &git.CommitOptions{
+		Author: &object.Signature{Name: "blush", Email: "blush@example.com", When: time.Now()},
+	})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return hash
+}
+
+func packageVersions(pkgs []registry.Package) []string {
+	vs := make([]string, len(pkgs))
+	for i, pkg := range pkgs {
+		vs[i] = pkg.Version().String()
+	}
+	return vs
+}
+
+func TestGitRegistryCachesRemoteReferences(t *testing.T) {
+	ctx := context.Background()
+	remote := newBareRemote(t)
+	remote.release("v1.0.0", "let version = 1")
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rootfs := memfs.New()
+	reg := gitreg.New(rootfs, gitreg.WithCacheTTL(time.Hour), gitreg.WithClock(func() time.Time {
+		return now
+	}))
+
+	pkgs, err := reg.DiscoverPackageVersions(ctx, remote.url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := packageVersions(pkgs); !slices.Equal(got, []string{"1.0.0"}) {
+		t.Errorf("expected [1.0.0], got %v", got)
+	}
+
+	remote.release("v1.1.0", "let version = 2")
+	now = now.Add(30 * time.Minute)
+	pkgs, err = reg.DiscoverPackageVersions(ctx, remote.url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := packageVersions(pkgs); !slices.Equal(got, []string{"1.0.0"}) {
+		t.Errorf("expected cached [1.0.0], got %v", got)
+	}
+
+	// the cache is persisted within the registry root
+	reopened := gitreg.New(rootfs, gitreg.WithCacheTTL(time.Hour), gitreg.WithClock(func() time.Time {
+		return now
+	}))
+	pkgs, err = reopened.DiscoverPackageVersions(ctx, remote.url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := packageVersions(pkgs); !slices.Equal(got, []string{"1.0.0"}) {
+		t.Errorf("expected persisted [1.0.0], got %v", got)
+	}
+
+	now = now.Add(time.Hour)
+	pkgs, err = reg.DiscoverPackageVersions(ctx, remote.url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := packageVersions(pkgs); !slices.Equal(got, []string{"1.1.0", "1.0.0"}) {
+		t.Errorf("expected expired cache to list [1.1.0 1.0.0], got %v", got)
+	}
+
+	// the cache file must not be mistaken for a package
+	locals, err := reg.Discover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locals) != 0 {
+		t.Errorf("expected no local packages, got %v", locals)
+	}
+}
+
+func TestGitRegistryWithoutCache(t *testing.T) {
+	ctx := context.Background()
+	remote := newBareRemote(t)
+	remote.release("v1.0.0", "let version = 1")
+	reg := gitreg.New(memfs.New(), gitreg.WithCacheTTL(0))
+
+	if _, err := reg.DiscoverPackageVersions(ctx, remote.url); err != nil {
+		t.Fatal(err)
+	}
+	remote.release("v1.1.0", "let version = 2")
+	pkgs, err := reg.DiscoverPackageVersions(ctx, remote.url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := packageVersions(pkgs); !slices.Equal(got, []string{"1.1.0", "1.0.0"}) {
+		t.Errorf("expected [1.1.0 1.0.0], got %v", got)
+	}
+}
+
+func TestGitRegistryOffline(t *testing.T) {
+	ctx := context.Background()
+	remote := newBareRemote(t)
+	remote.release("v1.0.0", "let version = 1")
+	remote.release("v2.0.0", "let version = 2")
+
+	rootfs := memfs.New()
+	online := gitreg.New(rootfs)
+	pkgs, err := online.DiscoverPackageVersions(ctx, remote.url, version.ParsePredicate("^1.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 {
+		t.Fatalf("expected exactly one package, got %v", pkgs)
+	}
+	if _, err := pkgs[0].Resolve(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	offline := gitreg.New(rootfs, gitreg.WithOffline(true))
+	pkgs, err = offline.DiscoverPackageVersions(ctx, remote.url, version.ParsePredicate("^1.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := packageVersions(pkgs); !slices.Equal(got, []string{"1.0.0"}) {
+		t.Errorf("expected the local clone [1.0.0], got %v", got)
+	}
+	resolved, err := pkgs[0].Resolve(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mods, err := resolved.ResolveModules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mods) != 1 {
+		t.Errorf("expected one module, got %v", mods)
+	}
+
+	_, err = offline.DiscoverPackageVersions(ctx, remote.url, version.ParsePredicate("^2.0.0"))
+	if !errors.Is(err, gitreg.ErrOffline) {
+		t.Errorf("expected ErrOffline for a version without local clone, got %v", err)
+	}
+	_, err = offline.DiscoverPackageVersions(ctx, "https://example.com/unknown")
+	if !errors.Is(err, gitreg.ErrOffline) {
+		t.Errorf("expected ErrOffline for an unknown package, got %v", err)
+	}
+}
+
+func resolvePin(t *testing.T, reg *gitreg.GitRegistry, url string, pin string) registry.CommittedPackage {
+	t.Helper()
+	ctx := context.Background()
+	pkgs, err := reg.DiscoverPackageVersions(ctx, url, version.ParsePredicate(pin))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 {
+		t.Fatalf("expected exactly one package for %s, got %v", pin, pkgs)
+	}
+	resolved, err := pkgs[0].Resolve(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, ok := resolved.(registry.CommittedPackage)
+	if !ok {
+		t.Fatalf("expected %s to report its commit", pin)
+	}
+	if committed.Version().String() != pin {
+		t.Errorf("expected version %s, got %s", pin, committed.Version())
+	}
+	return committed
+}
+
+func TestGitRegistryPins(t *testing.T) {
+	ctx := context.Background()
+	remote := newBareRemote(t)
+	remote.release("v1.0.0", "let version = 1")
+	tagged, err := remote.repo.ResolveRevision("v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	between := remote.commit("let version = 2")
+	tip := remote.commit("let version = 3")
+
+	rootfs := memfs.New()
+	reg := gitreg.New(rootfs)
+
+	if got := resolvePin(t, reg, remote.url, "master").Commit(); got != tip.String() {
+		t.Errorf("expected branch master to resolve to %s, got %s", tip, got)
+	}
+	if got := resolvePin(t, reg, remote.url, tip.String()[:8]).Commit(); got != tip.String() {
+		t.Errorf("expected abbreviated tip to resolve to %s, got %s", tip, got)
+	}
+	if got := resolvePin(t, reg, remote.url, tagged.String()).Commit(); got != tagged.String() {
+		t.Errorf("expected full hash to resolve to %s, got %s", tagged, got)
+	}
+	committed := resolvePin(t, reg, remote.url, between.String()[:7])
+	if committed.Commit() != between.String() {
+		t.Errorf("expected abbreviated hash to resolve to %s, got %s", between, committed.Commit())
+	}
+	mods, err := committed.ResolveModules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcs, err := mods[0].Sources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := srcs[0].Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "let version = 2" {
+		t.Errorf("expected the pinned commit to be checked out, got %q", contents)
+	}
+
+	pkgs, err := reg.DiscoverPackageVersions(ctx, remote.url, version.ParsePredicate("develop"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 0 {
+		t.Errorf("expected no package for an unknown branch, got %v", pkgs)
+	}
+
+	// clones are keyed by their commit, the branch and the abbreviated tip share one
+	commits := make(map[string]bool)
+	locals, err := reg.Discover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, local := range locals {
+		commits[local.(registry.CommittedPackage).Commit()] = true
+	}
+	if len(commits) != 3 {
+		t.Errorf("expected three clones, got %v", commits)
+	}
+
+	offline := gitreg.New(rootfs, gitreg.WithOffline(true))
+	for _, pin := range []string{"master", between.String()[:7], "v1.0.0"} {
+		pkgs, err := offline.DiscoverPackageVersions(ctx, remote.url, version.ParsePredicate(pin))
+		if err != nil {
+			t.Errorf("expected %s to be available offline, got %v", pin, err)
+		} else if len(pkgs) == 0 {
+			t.Errorf("expected %s to be available offline", pin)
+		}
+	}
+}
+
+// cloneFiles finds the files whose name matches the pattern.
+func cloneFiles(t *testing.T, fs billy.Filesystem, pattern string) []string {
+	t.Helper()
+	var paths []string
+	err := billyutil.Walk(fs, "/", func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if ok, _ := path.Match(pattern, path.Base(p)); ok {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+func TestGitRegistryIntegrity(t *testing.T) {
+	ctx := context.Background()
+	remote := newBareRemote(t)
+	remote.release("v1.0.0", "let version = 1")
+
+	rootfs := memfs.New()
+	reg := gitreg.New(rootfs)
+	resolve := func() registry.ResolvedPackage {
+		t.Helper()
+		pkgs, err := reg.DiscoverPackageVersions(ctx, remote.url, version.ParsePredicate("1.0.0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pkgs) != 1 {
+			t.Fatalf("expected exactly one package, got %v", pkgs)
+		}
+		resolved, err := pkgs[0].Resolve(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resolved
+	}
+	discovered := func() int {
+		t.Helper()
+		locals, err := reg.Discover(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(locals)
+	}
+
+	resolved := resolve()
+	verifiable, ok := resolved.(registry.VerifiablePackage)
+	if !ok {
+		t.Fatal("expected clones to be verifiable")
+	}
+	if err := verifiable.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if discovered() == 0 {
+		t.Fatal("expected the clone to be discovered")
+	}
+	sums := cloneFiles(t, rootfs, "*.sum")
+	if len(sums) != 1 {
+		t.Fatalf("expected a recorded content hash next to the clone, got %v", sums)
+	}
+
+	sources := cloneFiles(t, rootfs, "main.blush")
+	if err := billyutil.WriteFile(rootfs, sources[0], []byte("let version = 666"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifiable.Verify(); !errors.Is(err, registry.ErrIntegrity) {
+		t.Errorf("expected ErrIntegrity for tampered sources, got %v", err)
+	}
+	if n := discovered(); n != 0 {
+		t.Errorf("expected tampered clones not to be discovered, got %d", n)
+	}
+	assertRefetched := func() {
+		t.Helper()
+		resolved := resolve()
+		if err := resolved.(registry.VerifiablePackage).Verify(); err != nil {
+			t.Fatal(err)
+		}
+		contents, err := billyutil.ReadFile(rootfs, sources[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(contents) != "let version = 1" {
+			t.Errorf("expected the clone to be fetched again, got %q", contents)
+		}
+	}
+	assertRefetched()
+
+	// a missing content hash marks an incomplete clone
+	if err := rootfs.Remove(sums[0]); err != nil {
+		t.Fatal(err)
+	}
+	if n := discovered(); n != 0 {
+		t.Errorf("expected incomplete clones not to be discovered, got %d", n)
+	}
+	assertRefetched()
+	if n := discovered(); n == 0 {
+		t.Error("expected the fetched clone to be discovered")
+	}
+}
+
 // func TestIntegrationGitRegistryResolveSecondLatestBlush(t *testing.T) {
 // 	ctx, cancel := context.WithCancel(context.Background())
 // 	defer cancel()
@@ -2,8 +2,11 @@ package gitreg
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/go-git/go-billy/v5"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/vknabel/blush/registry"
 	"github.com/vknabel/blush/registry/fsmodule"
 	"github.com/vknabel/blush/version"
@@ -11,7 +14,10 @@ import (
 
 type localGitPackage struct {
 	*remoteGitPackage
-	fs billy.Filesystem
+	fs     billy.Filesystem
+	commit plumbing.Hash
+	// the content hash recorded after cloning
+	integrity string
 }
 
 // Source implements registry.Package
@@ -29,6 +35,23 @@ func (p *localGitPackage) Resolve(ctx context.Context) (registry.ResolvedPackage
 	return p, nil
 }
 
+// Commit implements registry.CommittedPackage
+func (p *localGitPackage) Commit() string {
+	return p.commit.String()
+}
+
+// Verify implements registry.VerifiablePackage
+func (p *localGitPackage) Verify() error {
+	hash, err := registry.ContentHash(p)
+	if err != nil {
+		return err
+	}
+	if hash != p.integrity {
+		return fmt.Errorf("%w: package %s at commit %s has content hash %s, but %s was recorded", registry.ErrIntegrity, p.source, p.commit, hash, p.integrity)
+	}
+	return nil
+}
+
 // ResolveModules implements registry.ResolvedPackage
 func (p *localGitPackage) ResolveModules() ([]registry.ResolvedModule, error) {
 	fsmods, err := fsmodule.DiscoverModules(registry.LogicalURI(p.source), p.fs)
@@ -42,3 +65,10 @@ func (p *localGitPackage) ResolveModules() ([]registry.ResolvedModule, error) {
 	}
 	return mods, nil
 }
+
+// Cavefile implements registry.ResolvedPackage
+func (p *localGitPackage) Cavefile() (registry.Source, error) {
+	return registry.Cavefile(registry.LogicalURI(p.source), func(name string) ([]byte, error) {
+		return billyutil.ReadFile(p.fs, name)
+	})
+}
@@ -1,6 +1,8 @@
 package gitreg
 
 import (
+	"time"
+
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing/cache"
@@ -13,6 +15,33 @@ func WithDefaultOptions() func(*GitRegistry) {
 	return func(reg *GitRegistry) {
 		WithPlainRepositoryStorage()(reg)
 		WithRemoteStorageInMemory()(reg)
+		WithCacheTTL(DefaultCacheTTL)(reg)
+		WithClock(time.Now)(reg)
+	}
+}
+
+// DefaultCacheTTL is the duration the listed references of a remote are reused.
+const DefaultCacheTTL = 15 * time.Minute
+
+// WithCacheTTL reuses the listed references of remotes for the given duration.
+// A non-positive TTL disables the cache.
+func WithCacheTTL(ttl time.Duration) func(*GitRegistry) {
+	return func(reg *GitRegistry) {
+		reg.cacheTTL = ttl
+	}
+}
+
+// WithClock determines the age of cached references.
+func WithClock(now func() time.Time) func(*GitRegistry) {
+	return func(reg *GitRegistry) {
+		reg.now = now
+	}
+}
+
+// WithOffline never contacts remotes and only discovers local clones.
+func WithOffline(offline bool) func(*GitRegistry) {
+	return func(reg *GitRegistry) {
+		reg.offline = offline
 	}
 }
 
@@ -0,0 +1,83 @@
+package gitreg
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/vknabel/blush/world"
+)
+
+// refsCacheExt is appended to the mangled source to store its cached remote references.
+// As a plain file it does not interfere with the cloned package directories.
+const refsCacheExt = ".refs.json"
+
+// refsCache stores the result of listing the references of a remote.
+type refsCache struct {
+	Fetched time.Time        `json:"fetched"`
+	Refs    []refsCacheEntry `json:"refs"`
+}
+
+type refsCacheEntry struct {
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+}
+
+func newRefsCache(fetched time.Time, refs []*plumbing.Reference) refsCache {
+	cache := refsCache{Fetched: fetched}
+	for _, ref := range refs {
+		if ref.Type() != plumbing.HashReference {
+			continue
+		}
+		cache.Refs = append(cache.Refs, refsCacheEntry{
+			Name: ref.Name().String(),
+			Hash: ref.Hash().String(),
+		})
+	}
+	return cache
+}
+
+func (c refsCache) references() []*plumbing.Reference {
+	refs := make([]*plumbing.Reference, len(c.Refs))
+	for i, entry := range c.Refs {
+		refs[i] = plumbing.NewHashReference(plumbing.ReferenceName(entry.Name), plumbing.NewHash(entry.Hash))
+	}
+	return refs
+}
+
+// cachedReferences returns the cached references of the remote if they are not older than the TTL.
+func (r *GitRegistry) cachedReferences(repoUrl string) ([]*plumbing.Reference, bool, error) {
+	if r.cacheTTL <= 0 {
+		return nil, false, nil
+	}
+	data, err := util.ReadFile(r.rootfs, Mangle(repoUrl)+refsCacheExt)
+	if errors.Is(err, world.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var cache refsCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		// a corrupted cache will simply be replaced
+		return nil, false, nil
+	}
+	if r.now().Sub(cache.Fetched) > r.cacheTTL {
+		return nil, false, nil
+	}
+	return cache.references(), true, nil
+}
+
+// cacheReferences persists the listed references of the remote.
+func (r *GitRegistry) cacheReferences(repoUrl string, refs []*plumbing.Reference) error {
+	if r.cacheTTL <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(newRefsCache(r.now(), refs))
+	if err != nil {
+		return err
+	}
+	return util.WriteFile(r.rootfs, Mangle(repoUrl)+refsCacheExt, data, 0o644)
+}
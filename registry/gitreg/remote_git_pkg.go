@@ -11,9 +11,12 @@ import (
 type remoteGitPackage struct {
 	provider *GitRegistry
 
-	source       string
+	source string
+	// the reference to clone, nil for commits which are not the tip of any branch or tag
 	gitReference *plumbing.Reference
 	version      version.Version
+	// the expected commit, zero if unknown before cloning
+	commit plumbing.Hash
 }
 
 // Source implements registry.Package
@@ -0,0 +1,147 @@
+package localreg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/go-git/go-billy/v5"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	"github.com/vknabel/blush/cavefile"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/fsmodule"
+	"github.com/vknabel/blush/version"
+	"github.com/vknabel/blush/world"
+)
+
+// LocalRegistry serves packages from directories relative to the project.
+// Package names are slash separated paths relative to the root Cavefile and may leave the project like ../other.
+//
+//	<root>/
+//	├── Cavefile
+//	└── <package path>/
+//		├── Cavefile
+//		└── <submodule>/
+//
+// The version of a package is declared by @cave.Version on its dependencies.
+// Packages without a declared version have version 0.0.0.
+type LocalRegistry struct {
+	fs   billy.Filesystem
+	root string
+}
+
+// New creates a registry for the project at the root directory of the file system.
+func New(fs billy.Filesystem, root string) *LocalRegistry {
+	return &LocalRegistry{
+		fs:   fs,
+		root: root,
+	}
+}
+
+// Discover implements registry.Provider.
+// Local packages are only discovered on demand.
+func (r *LocalRegistry) Discover(ctx context.Context) ([]registry.ResolvedPackage, error) {
+	return nil, nil
+}
+
+// DiscoverPackageVersions implements registry.Provider.
+// Returns the package at the given path if its version matches all predicates.
+func (r *LocalRegistry) DiscoverPackageVersions(ctx context.Context, pkgPath string, predicates ...version.Predicate) ([]registry.Package, error) {
+	pkg, err := r.open(pkgPath)
+	if err != nil || pkg == nil {
+		return nil, err
+	}
+	for _, pred := range predicates {
+		if !pkg.version.Matches(pred) {
+			return nil, nil
+		}
+	}
+	return []registry.Package{pkg}, nil
+}
+
+func (r *LocalRegistry) open(pkgPath string) (*localPackage, error) {
+	pkgPath = path.Clean(pkgPath)
+	dir := path.Join(r.root, pkgPath)
+	info, err := r.fs.Stat(dir)
+	if errors.Is(err, world.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("local package %s is not a directory", pkgPath)
+	}
+	pkgfs, err := r.fs.Chroot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &localPackage{
+		source:  pkgPath,
+		fs:      pkgfs,
+		version: version.SemverVersion{},
+	}
+	cave, err := pkg.Cavefile()
+	if err != nil || cave == nil {
+		return pkg, err
+	}
+	manifest, errs, err := cavefile.Load(cave)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		joined := make([]error, len(errs))
+		for i, e := range errs {
+			joined[i] = e
+		}
+		return nil, fmt.Errorf("invalid Cavefile of %s: %w", pkgPath, errors.Join(joined...))
+	}
+	if manifest.Version != nil {
+		pkg.version = manifest.Version
+	}
+	return pkg, nil
+}
+
+type localPackage struct {
+	source  string
+	fs      billy.Filesystem
+	version version.Version
+}
+
+// Source implements registry.Package
+func (p *localPackage) Source() string {
+	return p.source
+}
+
+// Version implements registry.Package
+func (p *localPackage) Version() version.Version {
+	return p.version
+}
+
+// Resolve implements registry.Package
+func (p *localPackage) Resolve(ctx context.Context) (registry.ResolvedPackage, error) {
+	return p, nil
+}
+
+// ResolveModules implements registry.ResolvedPackage
+func (p *localPackage) ResolveModules() ([]registry.ResolvedModule, error) {
+	fsmods, err := fsmodule.DiscoverModules(registry.LogicalURI(p.source), p.fs)
+	if err != nil {
+		return nil, err
+	}
+
+	mods := make([]registry.ResolvedModule, len(fsmods))
+	for i, m := range fsmods {
+		mods[i] = m
+	}
+	return mods, nil
+}
+
+// Cavefile implements registry.ResolvedPackage
+func (p *localPackage) Cavefile() (registry.Source, error) {
+	return registry.Cavefile(registry.LogicalURI(p.source), func(name string) ([]byte, error) {
+		return billyutil.ReadFile(p.fs, name)
+	})
+}
@@ -0,0 +1,70 @@
+package localreg_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	"github.com/vknabel/blush/registry/localreg"
+	"github.com/vknabel/blush/version"
+)
+
+func TestLocalRegistryDiscoverPackageVersions(t *testing.T) {
+	fs := memfs.New()
+	files := map[string]string{
+		"/project/Cavefile":         "import cave",
+		"/lib/Cavefile":             "import cave\n@cave.Dependencies()\n@cave.Version(\"1.2.0\")\ndata Deps {}",
+		"/lib/main.blush":           "let a = 1",
+		"/lib/strings/main.blush":   "let b = 2",
+		"/project/tools/tool.blush": "let c = 3",
+	}
+	for name, contents := range files {
+		if err := billyutil.WriteFile(fs, name, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	reg := localreg.New(fs, "/project")
+
+	pkgs, err := reg.DiscoverPackageVersions(ctx, "../lib/", version.ParsePredicate("^1.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 {
+		t.Fatalf("expected exactly one package, got %v", pkgs)
+	}
+	if pkgs[0].Source() != "../lib" || pkgs[0].Version().String() != "1.2.0" {
+		t.Errorf("expected ../lib@1.2.0, got %s@%s", pkgs[0].Source(), pkgs[0].Version())
+	}
+	resolved, err := pkgs[0].Resolve(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mods, err := resolved.ResolveModules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mods) != 2 {
+		t.Errorf("expected two modules, got %v", mods)
+	}
+
+	pkgs, err = reg.DiscoverPackageVersions(ctx, "../lib", version.ParsePredicate("^2.0.0"))
+	if err != nil || len(pkgs) != 0 {
+		t.Errorf("expected no matching version, got %v, %v", pkgs, err)
+	}
+
+	pkgs, err = reg.DiscoverPackageVersions(ctx, "tools")
+	if err != nil || len(pkgs) != 1 || pkgs[0].Version().String() != "0.0.0" {
+		t.Errorf("expected unversioned package, got %v, %v", pkgs, err)
+	}
+
+	pkgs, err = reg.DiscoverPackageVersions(ctx, "missing")
+	if err != nil || len(pkgs) != 0 {
+		t.Errorf("expected no missing package, got %v, %v", pkgs, err)
+	}
+
+	if _, err = reg.DiscoverPackageVersions(ctx, "Cavefile"); err == nil {
+		t.Error("expected files to be rejected")
+	}
+}
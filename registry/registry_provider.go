@@ -3,6 +3,7 @@ package registry
 
 import (
 	"context"
+	"errors"
 	"strings"
 
 	"github.com/vknabel/blush/version"
@@ -13,8 +14,8 @@ import (
 //
 // The expected folder structure in increasing priority is:
 //
-//	 $BLUSH_STDLIB/
-//	 └── git/<package>/<version>/
+//	 $BLUSH_STDLIB/ (overrides the embedded standard library)
+//	 └── <package>/
 //		 ├── Cavefile
 //	 	 └── <submodule>/
 //	 $BLUSH_PACKAGES/
@@ -23,9 +24,11 @@ import (
 //	 	 └── <submodule>/
 //	 <package>
 //	 ├── Cavefile
-//	 ├── <vendored-package>/
-//	 │	 ├── Cavefile
-//	 │	 └── <submodule>/
+//	 ├── vendor/
+//	 │	 ├── vendor.json
+//	 │	 └── <vendored-package>/
+//	 │		 ├── Cavefile
+//	 │		 └── <submodule>/
 //	 └── <submodule>/
 //
 // Each Cavefile describes the package and its dependencies.
@@ -48,6 +51,25 @@ type ResolvedPackage interface {
 	Package
 	// ResolveModules discovers all nested modules.
 	ResolveModules() ([]ResolvedModule, error)
+	// Cavefile returns the manifest of the package or nil if the package has none.
+	Cavefile() (Source, error)
+}
+
+// CommittedPackage is a package resolved from a specific commit of a version control system.
+type CommittedPackage interface {
+	ResolvedPackage
+	// Commit returns the hash of the checked out commit.
+	Commit() string
+}
+
+// ErrIntegrity is returned by packages whose contents have changed since they have been fetched.
+var ErrIntegrity = errors.New("integrity check failed")
+
+// VerifiablePackage is a package, which recorded its ContentHash when it has been fetched.
+type VerifiablePackage interface {
+	ResolvedPackage
+	// Verify fails with ErrIntegrity if the contents differ from the recorded ContentHash.
+	Verify() error
 }
 
 type ResolvedModule interface {
@@ -0,0 +1,245 @@
+package stdlibreg
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"github.com/go-git/go-billy/v5"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/fsmodule"
+	"github.com/vknabel/blush/registry/staticmodule"
+	"github.com/vknabel/blush/stdlib"
+	"github.com/vknabel/blush/version"
+	"github.com/vknabel/blush/world"
+)
+
+const (
+	// EnvStdlib names the environment variable, which overrides the embedded standard library with a directory.
+	EnvStdlib = "BLUSH_STDLIB"
+	// PreludeName is the package, which is implicitly imported into every module.
+	PreludeName = "prelude"
+
+	sourceExt = ".blush"
+)
+
+// StdlibRegistry serves the packages of the standard library like prelude or cave.
+// All packages are versioned with the toolchain.
+// By default the sources embedded into the toolchain are served.
+// Alternatively a directory may override them.
+//
+//	<root>/
+//	└── <package>/
+//		├── Cavefile
+//		└── <submodule>/
+type StdlibRegistry struct {
+	// the embedded sources are served, if there is no directory
+	dir     billy.Filesystem
+	version version.Version
+}
+
+// New serves the standard library embedded into the toolchain.
+func New() *StdlibRegistry {
+	return &StdlibRegistry{
+		version: version.Parse(stdlib.Version),
+	}
+}
+
+// NewDir serves the standard library from the root of the given file system.
+func NewDir(dir billy.Filesystem) *StdlibRegistry {
+	return &StdlibRegistry{
+		dir:     dir,
+		version: version.Parse(stdlib.Version),
+	}
+}
+
+// FromWorld serves the standard library from the directory of $BLUSH_STDLIB if set.
+// Otherwise the embedded standard library is served.
+func FromWorld(w world.World) (*StdlibRegistry, error) {
+	root := w.OS.Getenv(EnvStdlib)
+	if root == "" {
+		return New(), nil
+	}
+	info, err := w.FS.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $%s, %w", EnvStdlib, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("invalid $%s, %s is not a directory", EnvStdlib, root)
+	}
+	dir, err := w.FS.Chroot(root)
+	if err != nil {
+		return nil, err
+	}
+	return NewDir(dir), nil
+}
+
+// Discover implements registry.Provider.
+// Returns all packages of the standard library.
+func (r *StdlibRegistry) Discover(ctx context.Context) ([]registry.ResolvedPackage, error) {
+	names, err := r.packageNames()
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make([]registry.ResolvedPackage, len(names))
+	for i, name := range names {
+		pkgs[i] = r.pkg(name)
+	}
+	return pkgs, nil
+}
+
+// DiscoverPackageVersions implements registry.Provider.
+// Returns the package with the given name if the toolchain version matches all predicates.
+func (r *StdlibRegistry) DiscoverPackageVersions(ctx context.Context, name string, predicates ...version.Predicate) ([]registry.Package, error) {
+	names, err := r.packageNames()
+	if err != nil {
+		return nil, err
+	}
+	for _, pkgName := range names {
+		if pkgName != name {
+			continue
+		}
+		for _, pred := range predicates {
+			if !r.version.Matches(pred) {
+				return nil, nil
+			}
+		}
+		return []registry.Package{r.pkg(name)}, nil
+	}
+	return nil, nil
+}
+
+// Prelude returns the root module of the prelude package.
+func (r *StdlibRegistry) Prelude() (registry.ResolvedModule, error) {
+	pkg := r.pkg(PreludeName)
+	mods, err := pkg.ResolveModules()
+	if err != nil {
+		return nil, err
+	}
+	for _, mod := range mods {
+		if mod.URI() == pkg.uri() {
+			return mod, nil
+		}
+	}
+	return nil, fmt.Errorf("standard library has no %s", PreludeName)
+}
+
+func (r *StdlibRegistry) packageNames() ([]string, error) {
+	var names []string
+	if r.dir != nil {
+		entries, err := r.dir.ReadDir(".")
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+		return names, nil
+	}
+
+	entries, err := fs.ReadDir(stdlib.Sources, ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func (r *StdlibRegistry) pkg(name string) *stdlibPackage {
+	return &stdlibPackage{
+		name:     name,
+		registry: r,
+	}
+}
+
+type stdlibPackage struct {
+	name     string
+	registry *StdlibRegistry
+}
+
+func (p *stdlibPackage) uri() registry.LogicalURI {
+	return registry.LogicalURI("stdlib:///").Join(p.name)
+}
+
+// Source implements registry.Package
+func (p *stdlibPackage) Source() string {
+	return p.name
+}
+
+// Version implements registry.Package
+func (p *stdlibPackage) Version() version.Version {
+	return p.registry.version
+}
+
+// Resolve implements registry.Package
+func (p *stdlibPackage) Resolve(ctx context.Context) (registry.ResolvedPackage, error) {
+	return p, nil
+}
+
+// ResolveModules implements registry.ResolvedPackage
+func (p *stdlibPackage) ResolveModules() ([]registry.ResolvedModule, error) {
+	if p.registry.dir != nil {
+		pkgfs, err := p.registry.dir.Chroot(p.name)
+		if err != nil {
+			return nil, err
+		}
+		fsmods, err := fsmodule.DiscoverModules(p.uri(), pkgfs)
+		if err != nil {
+			return nil, err
+		}
+		mods := make([]registry.ResolvedModule, len(fsmods))
+		for i, m := range fsmods {
+			mods[i] = m
+		}
+		return mods, nil
+	}
+
+	var mods []registry.ResolvedModule
+	byDir := make(map[string]*staticmodule.StaticModule)
+	err := fs.WalkDir(stdlib.Sources, p.name, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || path.Ext(file) != sourceExt {
+			return err
+		}
+		contents, err := fs.ReadFile(stdlib.Sources, file)
+		if err != nil {
+			return err
+		}
+		dir := path.Dir(file)
+		mod, ok := byDir[dir]
+		if !ok {
+			uri := p.uri()
+			if dir != p.name {
+				uri = uri.Join(dir[len(p.name)+1:])
+			}
+			mod = staticmodule.NewModule(uri, nil)
+			byDir[dir] = mod
+			mods = append(mods, mod)
+		}
+		uri := p.uri().Join(file[len(p.name)+1:])
+		mod.Srcs = append(mod.Srcs, staticmodule.NewSource(uri, contents))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover modules of %q, %w", p.uri(), err)
+	}
+	return mods, nil
+}
+
+// Cavefile implements registry.ResolvedPackage
+func (p *stdlibPackage) Cavefile() (registry.Source, error) {
+	return registry.Cavefile(p.uri(), func(name string) ([]byte, error) {
+		name = path.Join(p.name, name)
+		if p.registry.dir != nil {
+			return billyutil.ReadFile(p.registry.dir, name)
+		}
+		return fs.ReadFile(stdlib.Sources, name)
+	})
+}
@@ -0,0 +1,141 @@
+package stdlibreg_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/stdlibreg"
+	"github.com/vknabel/blush/stdlib"
+	"github.com/vknabel/blush/version"
+	"github.com/vknabel/blush/world"
+)
+
+func TestStdlibRegistryDiscoverEmbedded(t *testing.T) {
+	pkgs, err := stdlibreg.New().Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := make(map[string]bool)
+	for _, pkg := range pkgs {
+		sources[pkg.Source()] = true
+		if pkg.Version().String() != stdlib.Version {
+			t.Errorf("expected %s@%s, got %s", pkg.Source(), stdlib.Version, pkg.Version())
+		}
+	}
+	for _, name := range []string{"cave", "prelude"} {
+		if !sources[name] {
+			t.Errorf("expected package %s, got %v", name, sources)
+		}
+	}
+}
+
+func TestStdlibRegistryDiscoverPackageVersions(t *testing.T) {
+	ctx := context.Background()
+	reg := stdlibreg.New()
+
+	pkgs, err := reg.DiscoverPackageVersions(ctx, "cave", version.ParsePredicate(">="+stdlib.Version))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 1 || pkgs[0].Source() != "cave" {
+		t.Fatalf("expected exactly cave, got %v", pkgs)
+	}
+	resolved, err := pkgs[0].Resolve(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mods, err := resolved.ResolveModules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	uris := make(map[registry.LogicalURI]bool)
+	for _, mod := range mods {
+		uris[mod.URI()] = true
+	}
+	if !uris["stdlib:///cave"] || !uris["stdlib:///cave/tasks"] {
+		t.Errorf("expected cave and cave/tasks, got %v", uris)
+	}
+
+	pkgs, err = reg.DiscoverPackageVersions(ctx, "cave", version.ParsePredicate(">99.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 0 {
+		t.Errorf("expected no package for a foreign toolchain version, got %v", pkgs)
+	}
+
+	pkgs, err = reg.DiscoverPackageVersions(ctx, "unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pkgs) != 0 {
+		t.Errorf("expected no unknown package, got %v", pkgs)
+	}
+}
+
+func TestStdlibRegistryPrelude(t *testing.T) {
+	prelude, err := stdlibreg.New().Prelude()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prelude.URI() != "stdlib:///prelude" {
+		t.Errorf("expected stdlib:///prelude, got %s", prelude.URI())
+	}
+	srcs, err := prelude.Sources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(srcs) != 3 {
+		t.Errorf("expected three prelude sources, got %d", len(srcs))
+	}
+}
+
+type envOS struct {
+	env map[string]string
+}
+
+func (o envOS) Exit(code int)            {}
+func (o envOS) Stdout() io.Writer        { return io.Discard }
+func (o envOS) Stderr() io.Writer        { return io.Discard }
+func (o envOS) Getenv(key string) string { return o.env[key] }
+
+func TestStdlibRegistryFromWorld(t *testing.T) {
+	fs := memfs.New()
+	err := billyutil.WriteFile(fs, "/opt/stdlib/prelude/main.blush", []byte("let answer = 42"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := world.World{FS: fs, OS: envOS{env: map[string]string{stdlibreg.EnvStdlib: "/opt/stdlib"}}}
+
+	reg, err := stdlibreg.FromWorld(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prelude, err := reg.Prelude()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcs, err := prelude.Sources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(srcs) != 1 {
+		t.Fatalf("expected only the overridden prelude source, got %d", len(srcs))
+	}
+	contents, err := srcs[0].Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "let answer = 42" {
+		t.Errorf("expected overridden contents, got %q", contents)
+	}
+
+	w.OS = envOS{env: map[string]string{stdlibreg.EnvStdlib: "/missing"}}
+	if _, err := stdlibreg.FromWorld(w); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
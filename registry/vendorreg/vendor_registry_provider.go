@@ -0,0 +1,210 @@
+package vendorreg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/fsmodule"
+	"github.com/vknabel/blush/registry/gitreg"
+	"github.com/vknabel/blush/version"
+	"github.com/vknabel/blush/world"
+)
+
+// ManifestName is the file within the vendor directory, which lists all vendored packages.
+const ManifestName = "vendor.json"
+
+// VendorRegistry serves packages, which have been copied into the project.
+// Vendored packages are verified against the content hash recorded while vendoring.
+//
+//	<vendor>/
+//	├── vendor.json
+//	└── <package>/
+//		├── Cavefile
+//		└── <submodule>/
+type VendorRegistry struct {
+	fs  billy.Filesystem
+	dir string
+}
+
+// New serves the packages vendored into the directory of the file system.
+func New(fs billy.Filesystem, dir string) *VendorRegistry {
+	return &VendorRegistry{
+		fs:  fs,
+		dir: dir,
+	}
+}
+
+// Manifest lists all vendored packages.
+type Manifest struct {
+	Packages []VendoredPackage `json:"packages"`
+}
+
+// VendoredPackage describes a package copied into the vendor directory.
+type VendoredPackage struct {
+	Source  string `json:"source"`
+	Version string `json:"version"`
+	// The commit of packages from version control, if any.
+	Commit string `json:"commit,omitempty"`
+	// The content hash of the package, see registry.ContentHash.
+	Hash string `json:"hash"`
+	// The directory of the package relative to the vendor directory.
+	Dir string `json:"dir"`
+}
+
+// ReadManifest reads the manifest of the vendor directory.
+// Returns nil if nothing has been vendored.
+func ReadManifest(fs billy.Filesystem, dir string) (*Manifest, error) {
+	data, err := billyutil.ReadFile(fs, path.Join(dir, ManifestName))
+	if errors.Is(err, world.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ManifestName, err)
+	}
+	return &manifest, nil
+}
+
+// WriteManifest writes the manifest of the vendor directory sorted by source.
+func WriteManifest(fs billy.Filesystem, dir string, manifest Manifest) error {
+	manifest.Packages = append([]VendoredPackage(nil), manifest.Packages...)
+	sort.Slice(manifest.Packages, func(i, j int) bool {
+		return manifest.Packages[i].Source < manifest.Packages[j].Source
+	})
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return billyutil.WriteFile(fs, path.Join(dir, ManifestName), append(data, '\n'), 0o644)
+}
+
+// PackageDir returns the directory name of the vendored source.
+// Like clones of the git registry, it is named by the mangled source.
+func PackageDir(source string) string {
+	return gitreg.Mangle(source)
+}
+
+// Discover implements registry.Provider.
+// Returns all vendored packages.
+func (r *VendorRegistry) Discover(ctx context.Context) ([]registry.ResolvedPackage, error) {
+	manifest, err := ReadManifest(r.fs, r.dir)
+	if err != nil || manifest == nil {
+		return nil, err
+	}
+	pkgs := make([]registry.ResolvedPackage, 0, len(manifest.Packages))
+	for _, entry := range manifest.Packages {
+		pkg, err := r.open(entry)
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+// DiscoverPackageVersions implements registry.Provider.
+// Returns the vendored package of the source if it matches all predicates.
+func (r *VendorRegistry) DiscoverPackageVersions(ctx context.Context, source string, predicates ...version.Predicate) ([]registry.Package, error) {
+	pkgs, err := r.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var matches []registry.Package
+outer:
+	for _, pkg := range pkgs {
+		if pkg.Source() != source {
+			continue
+		}
+		for _, pred := range predicates {
+			if !pkg.Version().Matches(pred) {
+				continue outer
+			}
+		}
+		matches = append(matches, pkg)
+	}
+	return matches, nil
+}
+
+func (r *VendorRegistry) open(entry VendoredPackage) (*vendoredPackage, error) {
+	if entry.Dir == "" || entry.Dir == "." || entry.Dir == ".." || strings.Contains(entry.Dir, "/") {
+		return nil, fmt.Errorf("invalid %s: package %s is vendored outside of %s", ManifestName, entry.Source, r.dir)
+	}
+	pkgfs, err := r.fs.Chroot(path.Join(r.dir, entry.Dir))
+	if err != nil {
+		return nil, err
+	}
+	return &vendoredPackage{
+		entry:   entry,
+		fs:      pkgfs,
+		version: version.Parse(entry.Version),
+	}, nil
+}
+
+type vendoredPackage struct {
+	entry   VendoredPackage
+	fs      billy.Filesystem
+	version version.Version
+}
+
+// Source implements registry.Package
+func (p *vendoredPackage) Source() string {
+	return p.entry.Source
+}
+
+// Version implements registry.Package
+func (p *vendoredPackage) Version() version.Version {
+	return p.version
+}
+
+// Resolve implements registry.Package
+func (p *vendoredPackage) Resolve(ctx context.Context) (registry.ResolvedPackage, error) {
+	return p, nil
+}
+
+// Commit implements registry.CommittedPackage
+func (p *vendoredPackage) Commit() string {
+	return p.entry.Commit
+}
+
+// Verify implements registry.VerifiablePackage
+func (p *vendoredPackage) Verify() error {
+	hash, err := registry.ContentHash(p)
+	if err != nil {
+		return err
+	}
+	if hash != p.entry.Hash {
+		return fmt.Errorf("%w: vendored package %s has content hash %s, but %s was recorded", registry.ErrIntegrity, p.entry.Source, hash, p.entry.Hash)
+	}
+	return nil
+}
+
+// ResolveModules implements registry.ResolvedPackage
+func (p *vendoredPackage) ResolveModules() ([]registry.ResolvedModule, error) {
+	fsmods, err := fsmodule.DiscoverModules(registry.LogicalURI(p.entry.Source), p.fs)
+	if err != nil {
+		return nil, err
+	}
+	mods := make([]registry.ResolvedModule, len(fsmods))
+	for i, m := range fsmods {
+		mods[i] = m
+	}
+	return mods, nil
+}
+
+// Cavefile implements registry.ResolvedPackage
+func (p *vendoredPackage) Cavefile() (registry.Source, error) {
+	return registry.Cavefile(registry.LogicalURI(p.entry.Source), func(name string) ([]byte, error) {
+		return billyutil.ReadFile(p.fs, name)
+	})
+}
@@ -0,0 +1,124 @@
+package vendorreg_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	billyutil "github.com/go-git/go-billy/v5/util"
+	"github.com/vknabel/blush/registry"
+	"github.com/vknabel/blush/registry/vendorreg"
+	"github.com/vknabel/blush/version"
+)
+
+func TestVendorRegistry(t *testing.T) {
+	fs := memfs.New()
+	dir := vendorreg.PackageDir("github.com/vknabel/strings")
+	files := map[string]string{
+		"/vendor/" + dir + "/Cavefile":           "import cave",
+		"/vendor/" + dir + "/main.blush":         "let a = 1",
+		"/vendor/" + dir + "/unicode/main.blush": "let b = 2",
+	}
+	for name, contents := range files {
+		if err := billyutil.WriteFile(fs, name, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entry := vendorreg.VendoredPackage{
+		Source:  "github.com/vknabel/strings",
+		Version: "1.2.0",
+		Commit:  "0123456789abcdef0123456789abcdef01234567",
+		Dir:     dir,
+	}
+	ctx := context.Background()
+	reg := vendorreg.New(fs, "/vendor")
+
+	pkgs, err := reg.Discover(ctx)
+	if err != nil || len(pkgs) != 0 {
+		t.Fatalf("expected nothing vendored without manifest, got %v, %v", pkgs, err)
+	}
+
+	// record the hash of the vendored contents
+	if err := vendorreg.WriteManifest(fs, "/vendor", vendorreg.Manifest{Packages: []vendorreg.VendoredPackage{entry}}); err != nil {
+		t.Fatal(err)
+	}
+	pkgs, err = reg.Discover(ctx)
+	if err != nil || len(pkgs) != 1 {
+		t.Fatalf("expected one vendored package, got %v, %v", pkgs, err)
+	}
+	entry.Hash, err = registry.ContentHash(pkgs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vendorreg.WriteManifest(fs, "/vendor", vendorreg.Manifest{Packages: []vendorreg.VendoredPackage{entry}}); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := reg.DiscoverPackageVersions(ctx, "github.com/vknabel/strings", version.ParsePredicate("^1.0.0"))
+	if err != nil || len(versions) != 1 {
+		t.Fatalf("expected matching vendored package, got %v, %v", versions, err)
+	}
+	if versions[0].Version().String() != "1.2.0" {
+		t.Errorf("expected version 1.2.0, got %s", versions[0].Version())
+	}
+	resolved, err := versions[0].Resolve(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if committed, ok := resolved.(registry.CommittedPackage); !ok || committed.Commit() != entry.Commit {
+		t.Errorf("expected commit %s, got %v", entry.Commit, resolved)
+	}
+	mods, err := resolved.ResolveModules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mods) != 2 {
+		t.Errorf("expected two modules, got %v", mods)
+	}
+	for _, mod := range mods {
+		if uri := string(mod.URI()); uri != "github.com/vknabel/strings" && uri != "github.com/vknabel/strings/unicode" {
+			t.Errorf("expected logical module URIs of the source, got %s", uri)
+		}
+	}
+	if err := resolved.(registry.VerifiablePackage).Verify(); err != nil {
+		t.Errorf("expected vendored package to be intact, got %v", err)
+	}
+
+	versions, err = reg.DiscoverPackageVersions(ctx, "github.com/vknabel/strings", version.ParsePredicate("^2.0.0"))
+	if err != nil || len(versions) != 0 {
+		t.Errorf("expected no matching version, got %v, %v", versions, err)
+	}
+	versions, err = reg.DiscoverPackageVersions(ctx, "github.com/vknabel/missing")
+	if err != nil || len(versions) != 0 {
+		t.Errorf("expected no missing package, got %v, %v", versions, err)
+	}
+
+	// tampered contents
+	if err := billyutil.WriteFile(fs, "/vendor/"+dir+"/main.blush", []byte("let a = 2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := resolved.(registry.VerifiablePackage).Verify(); !errors.Is(err, registry.ErrIntegrity) {
+		t.Errorf("expected ErrIntegrity, got %v", err)
+	}
+
+	// escaping the vendor directory
+	entry.Dir = ".."
+	if err := vendorreg.WriteManifest(fs, "/vendor", vendorreg.Manifest{Packages: []vendorreg.VendoredPackage{entry}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.Discover(ctx); err == nil {
+		t.Error("expected vendored packages outside of the vendor directory to be rejected")
+	}
+}
+
+func TestPackageDir(t *testing.T) {
+	a := vendorreg.PackageDir("github.com/vknabel/a-b")
+	b := vendorreg.PackageDir("github.com/vknabel/a_b")
+	if a == b {
+		t.Errorf("expected distinct directories, got %s", a)
+	}
+	if want := "github.com-vknabel-a-b-"; a[:len(want)] != want {
+		t.Errorf("expected readable directory, got %s", a)
+	}
+}
@@ -1,7 +1,13 @@
 package runtime
 
-import "github.com/vknabel/blush/ast"
+import (
+	"fmt"
 
+	"github.com/vknabel/blush/ast"
+)
+
+// ExternPlugin provides the Go implementations of extern declarations.
+// Returns nil if the plugin does not know the declaration.
 type ExternPlugin interface {
 	Bind(module *ast.SymbolTable, decl *ast.Symbol) RuntimeValue
 }
@@ -10,6 +16,25 @@ type ExternPluginRegistry struct {
 	plugins []ExternPlugin
 }
 
+func MakeExternPluginRegistry(plugins ...ExternPlugin) *ExternPluginRegistry {
+	return &ExternPluginRegistry{plugins: plugins}
+}
+
+// Register adds another plugin. Earlier plugins take precedence.
+func (r *ExternPluginRegistry) Register(plugin ExternPlugin) {
+	r.plugins = append(r.plugins, plugin)
+}
+
+// Bind asks all plugins for the implementation of an extern declaration.
+func (r *ExternPluginRegistry) Bind(module *ast.SymbolTable, decl *ast.Symbol) (RuntimeValue, error) {
+	for _, p := range r.plugins {
+		if val := p.Bind(module, decl); val != nil {
+			return val, nil
+		}
+	}
+	return nil, fmt.Errorf("extern %s is not provided by any plugin", decl.Name)
+}
+
 func GetPlugin[P ExternPlugin](reg *ExternPluginRegistry, ref *P) {
 	for _, p := range reg.plugins {
 		plug, ok := p.(P)
@@ -11,7 +11,10 @@ func (a Array) Inspect() string {
 
 // Lookup implements RuntimeValue.
 func (a Array) Lookup(name string) RuntimeValue {
-	panic("unimplemented")
+	if name == "length" {
+		return Int(len(a))
+	}
+	return nil
 }
 
 // TypeConstantId implements RuntimeValue.
@@ -1,5 +1,10 @@
 package runtime
 
+import (
+	"cmp"
+	"slices"
+)
+
 var _ RuntimeValue = Dict{}
 
 type Dict map[RuntimeValue]RuntimeValue
@@ -11,10 +16,53 @@ func (a Dict) Inspect() string {
 
 // Lookup implements RuntimeValue.
 func (a Dict) Lookup(name string) RuntimeValue {
-	panic("unimplemented")
+	switch name {
+	case "length":
+		return Int(len(a))
+	case "keys":
+		return a.Keys()
+	default:
+		return nil
+	}
 }
 
 // TypeConstantId implements RuntimeValue.
 func (a Dict) TypeConstantId() TypeId {
 	return typeIdDict
 }
+
+// Keys returns all keys of the dictionary in a deterministic order.
+// Keys are grouped by their type and sorted by their value within each group.
+func (a Dict) Keys() Array {
+	keys := make(Array, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, compareKeys)
+	return keys
+}
+
+func compareKeys(lhs, rhs RuntimeValue) int {
+	switch lhs := lhs.(type) {
+	case Int:
+		if rhs, ok := rhs.(Int); ok {
+			return cmp.Compare(lhs, rhs)
+		}
+	case Float:
+		if rhs, ok := rhs.(Float); ok {
+			return cmp.Compare(lhs, rhs)
+		}
+	case String:
+		if rhs, ok := rhs.(String); ok {
+			return cmp.Compare(lhs, rhs)
+		}
+	case Char:
+		if rhs, ok := rhs.(Char); ok {
+			return cmp.Compare(lhs, rhs)
+		}
+	}
+	if c := cmp.Compare(lhs.TypeConstantId(), rhs.TypeConstantId()); c != 0 {
+		return c
+	}
+	return cmp.Compare(lhs.Inspect(), rhs.Inspect())
+}
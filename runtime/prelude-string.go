@@ -2,6 +2,7 @@ package runtime
 
 import (
 	"strconv"
+	"unicode/utf8"
 )
 
 var _ RuntimeValue = String("")
@@ -15,9 +16,26 @@ func (i String) Inspect() string {
 
 // Lookup implements runtime.RuntimeValue.
 func (i String) Lookup(name string) RuntimeValue {
+	if name == "length" {
+		return Int(utf8.RuneCountInString(string(i)))
+	}
 	return nil
 }
 
+// Char returns the character at the given index, counted in characters instead of bytes.
+func (i String) Char(idx int) (Char, bool) {
+	if idx < 0 {
+		return 0, false
+	}
+	for _, ch := range string(i) {
+		if idx == 0 {
+			return Char(ch), true
+		}
+		idx--
+	}
+	return 0, false
+}
+
 // TypeConstantId implements runtime.RuntimeValue.
 func (i String) TypeConstantId() TypeId {
 	return typeIdString
@@ -37,11 +37,17 @@ func (*Prelude) Bind(module *ast.SymbolTable, decl *ast.Symbol) RuntimeValue {
 		"Func",
 		"Int",
 		"Module",
+		"ModuleType",
+		"Annotation",
+		"AnnotationType",
+		"AnyType",
 		"String",
 		"Null":
 		return SimpleType{Decl: decl}
 	case "Any":
 		return MakeAnyType(decl)
+	case "null":
+		return Null{}
 	}
 	return nil
 }
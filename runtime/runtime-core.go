@@ -1,5 +1,7 @@
 package runtime
 
+import "github.com/vknabel/blush/ast"
+
 type TypeId uint32
 
 type RuntimeValue interface {
@@ -14,3 +16,12 @@ type CallableRuntimeValue interface {
 	RuntimeValue
 	Arity() int
 }
+
+// TypeRuntimeValue is a type, which values can be matched against.
+type TypeRuntimeValue interface {
+	RuntimeValue
+	// Includes reports whether the value is of this type.
+	Includes(v RuntimeValue) bool
+	// Annotations returns the annotations of the type's declaration.
+	Annotations() ast.AnnotationChain
+}
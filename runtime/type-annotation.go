@@ -0,0 +1,37 @@
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/vknabel/blush/ast"
+)
+
+var _ RuntimeValue = &AnnotationType{}
+
+type AnnotationType struct {
+	symbol *ast.Symbol
+}
+
+func MakeAnnotationType(symbol *ast.Symbol) *AnnotationType {
+	return &AnnotationType{symbol}
+}
+
+// Name returns the name of the declaration.
+func (at *AnnotationType) Name() string {
+	return at.symbol.Name
+}
+
+// Inspect implements RuntimeValue.
+func (at *AnnotationType) Inspect() string {
+	return fmt.Sprintf("annotation %s", at.symbol.Decl.DeclName())
+}
+
+// Lookup implements RuntimeValue.
+func (*AnnotationType) Lookup(name string) RuntimeValue {
+	return nil
+}
+
+// TypeConstantId implements RuntimeValue.
+func (at *AnnotationType) TypeConstantId() TypeId {
+	return TypeId(*at.symbol.TypeSymbol.ConstantId)
+}
@@ -2,7 +2,7 @@ package runtime
 
 import "github.com/vknabel/blush/ast"
 
-var _ RuntimeValue = &AnyType{}
+var _ TypeRuntimeValue = &AnyType{}
 
 type AnyType struct {
 	symbol *ast.Symbol
@@ -26,3 +26,17 @@ func (*AnyType) Lookup(name string) RuntimeValue {
 func (at *AnyType) TypeConstantId() TypeId {
 	return TypeId(*at.symbol.ConstantId)
 }
+
+// Includes implements TypeRuntimeValue.
+func (*AnyType) Includes(v RuntimeValue) bool {
+	return true
+}
+
+// Annotations implements TypeRuntimeValue.
+func (at *AnyType) Annotations() ast.AnnotationChain {
+	decl, ok := at.symbol.Decl.(*ast.DeclExternType)
+	if !ok {
+		return nil
+	}
+	return decl.Annotations
+}
@@ -7,10 +7,15 @@ import (
 )
 
 var _ CallableRuntimeValue = &DataType{}
+var _ TypeRuntimeValue = &DataType{}
 
 type DataType struct {
 	Symbol       *ast.Symbol
 	FieldSymbols []*ast.Symbol
+
+	// The constant id of the iterate function provided by @Iterable, if any.
+	// Used by `for item <- items` loops to enumerate data values.
+	Iterate *int
 }
 
 func MakeDataType(symbol *ast.Symbol) (*DataType, error) {
@@ -21,6 +26,10 @@ func MakeDataType(symbol *ast.Symbol) (*DataType, error) {
 	fieldSymbols := make([]*ast.Symbol, len(decl.Fields))
 	for i, f := range decl.Fields {
 		for _, fsym := range symbol.ChildTable.Symbols {
+			// unresolved references like annotations have no declaration
+			if fsym.Decl == nil {
+				continue
+			}
 			if fsym.Decl.DeclName().String() == f.DeclName().String() {
 				fieldSymbols[i] = fsym
 			}
@@ -55,3 +64,14 @@ func (dt *DataType) Lookup(name string) RuntimeValue {
 func (dt *DataType) TypeConstantId() TypeId {
 	return TypeId(*dt.Symbol.TypeSymbol.ConstantId)
 }
+
+// Includes implements TypeRuntimeValue.
+func (dt *DataType) Includes(v RuntimeValue) bool {
+	dv, ok := v.(*DataValue)
+	return ok && dv.TypeId == TypeId(*dt.Symbol.ConstantId)
+}
+
+// Annotations implements TypeRuntimeValue.
+func (dt *DataType) Annotations() ast.AnnotationChain {
+	return dt.Symbol.Decl.(*ast.DeclData).Annotations
+}
@@ -10,11 +10,24 @@ var _ RuntimeValue = &EnumType{}
 
 type EnumType struct {
 	symbol *ast.Symbol
+
+	// The constant ids of the types of all cases in declaration order.
+	// Values of any of these types are values of the enum.
+	Cases []int
+}
+
+func MakeEnumType(symbol *ast.Symbol) *EnumType {
+	return &EnumType{symbol: symbol}
+}
+
+// Name returns the name of the declaration.
+func (et *EnumType) Name() string {
+	return et.symbol.Name
 }
 
 // Inspect implements RuntimeValue.
 func (et *EnumType) Inspect() string {
-	return fmt.Sprintf("data %s", et.symbol.Decl.DeclName())
+	return fmt.Sprintf("enum %s", et.symbol.Decl.DeclName())
 }
 
 // Lookup implements RuntimeValue.
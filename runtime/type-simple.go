@@ -4,10 +4,14 @@ import (
 	"github.com/vknabel/blush/ast"
 )
 
-var _ RuntimeValue = SimpleType{}
+var _ TypeRuntimeValue = SimpleType{}
 
 type SimpleType struct {
 	Decl *ast.Symbol
+
+	// The constant id of the iterate function provided by @Iterable, if any.
+	// Used by `for item <- items` loops to enumerate values of the type.
+	Iterate *int
 }
 
 // Inspect implements runtime.RuntimeValue.
@@ -24,3 +28,48 @@ func (i SimpleType) Lookup(name string) RuntimeValue {
 func (i SimpleType) TypeConstantId() TypeId {
 	return TypeId(*i.Decl.ConstantId)
 }
+
+// Includes implements runtime.TypeRuntimeValue.
+func (i SimpleType) Includes(v RuntimeValue) bool {
+	var ok bool
+	switch i.Decl.Name {
+	case "Array":
+		_, ok = v.(Array)
+	case "Bool":
+		_, ok = v.(Bool)
+	case "Char":
+		_, ok = v.(Char)
+	case "Dict":
+		_, ok = v.(Dict)
+	case "Float":
+		_, ok = v.(Float)
+	case "Int":
+		_, ok = v.(Int)
+	case "String":
+		_, ok = v.(String)
+	case "Null":
+		_, ok = v.(Null)
+	case "Func":
+		// data types are callable, but no functions
+		_, callable := v.(CallableRuntimeValue)
+		_, isType := v.(TypeRuntimeValue)
+		ok = callable && !isType
+	case "AnnotationType":
+		_, ok = v.(*AnnotationType)
+	case "AnyType":
+		_, isType := v.(TypeRuntimeValue)
+		_, isEnum := v.(*EnumType)
+		_, isAnnotation := v.(*AnnotationType)
+		ok = isType || isEnum || isAnnotation
+	}
+	return ok
+}
+
+// Annotations implements runtime.TypeRuntimeValue.
+func (i SimpleType) Annotations() ast.AnnotationChain {
+	decl, ok := i.Decl.Decl.(*ast.DeclExternType)
+	if !ok {
+		return nil
+	}
+	return decl.Annotations
+}
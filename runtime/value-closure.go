@@ -5,13 +5,14 @@ import (
 )
 
 var _ CallableRuntimeValue = &Closure{}
+var _ RuntimeValue = &Cell{}
 
 type Closure struct {
 	Fn   *CompiledFunction
-	Free []RuntimeValue
+	Free []*Cell
 }
 
-func MakeClosure(fun *CompiledFunction, free []RuntimeValue) *Closure {
+func MakeClosure(fun *CompiledFunction, free []*Cell) *Closure {
 	return &Closure{
 		Fn:   fun,
 		Free: free,
@@ -25,7 +26,7 @@ func (c *Closure) Arity() int {
 
 // Inspect implements CallableRuntimeValue.
 func (c *Closure) Inspect() string {
-	return fmt.Sprintf("func %s(#%d)", c.Fn.Symbol.Decl.DeclName(), c.Arity())
+	return fmt.Sprintf("func %s(#%d)", c.Fn.Symbol.Name, c.Arity())
 }
 
 // Lookup implements CallableRuntimeValue.
@@ -40,3 +41,29 @@ func (c *Closure) Lookup(name string) RuntimeValue {
 func (c *Closure) TypeConstantId() TypeId {
 	return TypeId(*c.Fn.Symbol.TypeSymbol.ConstantId)
 }
+
+// Cell holds a captured variable.
+// The declaring function and all closures capturing the variable share the cell,
+// thus assignments are visible to all of them.
+type Cell struct {
+	Value RuntimeValue
+}
+
+// Inspect implements RuntimeValue.
+func (c *Cell) Inspect() string {
+	if c.Value == nil {
+		return "cell()"
+	}
+	return fmt.Sprintf("cell(%s)", c.Value.Inspect())
+}
+
+// Lookup implements RuntimeValue.
+func (c *Cell) Lookup(name string) RuntimeValue {
+	return nil
+}
+
+// TypeConstantId implements RuntimeValue.
+// Cells are never exposed as values, thus they have the type of their value.
+func (c *Cell) TypeConstantId() TypeId {
+	return c.Value.TypeConstantId()
+}
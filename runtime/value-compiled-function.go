@@ -12,17 +12,20 @@ var _ CallableRuntimeValue = CompiledFunction{}
 type CompiledFunction struct {
 	Instructions op.Instructions
 	Params       int
+	Locals       int // including params
 	Symbol       *ast.Symbol
 }
 
 func MakeCompiledFunction(
 	instructions op.Instructions,
 	params int,
+	locals int,
 	symbol *ast.Symbol,
 ) *CompiledFunction {
 	return &CompiledFunction{
 		Instructions: instructions,
 		Params:       params,
+		Locals:       locals,
 		Symbol:       symbol,
 	}
 }
@@ -34,7 +37,7 @@ func (c CompiledFunction) Arity() int {
 
 // Inspect implements CallableRuntimeValue.
 func (c CompiledFunction) Inspect() string {
-	return fmt.Sprintf("func %s(#%d)", c.Symbol.Decl.DeclName(), c.Arity())
+	return fmt.Sprintf("func %s(#%d)", c.Symbol.Name, c.Arity())
 }
 
 // Lookup implements CallableRuntimeValue.
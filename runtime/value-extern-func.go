@@ -8,7 +8,9 @@ import (
 
 var _ CallableRuntimeValue = ExternFunc{}
 
-type ExternFuncImpl func(args []RuntimeValue) RuntimeValue
+// ExternFuncImpl implements an extern func in Go.
+// Returned errors will be reported as runtime errors.
+type ExternFuncImpl func(args []RuntimeValue) (RuntimeValue, error)
 
 type ExternFunc struct {
 	symbol *ast.Symbol
@@ -24,6 +26,11 @@ func MakeExternFunc(symbol *ast.Symbol, impl ExternFuncImpl) (ExternFunc, error)
 	return ExternFunc{symbol, len(decl.Parameters), impl}, nil
 }
 
+// Name returns the name of the extern declaration.
+func (ef ExternFunc) Name() string {
+	return ef.symbol.Name
+}
+
 // Arity implements CallableRuntimeValue.
 func (ef ExternFunc) Arity() int {
 	return ef.arity
@@ -37,13 +44,16 @@ func (ef ExternFunc) Inspect() string {
 // Lookup implements CallableRuntimeValue.
 func (ef ExternFunc) Lookup(name string) RuntimeValue {
 	if name == "arity" {
-		// return ef.arity
-		panic("unimplemented: how to create ints?")
+		return Int(ef.arity)
 	}
 	return nil
 }
 
 // TypeConstantId implements CallableRuntimeValue.
+// Extern funcs without a type symbol, like those provided by the runtime itself, are of type Func.
 func (ef ExternFunc) TypeConstantId() TypeId {
+	if ef.symbol.TypeSymbol == nil || ef.symbol.TypeSymbol.ConstantId == nil {
+		return typeIdFunc
+	}
 	return TypeId(*ef.symbol.TypeSymbol.ConstantId)
 }
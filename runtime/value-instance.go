@@ -34,6 +34,16 @@ func (dv *DataValue) Lookup(name string) RuntimeValue {
 	return dv.Values[idx]
 }
 
+// Assign replaces the value of the field and reports whether the field exists.
+func (dv *DataValue) Assign(name string, v RuntimeValue) bool {
+	idx, ok := dv.Fields[name]
+	if !ok {
+		return false
+	}
+	dv.Values[idx] = v
+	return true
+}
+
 // TypeConstantId implements RuntimeValue.
 func (dv *DataValue) TypeConstantId() TypeId {
 	return dv.TypeId
@@ -0,0 +1,15 @@
+// Package stdlib ships the sources of the standard library with the toolchain.
+//
+// Every top level directory is a package like prelude or cave,
+// which may contain further submodules like cave/tasks.
+package stdlib
+
+import "embed"
+
+// Version of the standard library, which is released together with the toolchain.
+const Version = "0.1.0"
+
+// Sources contains all packages of the standard library.
+//
+//go:embed prelude cave
+var Sources embed.FS
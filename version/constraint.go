@@ -0,0 +1,389 @@
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Constraint is a predicate expression, which is satisfied by any of its alternatives.
+// An alternative is satisfied if all of its predicates are.
+//
+// Pre-release versions only satisfy an alternative, if one of its predicates
+// refers to a pre-release of the same major, minor and patch version.
+//
+// Examples:
+//   - "main"
+//   - ">=1.2.0 <2.0.0"
+//   - "1.x"
+//   - "^1.2 || ^2.0"
+//   - "1.2.3 - 2.3.4"
+type Constraint struct {
+	Alternatives [][]Predicate
+}
+
+// ConstraintOf requires all predicates.
+func ConstraintOf(predicates ...Predicate) Constraint {
+	return Constraint{Alternatives: [][]Predicate{predicates}}
+}
+
+// ConstraintError points at the offending part of a constraint.
+type ConstraintError struct {
+	Input string
+	// Offset is the byte offset of Part within Input.
+	Offset int
+	Part   string
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *ConstraintError) Error() string {
+	if e.Part == "" {
+		return fmt.Sprintf("%s %q at column %d: %s", ErrInvalidConstraint, e.Input, e.Offset+1, e.Reason)
+	}
+	return fmt.Sprintf("%s %q: %q at column %d %s", ErrInvalidConstraint, e.Input, e.Part, e.Offset+1, e.Reason)
+}
+
+// Unwrap allows matching ErrInvalidConstraint.
+func (e *ConstraintError) Unwrap() error {
+	return ErrInvalidConstraint
+}
+
+// Matches reports whether the version satisfies any alternative.
+func (c Constraint) Matches(v Version) bool {
+	for _, alternative := range c.Alternatives {
+		if matchesAlternative(v, alternative) {
+			return true
+		}
+	}
+	return false
+}
+
+// Predicates returns the predicates of a constraint without alternatives.
+func (c Constraint) Predicates() ([]Predicate, bool) {
+	if len(c.Alternatives) != 1 {
+		return nil, false
+	}
+	return c.Alternatives[0], true
+}
+
+// String implements the fmt.Stringer interface.
+// The result parses to an equal constraint.
+func (c Constraint) String() string {
+	alternatives := make([]string, len(c.Alternatives))
+	for i, alternative := range c.Alternatives {
+		preds := make([]string, len(alternative))
+		for j, pred := range alternative {
+			preds[j] = pred.String()
+		}
+		alternatives[i] = strings.Join(preds, " ")
+	}
+	return strings.Join(alternatives, " || ")
+}
+
+func matchesAlternative(v Version, alternative []Predicate) bool {
+	for _, pred := range alternative {
+		if !v.Matches(pred) {
+			return false
+		}
+	}
+	semver, ok := v.(SemverVersion)
+	if !ok || len(semver.PreReleaseIdentifiers) == 0 {
+		return true
+	}
+	for _, pred := range alternative {
+		ref, ok := pred.Version.(SemverVersion)
+		if ok && len(ref.PreReleaseIdentifiers) > 0 &&
+			ref.Major == semver.Major && ref.Minor == semver.Minor && ref.Patch == semver.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseConstraint parses alternatives separated by `||`.
+// Each alternative consists of whitespace separated predicates, wildcards like `1.x` or hyphen ranges like `1.2 - 2`.
+// Versions may be partial like `^1.2`.
+func ParseConstraint(input string) (Constraint, error) {
+	p := constraintParser{input: input}
+	var c Constraint
+	start := 0
+	for {
+		end := strings.Index(input[start:], "||")
+		if end < 0 {
+			end = len(input)
+		} else {
+			end += start
+		}
+		alternative, err := p.parseAlternative(start, end)
+		if err != nil {
+			return Constraint{}, err
+		}
+		c.Alternatives = append(c.Alternatives, alternative)
+		if end == len(input) {
+			return c, nil
+		}
+		start = end + len("||")
+	}
+}
+
+type constraintParser struct {
+	input string
+}
+
+type constraintToken struct {
+	text   string
+	offset int
+}
+
+func (p constraintParser) errorf(tok constraintToken, format string, args ...any) error {
+	return &ConstraintError{
+		Input:  p.input,
+		Offset: tok.offset,
+		Part:   tok.text,
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+func (p constraintParser) tokens(start, end int) []constraintToken {
+	var toks []constraintToken
+	tokStart := -1
+	for i, r := range p.input[start:end] {
+		if unicode.IsSpace(r) {
+			if tokStart >= 0 {
+				toks = append(toks, constraintToken{p.input[tokStart : start+i], tokStart})
+				tokStart = -1
+			}
+		} else if tokStart < 0 {
+			tokStart = start + i
+		}
+	}
+	if tokStart >= 0 {
+		toks = append(toks, constraintToken{p.input[tokStart:end], tokStart})
+	}
+
+	// comparisons may be separated from their version like `>= 1.0.0`
+	var joined []constraintToken
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
+		if _, rest := comparisonPrefix(tok.text); rest == "" && i+1 < len(toks) && toks[i+1].text != "-" {
+			tok.text = p.input[tok.offset : toks[i+1].offset+len(toks[i+1].text)]
+			i++
+		}
+		joined = append(joined, tok)
+	}
+	return joined
+}
+
+func (p constraintParser) parseAlternative(start, end int) ([]Predicate, error) {
+	toks := p.tokens(start, end)
+	if len(toks) == 0 {
+		reason := "expected a predicate"
+		if strings.TrimSpace(p.input) != "" {
+			reason = "expected a predicate around ||"
+		}
+		return nil, &ConstraintError{Input: p.input, Offset: start, Reason: reason}
+	}
+	var preds []Predicate
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
+		if tok.text == "-" {
+			return nil, p.errorf(tok, "requires a lower bound")
+		}
+		if i+1 < len(toks) && toks[i+1].text == "-" {
+			if i+2 >= len(toks) {
+				return nil, p.errorf(toks[i+1], "requires an upper bound")
+			}
+			hyphenPreds, err := p.parseHyphenRange(tok, toks[i+2])
+			if err != nil {
+				return nil, err
+			}
+			preds = append(preds, hyphenPreds...)
+			i += 2
+			continue
+		}
+		termPreds, err := p.parseTerm(tok)
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, termPreds...)
+	}
+	return preds, nil
+}
+
+func (p constraintParser) parseHyphenRange(lower, upper constraintToken) ([]Predicate, error) {
+	from, err := p.parsePartial(lower, lower.text)
+	if err != nil {
+		return nil, err
+	}
+	to, err := p.parsePartial(upper, upper.text)
+	if err != nil {
+		return nil, err
+	}
+	preds := []Predicate{{Comparison: ComparisonGreaterThanOrEqual, Version: from.floor()}}
+	if to.isFull() {
+		return append(preds, Predicate{Comparison: ComparisonLessThanOrEqual, Version: to.version}), nil
+	}
+	if next, ok := to.next(); ok {
+		preds = append(preds, Predicate{Comparison: ComparisonLessThan, Version: next})
+	}
+	return preds, nil
+}
+
+func (p constraintParser) parseTerm(tok constraintToken) ([]Predicate, error) {
+	comparison, rest := comparisonPrefix(tok.text)
+	rest = strings.TrimSpace(rest)
+	if comparison == nil {
+		partial, err := p.parsePartial(tok, rest)
+		if err != nil && !looksNumeric(rest) {
+			return []Predicate{{Comparison: ComparisonExact, Version: ParseVerbal(rest)}}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return partial.wildcard(), nil
+	}
+	if rest == "" {
+		return nil, p.errorf(tok, "requires a version")
+	}
+
+	partial, err := p.parsePartial(tok, rest)
+	if err != nil {
+		if *comparison == ComparisonExact && !looksNumeric(rest) {
+			return []Predicate{{Comparison: ComparisonExact, Version: ParseVerbal(rest)}}, nil
+		}
+		return nil, err
+	}
+	if partial.hasWildcard {
+		return nil, p.errorf(tok, "cannot combine wildcards with %s", *comparison)
+	}
+	if partial.isFull() {
+		return []Predicate{{Comparison: *comparison, Version: partial.version}}, nil
+	}
+	switch *comparison {
+	case ComparisonExact:
+		return partial.wildcard(), nil
+	case ComparisonGreaterThan:
+		// >1.2 excludes all of 1.2.x
+		next, _ := partial.next()
+		return []Predicate{{Comparison: ComparisonGreaterThanOrEqual, Version: next}}, nil
+	case ComparisonLessThanOrEqual:
+		// <=1.2 includes all of 1.2.x
+		next, _ := partial.next()
+		return []Predicate{{Comparison: ComparisonLessThan, Version: next}}, nil
+	default:
+		return []Predicate{{Comparison: *comparison, Version: partial.floor()}}, nil
+	}
+}
+
+// comparisonPrefix splits a leading comparison off the term.
+func comparisonPrefix(s string) (*Comparison, string) {
+	prefixes := []struct {
+		prefix string
+		comp   Comparison
+	}{
+		{"==", ComparisonExact},
+		{"<=", ComparisonLessThanOrEqual},
+		{">=", ComparisonGreaterThanOrEqual},
+		{"=", ComparisonExact},
+		{"<", ComparisonLessThan},
+		{">", ComparisonGreaterThan},
+		{"^", ComparisonUpToNextMajor},
+		{"~", ComparisonUpToNextMinor},
+	}
+	for _, pref := range prefixes {
+		if strings.HasPrefix(s, pref.prefix) {
+			comp := pref.comp
+			return &comp, s[len(pref.prefix):]
+		}
+	}
+	return nil, s
+}
+
+// looksNumeric reports whether s was meant to be a semantic version rather than a verbal one.
+func looksNumeric(s string) bool {
+	s = strings.TrimPrefix(s, "v")
+	if s == "" {
+		return false
+	}
+	return unicode.IsDigit(rune(s[0])) || isWildcard(s) || (isWildcard(s[:1]) && strings.HasPrefix(s[1:], "."))
+}
+
+func isWildcard(s string) bool {
+	return s == "x" || s == "X" || s == "*"
+}
+
+// partialVersion is a version with up to three parts like `1`, `1.2`, `1.x` or `1.2.3-beta`.
+type partialVersion struct {
+	parts       []int
+	hasWildcard bool
+	// only set for full versions
+	version SemverVersion
+}
+
+func (p constraintParser) parsePartial(tok constraintToken, s string) (partialVersion, error) {
+	if v, err := ParseSemver(s); err == nil {
+		return partialVersion{parts: []int{v.Major, v.Minor, v.Patch}, version: v}, nil
+	}
+	var partial partialVersion
+	for i, part := range strings.Split(strings.TrimPrefix(s, "v"), ".") {
+		if i >= 3 {
+			return partialVersion{}, p.errorf(tok, "has more than three version parts")
+		}
+		if isWildcard(part) {
+			partial.hasWildcard = true
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (len(part) > 1 && part[0] == '0') {
+			return partialVersion{}, p.errorf(tok, "is not a semantic version")
+		}
+		if partial.hasWildcard {
+			return partialVersion{}, p.errorf(tok, "cannot be followed by numbers after a wildcard")
+		}
+		partial.parts = append(partial.parts, n)
+	}
+	if partial.isFull() {
+		partial.version = SemverVersion{Major: partial.parts[0], Minor: partial.parts[1], Patch: partial.parts[2]}
+	}
+	return partial, nil
+}
+
+func (v partialVersion) isFull() bool {
+	return len(v.parts) == 3
+}
+
+// floor fills missing parts with zeros.
+func (v partialVersion) floor() SemverVersion {
+	if v.isFull() {
+		return v.version
+	}
+	parts := append(append([]int{}, v.parts...), 0, 0, 0)
+	return SemverVersion{Major: parts[0], Minor: parts[1], Patch: parts[2]}
+}
+
+// next returns the first version after all versions the partial version matches.
+func (v partialVersion) next() (SemverVersion, bool) {
+	switch len(v.parts) {
+	case 1:
+		return SemverVersion{Major: v.parts[0] + 1}, true
+	case 2:
+		return SemverVersion{Major: v.parts[0], Minor: v.parts[1] + 1}, true
+	case 3:
+		return SemverVersion{Major: v.parts[0], Minor: v.parts[1], Patch: v.parts[2] + 1}, true
+	}
+	return SemverVersion{}, false
+}
+
+// wildcard matches all versions starting with the given parts.
+func (v partialVersion) wildcard() []Predicate {
+	if v.isFull() {
+		return []Predicate{{Comparison: ComparisonExact, Version: v.version}}
+	}
+	preds := []Predicate{{Comparison: ComparisonGreaterThanOrEqual, Version: v.floor()}}
+	if next, ok := v.next(); ok {
+		preds = append(preds, Predicate{Comparison: ComparisonLessThan, Version: next})
+	}
+	return preds
+}
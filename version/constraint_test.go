@@ -0,0 +1,158 @@
+package version_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vknabel/blush/version"
+)
+
+func TestConstraintString(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want string
+	}{
+		{"main", "main"},
+		{"1.2.3", "==1.2.3"},
+		{"v1.2.3", "==1.2.3"},
+		{">=1.2.0 <2.0.0", ">=1.2.0 <2.0.0"},
+		{">= 1.2.0  < 2.0.0", ">=1.2.0 <2.0.0"},
+		{"1.x", ">=1.0.0 <2.0.0"},
+		{"1.2.*", ">=1.2.0 <1.3.0"},
+		{"1", ">=1.0.0 <2.0.0"},
+		{"*", ">=0.0.0"},
+		{"^1.2 || ^2.0", "^1.2.0 || ^2.0.0"},
+		{"^1.2||~2.0.1", "^1.2.0 || ~2.0.1"},
+		{"1.2.3 - 2.3.4", ">=1.2.3 <=2.3.4"},
+		{"1.2 - 2", ">=1.2.0 <3.0.0"},
+		{">1.2", ">=1.3.0"},
+		{"<=1.2", "<1.3.0"},
+		{"=1.2", ">=1.2.0 <1.3.0"},
+		{">=1.0.0-beta.2", ">=1.0.0-beta.2"},
+		{"main || ^1.0.0", "main || ^1.0.0"},
+	}
+	for _, c := range cases {
+		t.Run(c.raw, func(t *testing.T) {
+			constraint, err := version.ParseConstraint(c.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := constraint.String(); got != c.want {
+				t.Errorf("expected %q, got %q", c.want, got)
+			}
+			roundtrip, err := version.ParseConstraint(constraint.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if roundtrip.String() != constraint.String() {
+				t.Errorf("expected %q to round-trip, got %q", constraint, roundtrip)
+			}
+		})
+	}
+}
+
+func TestConstraintMatches(t *testing.T) {
+	cases := []struct {
+		constraint string
+		matches    []string
+		mismatches []string
+	}{
+		{
+			constraint: ">=1.2.0 <2.0.0",
+			matches:    []string{"1.2.0", "1.9.9"},
+			mismatches: []string{"1.1.9", "2.0.0", "2.0.0-beta", "1.5.0-beta"},
+		},
+		{
+			constraint: "1.x",
+			matches:    []string{"1.0.0", "1.99.0"},
+			mismatches: []string{"0.9.0", "2.0.0"},
+		},
+		{
+			constraint: "^1.2 || ^2.0",
+			matches:    []string{"1.2.0", "1.3.4", "2.0.0", "2.5.0"},
+			mismatches: []string{"1.1.0", "3.0.0"},
+		},
+		{
+			constraint: "1.2.3 - 2.3.4",
+			matches:    []string{"1.2.3", "2.3.4"},
+			mismatches: []string{"1.2.2", "2.3.5"},
+		},
+		{
+			constraint: "1.2 - 2",
+			matches:    []string{"1.2.0", "2.9.9"},
+			mismatches: []string{"1.1.9", "3.0.0"},
+		},
+		{
+			constraint: ">=1.0.0-beta.2 <2.0.0",
+			matches:    []string{"1.0.0-beta.2", "1.0.0-beta.10", "1.0.0", "1.5.0"},
+			mismatches: []string{"1.0.0-beta.1", "1.5.0-beta", "2.0.0-alpha"},
+		},
+		{
+			constraint: "*",
+			matches:    []string{"0.0.1", "42.0.0"},
+			mismatches: []string{"1.0.0-rc.1"},
+		},
+		{
+			constraint: "main || ^1.0.0",
+			matches:    []string{"main", "1.1.0"},
+			mismatches: []string{"develop", "2.0.0"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.constraint, func(t *testing.T) {
+			constraint, err := version.ParseConstraint(c.constraint)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range c.matches {
+				if !constraint.Matches(version.Parse(v)) {
+					t.Errorf("expected %s to match %s", v, constraint)
+				}
+			}
+			for _, v := range c.mismatches {
+				if constraint.Matches(version.Parse(v)) {
+					t.Errorf("expected %s not to match %s", v, constraint)
+				}
+			}
+		})
+	}
+}
+
+func TestConstraintErrors(t *testing.T) {
+	cases := []struct {
+		raw    string
+		part   string
+		offset int
+	}{
+		{"", "", 0},
+		{"^1.0 ||", "", 7},
+		{"|| ^1.0", "", 0},
+		{">=1.2.0 <2.y", "<2.y", 8},
+		{"^1.0 || >=", ">=", 8},
+		{"1.2.3 -", "-", 6},
+		{"- 1.2.3", "-", 0},
+		{"1.x.3", "1.x.3", 0},
+		{">=1.x", ">=1.x", 0},
+		{"1.2.3.4", "1.2.3.4", 0},
+		{"1.0.0 - 2.a", "2.a", 8},
+	}
+	for _, c := range cases {
+		t.Run(c.raw, func(t *testing.T) {
+			_, err := version.ParseConstraint(c.raw)
+			if !errors.Is(err, version.ErrInvalidConstraint) {
+				t.Fatalf("expected ErrInvalidConstraint, got %v", err)
+			}
+			var constraintErr *version.ConstraintError
+			if !errors.As(err, &constraintErr) {
+				t.Fatalf("expected a ConstraintError, got %T", err)
+			}
+			if constraintErr.Part != c.part || constraintErr.Offset != c.offset {
+				t.Errorf("expected %q at %d, got %q at %d", c.part, c.offset, constraintErr.Part, constraintErr.Offset)
+			}
+			if c.part != "" && !strings.Contains(err.Error(), c.part) {
+				t.Errorf("expected error to mention %q, got %q", c.part, err)
+			}
+		})
+	}
+}
@@ -6,7 +6,8 @@ import (
 )
 
 var (
-	ErrInvalidVersion = errors.New("invalid version")
+	ErrInvalidVersion    = errors.New("invalid version")
+	ErrInvalidConstraint = errors.New("invalid version constraint")
 )
 
 type Version interface {
@@ -0,0 +1,273 @@
+package vm
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/vknabel/blush/ast"
+	"github.com/vknabel/blush/runtime"
+)
+
+var _ runtime.RuntimeValue = &iterator{}
+
+// iterator lives on the stack for the duration of a `for item <- items` loop.
+//
+// Collections are enumerated lazily by the iterate function of their @Iterable annotation.
+// The iterate function runs as a coroutine, which is suspended by each call of yield,
+// until the loop requests the next element.
+type iterator struct {
+	// elements of collections without @Iterable, which are iterated natively
+	values runtime.Array
+	pos    int
+
+	// the coroutine running the iterate function, if any
+	co *coroutine
+}
+
+// coroutine runs an iterate function on its own stack.
+type coroutine struct {
+	vm     *VM
+	taskId TaskId
+	// the iterate function of the collection
+	fn runtime.RuntimeValue
+
+	// the element passed to yield
+	element runtime.RuntimeValue
+	// yield may only be called while the coroutine is running
+	running bool
+	// suspended within yield until resumed
+	suspended bool
+	// yield returns false once the loop has been left
+	stopped bool
+	// the iterate function returned
+	done bool
+}
+
+// coroutineVMs are reused by coroutines, as nested loops would otherwise allocate a stack per iteration.
+var coroutineVMs = sync.Pool{
+	New: func() any {
+		return &VM{
+			stack:  make([]runtime.RuntimeValue, stackSize),
+			frames: make([]*Frame, maxFrames),
+		}
+	},
+}
+
+// iterate creates an iterator for the elements of the given collection.
+//
+// The iterate function of the collection's @Iterable annotation will be called with the collection and a yield function.
+// Without a prelude declaring @Iterable for them, arrays, dicts and strings are iterated natively.
+func (vm *VM) iterate(taskId TaskId, collection runtime.RuntimeValue) (*iterator, error) {
+	var iterate *int
+	switch typ := vm.typeOf(collection).(type) {
+	case *runtime.DataType:
+		iterate = typ.Iterate
+	case runtime.SimpleType:
+		iterate = typ.Iterate
+	}
+	if iterate != nil {
+		return vm.iterateCoroutine(taskId, vm.constants[*iterate], collection)
+	}
+
+	switch collection := collection.(type) {
+	case runtime.Array:
+		return &iterator{values: collection}, nil
+
+	case runtime.Dict:
+		keys := collection.Keys()
+		values := make(runtime.Array, len(keys))
+		for i, key := range keys {
+			values[i] = collection[key]
+		}
+		return &iterator{values: values}, nil
+
+	case runtime.String:
+		values := make(runtime.Array, 0, len(collection))
+		for _, ch := range collection {
+			values = append(values, runtime.Char(ch))
+		}
+		return &iterator{values: values}, nil
+
+	default:
+		return nil, fmt.Errorf("value is not iterable (%T %q)", collection, collection.Inspect())
+	}
+}
+
+// iterateCoroutine prepares the call of the iterate function, which starts running once the first element is requested.
+func (vm *VM) iterateCoroutine(taskId TaskId, fn, collection runtime.RuntimeValue) (*iterator, error) {
+	co := &coroutine{
+		vm:     coroutineVMs.Get().(*VM),
+		taskId: taskId,
+		fn:     fn,
+	}
+	co.vm.constants = vm.constants
+	co.vm.globals = vm.globals
+
+	yield, err := runtime.MakeExternFunc(yieldSymbol, co.yield)
+	if err != nil {
+		co.release()
+		return nil, err
+	}
+
+	co.vm.stack[0] = collection
+	co.vm.stack[1] = yield
+	co.vm.sp = 2
+	if err := co.vm.callValue(fn, 2); err != nil {
+		co.release()
+		return nil, err
+	}
+	return &iterator{co: co}, nil
+}
+
+func (it *iterator) next() (runtime.RuntimeValue, bool, error) {
+	if it.co == nil {
+		if it.pos >= len(it.values) {
+			return nil, false, nil
+		}
+		val := it.values[it.pos]
+		it.pos++
+		return val, true, nil
+	}
+
+	if it.co.done {
+		return nil, false, nil
+	}
+	if err := it.co.resume(runtime.Bool(true)); err != nil {
+		return nil, false, err
+	}
+	if it.co.done {
+		return nil, false, nil
+	}
+	return it.co.element, true, nil
+}
+
+// end lets yield return false and runs the iterate function until it returns.
+func (it *iterator) end() error {
+	if it.co == nil || it.co.done {
+		return nil
+	}
+	it.co.stopped = true
+	return it.co.resume(runtime.Bool(false))
+}
+
+// release stops the coroutine without running the iterate function any further.
+func (it *iterator) release() {
+	if it.co != nil && !it.co.done {
+		it.co.release()
+	}
+}
+
+// endIterators leaves all loops of the frame, innermost first, as if they were left by break.
+func (f *Frame) endIterators() error {
+	for len(f.iterators) > 0 {
+		it := f.iterators[len(f.iterators)-1]
+		f.iterators = f.iterators[:len(f.iterators)-1]
+		if err := it.end(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseIterators releases the iterators of all frames from the given depth on.
+// Their loops won't be left regularly, for example when an error occurred.
+func (vm *VM) releaseIterators(depth int) {
+	for i := vm.framesIdx - 1; i >= depth; i-- {
+		fr := vm.frames[i]
+		for j := len(fr.iterators) - 1; j >= 0; j-- {
+			fr.iterators[j].release()
+		}
+		fr.iterators = nil
+	}
+}
+
+// resume runs the coroutine until it yields the next element or returns.
+// If it has been suspended within yield, yield returns the given result.
+func (co *coroutine) resume(result runtime.Bool) error {
+	if co.suspended {
+		co.suspended = false
+		co.vm.suspended = false
+		// replaces the placeholder returned by yield
+		co.vm.stack[co.vm.sp-1] = result
+	}
+
+	co.running = true
+	err := co.vm.runTask(co.taskId, 0)
+	co.running = false
+	if err != nil {
+		co.release()
+		return err
+	}
+	if !co.suspended {
+		co.release()
+	}
+	return nil
+}
+
+// release marks the coroutine as done and returns its VM to the pool.
+func (co *coroutine) release() {
+	if co.vm == nil {
+		return
+	}
+	vm := co.vm
+	co.vm = nil
+	co.done = true
+	co.element = nil
+
+	// nested loops of a suspended iterate function
+	vm.releaseIterators(0)
+	clear(vm.stack)
+	clear(vm.frames)
+	vm.sp = 0
+	vm.framesIdx = 0
+	vm.suspended = false
+	vm.constants = nil
+	vm.globals = nil
+	coroutineVMs.Put(vm)
+}
+
+// Inspect implements runtime.RuntimeValue.
+func (it *iterator) Inspect() string {
+	if it.co != nil {
+		return fmt.Sprintf("iterator(%s)", it.co.fn.Inspect())
+	}
+	return fmt.Sprintf("iterator(%d/%d)", it.pos, len(it.values))
+}
+
+// Lookup implements runtime.RuntimeValue.
+func (it *iterator) Lookup(name string) runtime.RuntimeValue {
+	return nil
+}
+
+// TypeConstantId implements runtime.RuntimeValue.
+func (it *iterator) TypeConstantId() runtime.TypeId {
+	return it.values.TypeConstantId()
+}
+
+// yieldDecl declares the yield function passed to iterate functions.
+// It returns whether the loop requests further elements.
+var yieldDecl = &ast.DeclExternFunc{
+	Name:       ast.Identifier{Value: "yield"},
+	Parameters: []ast.DeclParameter{{Name: ast.Identifier{Value: "element"}}},
+}
+
+var yieldSymbol = &ast.Symbol{
+	Name: yieldDecl.Name.Value,
+	Decl: yieldDecl,
+}
+
+// yield suspends the coroutine with the given element.
+// Once the loop has been left, it returns false immediately.
+func (co *coroutine) yield(args []runtime.RuntimeValue) (runtime.RuntimeValue, error) {
+	if !co.running {
+		return nil, fmt.Errorf("yield called outside of its iterate function")
+	}
+	if co.stopped {
+		return runtime.Bool(false), nil
+	}
+	co.element = args[0]
+	co.suspended = true
+	co.vm.suspended = true
+	// replaced by the result once resumed
+	return runtime.Null{}, nil
+}
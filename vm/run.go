@@ -3,6 +3,7 @@ package vm
 import (
 	"fmt"
 	"math/rand"
+	"slices"
 
 	"github.com/vknabel/blush/op"
 	"github.com/vknabel/blush/runtime"
@@ -10,11 +11,22 @@ import (
 
 func (vm *VM) Run() error {
 	var taskId = TaskId(rand.Uint64())
-	return vm.runTask(taskId)
+	return vm.runTask(taskId, 0)
 }
 
-func (vm *VM) runTask(taskId TaskId) error {
-	for vm.currentFrame().ip < len(vm.currentFrame().Instructions()) {
+// runTask executes instructions until the current frame runs out of instructions,
+// until all frames above the given depth have returned or until a coroutine has been suspended.
+func (vm *VM) runTask(taskId TaskId, depth int) error {
+	if err := vm.run(taskId, depth); err != nil {
+		// the loops of the failed frames will never be left
+		vm.releaseIterators(depth)
+		return err
+	}
+	return nil
+}
+
+func (vm *VM) run(taskId TaskId, depth int) error {
+	for !vm.suspended && vm.framesIdx > depth && vm.currentFrame().ip < len(vm.currentFrame().Instructions()) {
 		vm.currentFrame().ip++
 
 		var (
@@ -26,7 +38,10 @@ func (vm *VM) runTask(taskId TaskId) error {
 
 		switch code {
 		case op.Pop:
-			vm.pop()
+			val := vm.pop()
+			if vm.framesIdx == 1 && slices.Contains(vm.results, ip-1) {
+				vm.result = val
+			}
 
 		case op.Const:
 			idx := op.ReadUint16(ins[ip:])
@@ -72,6 +87,46 @@ func (vm *VM) runTask(taskId TaskId) error {
 				fr.ip = pos
 			}
 
+		case op.Iterate:
+			collection := vm.pop()
+			it, err := vm.iterate(taskId, collection)
+			if err != nil {
+				return err
+			}
+			if err := vm.push(it); err != nil {
+				it.release()
+				return err
+			}
+			fr.iterators = append(fr.iterators, it)
+		case op.IterNext:
+			pos := int(op.ReadUint16(ins[ip:]))
+			fr.ip += 2
+			it, ok := vm.stack[vm.sp-1].(*iterator)
+			if !ok {
+				return fmt.Errorf("iteration requires an iterator (%T %q)", vm.stack[vm.sp-1], vm.stack[vm.sp-1].Inspect())
+			}
+			val, ok, err := it.next()
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fr.ip = pos
+				break
+			}
+			if err := vm.push(val); err != nil {
+				return err
+			}
+		case op.IterEnd:
+			val := vm.pop()
+			it, ok := val.(*iterator)
+			if !ok {
+				return fmt.Errorf("iteration requires an iterator (%T %q)", val, val.Inspect())
+			}
+			fr.iterators = fr.iterators[:len(fr.iterators)-1]
+			if err := it.end(); err != nil {
+				return err
+			}
+
 		case op.AssertType:
 			typeId := runtime.TypeId(op.ReadUint16(ins[ip:]))
 			fr.ip += 2
@@ -80,6 +135,32 @@ func (vm *VM) runTask(taskId TaskId) error {
 				return fmt.Errorf("unexpected type (%T %q)", v, v.Inspect())
 			}
 
+		case op.IsType:
+			t := vm.pop()
+			v := vm.pop()
+			includes, err := vm.includes(t, v)
+			if err != nil {
+				return err
+			}
+			if err := vm.push(runtime.Bool(includes)); err != nil {
+				return err
+			}
+		case op.HasAnnotation:
+			a := vm.pop()
+			anno, ok := a.(*runtime.AnnotationType)
+			if !ok {
+				return fmt.Errorf("@Has requires an annotation type (%T %q)", a, a.Inspect())
+			}
+			v := vm.pop()
+			if err := vm.push(runtime.Bool(vm.hasAnnotation(v, anno))); err != nil {
+				return err
+			}
+		case op.Unmatched:
+			typeId := op.ReadUint16(ins[ip:])
+			fr.ip += 2
+			v := vm.pop()
+			return fmt.Errorf("no case of %s matches (%T %q)", vm.constants[typeId].Inspect(), v, v.Inspect())
+
 		case op.Invert:
 			v, ok := vm.pop().(runtime.Bool)
 			if !ok {
@@ -123,12 +204,18 @@ func (vm *VM) runTask(taskId TaskId) error {
 				return err
 			}
 		case op.Equal:
-			equal := vm.isEqual()
+			equal, err := vm.isEqual()
+			if err != nil {
+				return err
+			}
 			if err := vm.push(equal); err != nil {
 				return err
 			}
 		case op.NotEqual:
-			equal := vm.isEqual()
+			equal, err := vm.isEqual()
+			if err != nil {
+				return err
+			}
 			if err := vm.push(!equal); err != nil {
 				return err
 			}
@@ -147,6 +234,16 @@ func (vm *VM) runTask(taskId TaskId) error {
 			if err := vm.push(array); err != nil {
 				return err
 			}
+		case op.Append:
+			val := vm.pop()
+			target := vm.pop()
+			array, ok := target.(runtime.Array)
+			if !ok {
+				return fmt.Errorf("values can only be appended to arrays (%T %q)", target, target.Inspect())
+			}
+			if err := vm.push(append(array, val)); err != nil {
+				return err
+			}
 		case op.Dict:
 			length, ok := vm.pop().(runtime.Int)
 			if !ok {
@@ -181,6 +278,18 @@ func (vm *VM) runTask(taskId TaskId) error {
 				if err := vm.push(target[pos]); err != nil {
 					return err
 				}
+			case runtime.String:
+				idx, ok := index.(runtime.Int)
+				if !ok {
+					return fmt.Errorf("string index must be Int (%T %q)", index, index.Inspect())
+				}
+				ch, ok := target.Char(int(idx))
+				if !ok {
+					return fmt.Errorf("string index %d out of bounds", idx)
+				}
+				if err := vm.push(ch); err != nil {
+					return err
+				}
 			case runtime.Dict:
 				val, ok := target[index]
 				if !ok {
@@ -196,6 +305,28 @@ func (vm *VM) runTask(taskId TaskId) error {
 				return fmt.Errorf("index operator not supported on %T", target)
 			}
 
+		case op.SetIndex:
+			val := vm.pop()
+			index := vm.pop()
+			target := vm.pop()
+
+			switch target := target.(type) {
+			case runtime.Array:
+				idx, ok := index.(runtime.Int)
+				if !ok {
+					return fmt.Errorf("array index must be Int (%T %q)", index, index.Inspect())
+				}
+				pos := int(idx)
+				if pos < 0 || pos >= len(target) {
+					return fmt.Errorf("array index %d out of bounds", pos)
+				}
+				target[pos] = val
+			case runtime.Dict:
+				target[index] = val
+			default:
+				return fmt.Errorf("index assignment not supported on %T", target)
+			}
+
 		case op.SetLocal:
 			idx := op.ReadUint16(ins[ip:])
 			fr.ip += 2
@@ -206,7 +337,78 @@ func (vm *VM) runTask(taskId TaskId) error {
 			idx := op.ReadUint16(ins[ip:])
 			fr.ip += 2
 
-			if err := vm.push(fr.locals[idx]); err != nil {
+			val := fr.locals[idx]
+			if cell, ok := val.(*runtime.Cell); ok {
+				val = cell.Value
+			}
+			if err := vm.push(val); err != nil {
+				return err
+			}
+
+		case op.AssignLocal:
+			idx := op.ReadUint16(ins[ip:])
+			fr.ip += 2
+			val := vm.pop()
+			if cell, ok := fr.locals[idx].(*runtime.Cell); ok {
+				cell.Value = val
+			} else {
+				fr.locals[idx] = val
+			}
+
+		case op.CaptureLocal:
+			idx := op.ReadUint16(ins[ip:])
+			fr.ip += 2
+
+			cell, ok := fr.locals[idx].(*runtime.Cell)
+			if !ok {
+				cell = &runtime.Cell{Value: fr.locals[idx]}
+				fr.locals[idx] = cell
+			}
+			if err := vm.push(cell); err != nil {
+				return err
+			}
+
+		case op.CaptureFree:
+			idx := op.ReadUint16(ins[ip:])
+			fr.ip += 2
+
+			if err := vm.push(fr.closure.Free[idx]); err != nil {
+				return err
+			}
+
+		case op.GetFree:
+			idx := op.ReadUint16(ins[ip:])
+			fr.ip += 2
+
+			if err := vm.push(fr.closure.Free[idx].Value); err != nil {
+				return err
+			}
+
+		case op.SetFree:
+			idx := op.ReadUint16(ins[ip:])
+			fr.ip += 2
+			fr.closure.Free[idx].Value = vm.pop()
+
+		case op.Closure:
+			constId := op.ReadUint16(ins[ip:])
+			numFree := int(op.ReadUint8(ins[ip+2:]))
+			fr.ip += 3
+
+			fn, ok := vm.constants[constId].(*runtime.CompiledFunction)
+			if !ok {
+				return fmt.Errorf("closures require a function (%T %q)", vm.constants[constId], vm.constants[constId].Inspect())
+			}
+			free := make([]*runtime.Cell, numFree)
+			for i, val := range vm.stack[vm.sp-numFree : vm.sp] {
+				cell, ok := val.(*runtime.Cell)
+				if !ok {
+					return fmt.Errorf("closures capture cells (%T %q)", val, val.Inspect())
+				}
+				free[i] = cell
+			}
+			vm.sp -= numFree
+
+			if err := vm.push(runtime.MakeClosure(fn, free)); err != nil {
 				return err
 			}
 
@@ -234,6 +436,24 @@ func (vm *VM) runTask(taskId TaskId) error {
 				return err
 			}
 
+		case op.SetField:
+			nameIdx := op.ReadUint16(ins[ip:])
+			fr.ip += 2
+			nameConst, ok := vm.constants[nameIdx].(runtime.String)
+			if !ok {
+				return fmt.Errorf("name lookup requires a String constant (%T %q)", vm.constants[nameIdx], vm.constants[nameIdx].Inspect())
+			}
+			name := string(nameConst)
+			val := vm.pop()
+			obj := vm.pop()
+			dv, ok := obj.(*runtime.DataValue)
+			if !ok {
+				return fmt.Errorf("field assignment not supported on %T %q", obj, obj.Inspect())
+			}
+			if !dv.Assign(name, val) {
+				return fmt.Errorf("field %q not found in %T %q", name, obj, obj.Inspect())
+			}
+
 		case op.GetField:
 			nameIdx := op.ReadUint16(ins[ip:])
 			fr.ip += 2
@@ -257,41 +477,15 @@ func (vm *VM) runTask(taskId TaskId) error {
 			fr.ip += 2
 			callee := vm.pop()
 
-			switch callee := callee.(type) {
-			case *runtime.CompiledFunction:
-				if argCount != callee.Arity() {
-					return fmt.Errorf("wrong number of arguments: want=%d, got=%d", callee.Arity(), argCount)
-				}
-
-				closure := runtime.MakeClosure(callee, nil)
-				frame := newClosureFrame(closure, vm.sp-argCount)
-
-				vm.pushFrame(frame)
-				vm.sp = frame.basep
-
-				for i := 0; i < argCount; i++ {
-					frame.locals[argCount-1-i] = vm.stack[vm.sp+i]
-				}
-
-			case *runtime.DataType:
-				if argCount != callee.Arity() {
-					return fmt.Errorf("wrong number of arguments: want=%d, got=%d", callee.Arity(), argCount)
-				}
-
-				vals := make([]runtime.RuntimeValue, argCount)
-				for i := 0; i < argCount; i++ {
-					vals[argCount-1-i] = vm.pop()
-				}
-
-				dv := runtime.MakeDataValue(callee, vals)
-				err := vm.push(dv)
-				if err != nil {
-					return err
-				}
+			if err := vm.callValue(callee, argCount); err != nil {
+				return err
 			}
 
 		case op.Return:
 			ret := vm.pop()
+			if err := fr.endIterators(); err != nil {
+				return err
+			}
 			frame := vm.popFrame()
 			vm.sp = frame.basep
 
@@ -311,6 +505,102 @@ func (vm *VM) runTask(taskId TaskId) error {
 	return nil
 }
 
+// callValue calls the callee with the topmost argCount values on the stack.
+// Compiled functions only push their frame, their result is pushed once they return.
+func (vm *VM) callValue(callee runtime.RuntimeValue, argCount int) error {
+	switch callee := callee.(type) {
+	case *runtime.CompiledFunction:
+		return vm.callClosure(runtime.MakeClosure(callee, nil), argCount)
+
+	case *runtime.Closure:
+		return vm.callClosure(callee, argCount)
+
+	case *runtime.DataType:
+		if argCount != callee.Arity() {
+			return fmt.Errorf("wrong number of arguments: want=%d, got=%d", callee.Arity(), argCount)
+		}
+
+		vals := make([]runtime.RuntimeValue, argCount)
+		for i := 0; i < argCount; i++ {
+			vals[argCount-1-i] = vm.pop()
+		}
+
+		dv := runtime.MakeDataValue(callee, vals)
+		return vm.push(dv)
+
+	case runtime.ExternFunc:
+		if argCount != callee.Arity() {
+			return fmt.Errorf("wrong number of arguments: want=%d, got=%d", callee.Arity(), argCount)
+		}
+
+		args := make([]runtime.RuntimeValue, argCount)
+		for i := 0; i < argCount; i++ {
+			args[argCount-1-i] = vm.pop()
+		}
+
+		result, err := callee.Impl(args)
+		if err != nil {
+			return fmt.Errorf("%s: %w", callee.Inspect(), err)
+		}
+		if result == nil {
+			result = runtime.Null{}
+		}
+		return vm.push(result)
+
+	default:
+		return fmt.Errorf("value is not callable (%T %q)", callee, callee.Inspect())
+	}
+}
+
+func (vm *VM) callClosure(closure *runtime.Closure, argCount int) error {
+	if argCount != closure.Arity() {
+		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", closure.Arity(), argCount)
+	}
+
+	frame := newClosureFrame(closure, vm.sp-argCount)
+
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+	vm.sp = frame.basep
+
+	for i := 0; i < argCount; i++ {
+		frame.locals[i] = vm.stack[vm.sp+i]
+	}
+	return nil
+}
+
+// Call invokes the callee with the given arguments from Go and runs until it returned.
+// On failure the stack and frames are reset, so the VM stays usable.
+func (vm *VM) Call(callee runtime.RuntimeValue, args ...runtime.RuntimeValue) (runtime.RuntimeValue, error) {
+	sp, framesIdx := vm.sp, vm.framesIdx
+
+	ret, err := vm.call(TaskId(rand.Uint64()), callee, args)
+	if err != nil {
+		vm.sp, vm.framesIdx = sp, framesIdx
+		return nil, err
+	}
+	return ret, nil
+}
+
+// call invokes the callee from Go and runs until it returned.
+func (vm *VM) call(taskId TaskId, callee runtime.RuntimeValue, args []runtime.RuntimeValue) (runtime.RuntimeValue, error) {
+	for _, arg := range args {
+		if err := vm.push(arg); err != nil {
+			return nil, err
+		}
+	}
+
+	depth := vm.framesIdx
+	if err := vm.callValue(callee, len(args)); err != nil {
+		return nil, err
+	}
+	if err := vm.runTask(taskId, depth); err != nil {
+		return nil, err
+	}
+	return vm.pop(), nil
+}
+
 func (vm *VM) push(val runtime.RuntimeValue) error {
 	if vm.sp >= stackSize {
 		return fmt.Errorf("stack overflow")
@@ -398,64 +688,157 @@ func (vm *VM) numericBinaryOperationFloat(operator op.Opcode, lhs, rhs runtime.F
 		return fmt.Errorf("unknown binary operator %x", operator)
 	}
 }
-func (vm *VM) isEqual() runtime.Bool {
+func (vm *VM) isEqual() (runtime.Bool, error) {
 	rhs := vm.pop()
 	lhs := vm.pop()
+	return isEqual(lhs, rhs)
+}
 
-	if lhs.TypeConstantId() != rhs.TypeConstantId() {
-		return false
+// isEqual compares two values, which are only equal if they are of the same type.
+// Arrays, dicts and data values are compared by their elements, other values by identity.
+// Functions cannot be compared.
+func isEqual(lhs, rhs runtime.RuntimeValue) (runtime.Bool, error) {
+	// any value may be compared with null
+	_, lhsNull := lhs.(runtime.Null)
+	_, rhsNull := rhs.(runtime.Null)
+	if lhsNull || rhsNull {
+		return runtime.Bool(lhsNull && rhsNull), nil
 	}
+
 	switch lhs := lhs.(type) {
 	case runtime.Int:
 		rhs, ok := rhs.(runtime.Int)
-		if !ok {
-			return false
-		}
-		return lhs == rhs
+		return runtime.Bool(ok && lhs == rhs), nil
 	case runtime.Float:
 		rhs, ok := rhs.(runtime.Float)
-		if !ok {
-			return false
-		}
-		return lhs == rhs
+		return runtime.Bool(ok && lhs == rhs), nil
 	case runtime.Bool:
 		rhs, ok := rhs.(runtime.Bool)
-		if !ok {
-			return false
-		}
-		return lhs == rhs
+		return runtime.Bool(ok && lhs == rhs), nil
 	case runtime.Char:
 		rhs, ok := rhs.(runtime.Char)
-		if !ok {
-			return false
-		}
-		return lhs == rhs
+		return runtime.Bool(ok && lhs == rhs), nil
 	case runtime.String:
 		rhs, ok := rhs.(runtime.String)
-		if !ok {
-			return false
+		return runtime.Bool(ok && lhs == rhs), nil
+
+	case runtime.Array:
+		rhs, ok := rhs.(runtime.Array)
+		if !ok || len(lhs) != len(rhs) {
+			return false, nil
+		}
+		for i := range lhs {
+			equal, err := isEqual(lhs[i], rhs[i])
+			if !equal || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case runtime.Dict:
+		rhs, ok := rhs.(runtime.Dict)
+		if !ok || len(lhs) != len(rhs) {
+			return false, nil
 		}
-		return lhs == rhs
-	case runtime.Null:
-		_, ok := rhs.(runtime.Null)
-		return runtime.Bool(ok)
+		for key, lval := range lhs {
+			rval, ok := rhs[key]
+			if !ok {
+				return false, nil
+			}
+			equal, err := isEqual(lval, rval)
+			if !equal || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case *runtime.DataValue:
+		rhs, ok := rhs.(*runtime.DataValue)
+		if !ok || lhs.TypeId != rhs.TypeId || len(lhs.Values) != len(rhs.Values) {
+			return false, nil
+		}
+		for i := range lhs.Values {
+			equal, err := isEqual(lhs.Values[i], rhs.Values[i])
+			if !equal || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+
+	case *runtime.DataType:
+		return runtime.Bool(lhs == rhs), nil
+	case *runtime.EnumType:
+		return runtime.Bool(lhs == rhs), nil
+	case *runtime.AnnotationType:
+		return runtime.Bool(lhs == rhs), nil
+	default:
+		return false, fmt.Errorf("values of type %T cannot be compared", lhs)
 	}
-	panic(fmt.Sprintf("unknown type for equality check %T of %q", lhs, lhs.Inspect()))
 }
 
-func (vm *VM) initGlobal(owner TaskId, ins op.Instructions) (runtime.RuntimeValue, error) {
-	frame := newGeneralFrame(ins, vm.sp)
+// hasAnnotation reports whether the type of the value is annotated with the annotation type.
+// Annotations are matched by name.
+func (vm *VM) hasAnnotation(v runtime.RuntimeValue, anno *runtime.AnnotationType) bool {
+	typ := vm.typeOf(v)
+	if typ == nil {
+		return false
+	}
+	for _, instance := range typ.Annotations() {
+		if instance.Reference.Name().Value == anno.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// includes reports whether the value is of the type.
+// Enums include the values of all their cases.
+func (vm *VM) includes(t, v runtime.RuntimeValue) (bool, error) {
+	switch typ := t.(type) {
+	case *runtime.EnumType:
+		for _, id := range typ.Cases {
+			// cases without values like annotations never match
+			if ok, err := vm.includes(vm.constants[id], v); err == nil && ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	case runtime.TypeRuntimeValue:
+		return typ.Includes(v), nil
+	default:
+		return false, fmt.Errorf("values can only be matched against types (%T %q)", t, t.Inspect())
+	}
+}
+
+// typeOf returns the declared type of the value or nil if it is unknown.
+func (vm *VM) typeOf(v runtime.RuntimeValue) runtime.TypeRuntimeValue {
+	if dv, ok := v.(*runtime.DataValue); ok {
+		typ, _ := vm.constants[dv.TypeId].(runtime.TypeRuntimeValue)
+		return typ
+	}
+	// values of extern types are only known by the plugins binding them
+	for _, c := range vm.constants {
+		if typ, ok := c.(runtime.SimpleType); ok && typ.Includes(v) {
+			return typ
+		}
+	}
+	return nil
+}
+
+func (vm *VM) initGlobal(owner TaskId, ins op.Instructions, numLocals int) (runtime.RuntimeValue, error) {
+	frame := newGeneralFrame(ins, numLocals, vm.sp)
 	frame.ip = 0
-	vm.pushFrame(frame)
+	if err := vm.pushFrame(frame); err != nil {
+		return nil, err
+	}
 	vm.sp = frame.basep
 
-	err := vm.runTask(owner)
+	err := vm.runTask(owner, vm.framesIdx-1)
 	if err != nil {
 		return nil, err
 	}
 
-	val := vm.stack[vm.sp-1]
+	val := vm.pop()
 	vm.popFrame()
+	vm.sp = frame.basep
 
 	return val, nil
 }
@@ -1,6 +1,9 @@
 package vm
 
 import (
+	"fmt"
+	"math/rand"
+
 	"github.com/vknabel/blush/compiler"
 	"github.com/vknabel/blush/op"
 	"github.com/vknabel/blush/runtime"
@@ -17,24 +20,27 @@ type Frame struct {
 	ip    int
 	basep int
 
-	locals []runtime.RuntimeValue
+	locals  []runtime.RuntimeValue
+	closure *runtime.Closure
+	// the iterators of the loops currently running in this frame, innermost last
+	iterators []*iterator
 }
 
 func newClosureFrame(closure *runtime.Closure, basep int) *Frame {
-	// TODO: actually this might be wrong
-	numLocals := len(closure.Fn.Symbol.ChildTable.Symbols) - len(closure.Fn.Symbol.ChildTable.FreeSymbols)
 	return &Frame{
-		ins:    closure.Fn.Instructions,
-		ip:     0,
-		basep:  basep,
-		locals: make([]runtime.RuntimeValue, closure.Fn.Params+numLocals),
+		ins:     closure.Fn.Instructions,
+		ip:      0,
+		basep:   basep,
+		locals:  make([]runtime.RuntimeValue, closure.Fn.Locals),
+		closure: closure,
 	}
 }
-func newGeneralFrame(ins op.Instructions, basep int) *Frame {
+func newGeneralFrame(ins op.Instructions, numLocals int, basep int) *Frame {
 	return &Frame{
-		ins:   ins,
-		ip:    0,
-		basep: basep,
+		ins:    ins,
+		ip:     0,
+		basep:  basep,
+		locals: make([]runtime.RuntimeValue, numLocals),
 	}
 }
 
@@ -49,11 +55,17 @@ type VM struct {
 	sp        int
 	frames    []*Frame
 	framesIdx int
+	// set by yield to suspend the coroutine of an iterator
+	suspended bool
+
+	// the value of the trailing expression statement popped at one of the results positions
+	result  runtime.RuntimeValue
+	results []int
 }
 
 func New(bytecode *compiler.Bytecode) *VM {
 	frames := make([]*Frame, maxFrames)
-	frames[0] = newGeneralFrame(bytecode.Instructions, 0)
+	frames[0] = newGeneralFrame(bytecode.Instructions, bytecode.NumLocals, 0)
 
 	vm := &VM{
 		stack:     make([]runtime.RuntimeValue, stackSize),
@@ -62,12 +74,13 @@ func New(bytecode *compiler.Bytecode) *VM {
 		globals:   make([]*Global, len(bytecode.Globals)),
 		frames:    frames,
 		framesIdx: 1,
+		results:   bytecode.Results,
 	}
 
 	for i := range bytecode.Globals {
-		ins := bytecode.Globals[i].Instructions
+		scope := bytecode.Globals[i]
 		vm.globals[i] = MakeGlobal(func(ti TaskId) (runtime.RuntimeValue, error) {
-			return vm.initGlobal(ti, ins)
+			return vm.initGlobal(ti, scope.Instructions, scope.NumLocals())
 		})
 	}
 
@@ -78,13 +91,37 @@ func (vm *VM) LastPoppedStackElem() runtime.RuntimeValue {
 	return vm.stack[vm.sp]
 }
 
+// Result returns the value of the trailing top level expression statement.
+// Programs ending with other statements result in Null.
+func (vm *VM) Result() runtime.RuntimeValue {
+	if vm.result == nil {
+		return runtime.Null{}
+	}
+	return vm.result
+}
+
+// Constant returns the constant with the given id.
+func (vm *VM) Constant(id int) runtime.RuntimeValue {
+	return vm.constants[id]
+}
+
+// Global returns the value of the global with the given id.
+// Uninitialized globals will be initialized first.
+func (vm *VM) Global(id int) (runtime.RuntimeValue, error) {
+	return vm.globals[id].Get(TaskId(rand.Uint64()))
+}
+
 func (vm *VM) currentFrame() *Frame {
 	return vm.frames[vm.framesIdx-1]
 }
 
-func (vm *VM) pushFrame(f *Frame) {
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIdx >= maxFrames {
+		return fmt.Errorf("stack overflow")
+	}
 	vm.frames[vm.framesIdx] = f
 	vm.framesIdx++
+	return nil
 }
 
 func (vm *VM) popFrame() *Frame {
@@ -19,6 +19,7 @@ type vmTestCase struct {
 	input    string
 	expected any
 	err      string
+	plugins  []runtime.ExternPlugin
 }
 
 func TestBasicOperations(t *testing.T) {
@@ -76,11 +77,368 @@ func TestBasicFunctions(t *testing.T) {
 		}
 		twice(2)
 		`, expected: 4},
+		{
+			label: "unbounded recursion",
+			input: `
+		func recurse(n) {
+			return recurse(n+1)
+		}
+		recurse(0)
+		`,
+			err: "stack overflow",
+		},
 	}
 
 	runVmTests(t, tests)
 }
 
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			label: "capture parameter",
+			input: `
+			func adder(x) {
+				func add(a) {
+					return a + x
+				}
+				return add
+			}
+			adder(2)(40)
+			`,
+			expected: 42,
+		},
+		{
+			label: "capture local",
+			input: `
+			func greeter() {
+				let greeting = "hello"
+				func greet() {
+					return greeting
+				}
+				return greet
+			}
+			greeter()()
+			`,
+			expected: "hello",
+		},
+		{
+			label: "capture loop element",
+			input: `
+			func getters(xs) {
+				return for x <- xs {
+					func get() {
+						return x
+					}
+					get
+				}
+			}
+			let gets = getters([1, 2])
+			let values = [gets[0](), gets[1]()]
+			values
+			`,
+			expected: []any{1, 2},
+		},
+		{
+			label: "assignment after capture",
+			input: `
+			func greeter() {
+				let greeting = "hello"
+				func greet() {
+					return greeting
+				}
+				greeting = "hi"
+				return greet()
+			}
+			greeter()
+			`,
+			expected: "hi",
+		},
+		{
+			label: "assignment within nested closure",
+			input: `
+			func outer() {
+				let n = 1
+				func middle() {
+					func inner() {
+						n = n * 10
+					}
+					inner()
+					return n
+				}
+				let fromMiddle = middle()
+				return [fromMiddle, n]
+			}
+			outer()
+			`,
+			expected: []any{10, 10},
+		},
+		{
+			label: "multiple nesting levels",
+			input: `
+			func outer(a) {
+				func middle(b) {
+					func inner(c) {
+						return a + b + c
+					}
+					return inner
+				}
+				return middle
+			}
+			outer(1)(2)(3)
+			`,
+			expected: 6,
+		},
+		{
+			label: "call sibling closure",
+			input: `
+			func outer(a) {
+				func first() {
+					return a
+				}
+				func second() {
+					return first() + 1
+				}
+				return second
+			}
+			outer(41)()
+			`,
+			expected: 42,
+		},
+		{
+			label: "recursive closure",
+			input: `
+			func outer(step) {
+				func count(n) {
+					if n <= 0 {
+						return 0
+					}
+					return count(n - step) + 1
+				}
+				return count
+			}
+			outer(2)(10)
+			`,
+			expected: 5,
+		},
+		{
+			label: "globals are not captured",
+			input: `
+			let offset = 40
+			func adder(x) {
+				func add() {
+					return offset + x
+				}
+				return add
+			}
+			adder(2)()
+			`,
+			expected: 42,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFunctionLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{input: "{ -> 42 }()", expected: 42},
+		{input: "{ 42 }()", expected: 42},
+		{input: "{ a, b -> a - b }(5, 3)", expected: 2},
+		{input: "{ -> }()", expected: runtime.Null{}},
+		{
+			label: "stored in variable",
+			input: `
+			let multiply = { a, b ->
+				a * b
+			}
+			multiply(6, 7)
+			`,
+			expected: 42,
+		},
+		{
+			label: "explicit return",
+			input: `
+			let multiline = { a, b ->
+				let result = a * b
+				return result + 1
+			}
+			multiline(6, 7)
+			`,
+			expected: 43,
+		},
+		{
+			label: "trailing if else",
+			input: `
+			let f = { n -> if n < 2 { n } else { 1 } }
+			let results = [f(0), f(5)]
+			results
+			`,
+			expected: []any{0, 1},
+		},
+		{
+			label: "trailing else if without else",
+			input: `
+			let f = { n ->
+				if n < 2 {
+					n
+				} else if n < 4 {
+					let m = n * 2
+					m
+				}
+			}
+			let results = [f(1), f(3), f(5)]
+			results
+			`,
+			expected: []any{1, 6, runtime.Null{}},
+		},
+		{
+			label: "trailing switch",
+			input: `
+			let f = { n ->
+				switch n {
+				case 1: "one"
+				case _:
+					if n > 10 { "many" } else { "some" }
+				}
+			}
+			let results = [f(1), f(5), f(20)]
+			results
+			`,
+			expected: []any{"one", "some", "many"},
+		},
+		{
+			label: "passed as argument",
+			input: `
+			func apply(f, value) {
+				return f(value)
+			}
+			apply({ v -> v * 2 }, 21)
+			`,
+			expected: 42,
+		},
+		{
+			label: "capturing",
+			input: `
+			func adder(x) {
+				return { a -> a + x }
+			}
+			adder(40)(2)
+			`,
+			expected: 42,
+		},
+		{
+			label: "nested capturing",
+			input: `
+			func adder(x) {
+				return { a -> { b -> a + b + x } }
+			}
+			adder(1)(2)(3)
+			`,
+			expected: 6,
+		},
+		{
+			label: "within for expression",
+			input: `
+			let fs = (for x <- [1, 2] { { -> x * 10 } })
+			fs[1]()
+			`,
+			expected: 20,
+		},
+		{
+			label: "annotation argument",
+			input: `
+			@Iterable({ p, yield ->
+				yield(p.a)
+				yield(p.b)
+			})
+			data Pair {
+				a
+				b
+			}
+			(for x <- Pair(1, 2) { x * 2 })
+			`,
+			expected: []any{2, 4},
+		},
+		{
+			label: "wrong arity",
+			input: "{ a -> a }()",
+			err:   "wrong number of arguments: want=1, got=0",
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestExternFunctions(t *testing.T) {
+	plugins := []runtime.ExternPlugin{testPlugin{}}
+	tests := []vmTestCase{
+		{
+			label: "call extern",
+			input: `
+			extern func double(value)
+			double(21)
+			`,
+			expected: 42,
+			plugins:  plugins,
+		},
+		{
+			label: "extern as value",
+			input: `
+			extern func double(value)
+			func apply(f, value) {
+				return f(value)
+			}
+			apply(double, 2)
+			`,
+			expected: 4,
+			plugins:  plugins,
+		},
+		{
+			label: "wrong arity",
+			input: `
+			extern func double(value)
+			double(1, 2)
+			`,
+			err:     "wrong number of arguments: want=1, got=2",
+			plugins: plugins,
+		},
+		{
+			label: "go errors become runtime errors",
+			input: `
+			extern func fail(message)
+			fail("oops")
+			`,
+			err:     "extern fail(#1): oops",
+			plugins: plugins,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+type testPlugin struct{}
+
+func (testPlugin) Bind(module *ast.SymbolTable, decl *ast.Symbol) runtime.RuntimeValue {
+	var impl runtime.ExternFuncImpl
+	switch decl.Name {
+	case "double":
+		impl = func(args []runtime.RuntimeValue) (runtime.RuntimeValue, error) {
+			return args[0].(runtime.Int) * 2, nil
+		}
+	case "fail":
+		impl = func(args []runtime.RuntimeValue) (runtime.RuntimeValue, error) {
+			return nil, fmt.Errorf("%s", args[0].(runtime.String))
+		}
+	default:
+		return nil
+	}
+	fn, err := runtime.MakeExternFunc(decl, impl)
+	if err != nil {
+		return nil
+	}
+	return fn
+}
+
 func TestData(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -115,6 +473,17 @@ func TestData(t *testing.T) {
 			`,
 			expected: "Max",
 		},
+		{
+			label: "data with keyword fields",
+			input: `
+			data Import {
+				module
+				type
+			}
+			Import("strings", "package").type
+			`,
+			expected: "package",
+		},
 		{
 			label: "data with values and member access",
 			input: `
@@ -190,6 +559,849 @@ func BenchmarkFib32(t *testing.B) {
 func TestBasicVariables(t *testing.T) {
 	tests := []vmTestCase{
 		{input: "let a = 42\na", expected: 42},
+		{input: "let a = 42\nlet b = a\nb", expected: 42},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			label: "infinite loop",
+			input: `
+			func answer() {
+				for {
+					return 42
+				}
+			}
+			answer()
+			`,
+			expected: 42,
+		},
+		{
+			label: "consecutive loops with the same element",
+			input: `
+			func sum() {
+				let total = 0
+				for x <- [1, 2] {
+					let next = total + x
+					total = next
+				}
+				for x <- [3, 4] {
+					let next = total + x
+					total = next
+				}
+				return total
+			}
+			sum()
+			`,
+			expected: 10,
+		},
+		{
+			label: "conditional loop",
+			input: `
+			func answer() {
+				for true {
+					return 42
+				}
+				return 0
+			}
+			answer()
+			`,
+			expected: 42,
+		},
+		{
+			label: "conditional loop never entered",
+			input: `
+			func answer() {
+				for false {
+					return 42
+				}
+				return 0
+			}
+			answer()
+			`,
+			expected: 0,
+		},
+		{
+			label: "array loop",
+			input: `
+			func find(xs) {
+				for x <- xs {
+					if x > 2 {
+						return x
+					}
+				}
+				return 0
+			}
+			find([1, 2, 3, 4])
+			`,
+			expected: 3,
+		},
+		{
+			label: "string loop",
+			input: `
+			func first(s) {
+				for c <- s {
+					return c
+				}
+				return ' '
+			}
+			first("abc")
+			`,
+			expected: 'a',
+		},
+		{
+			label: "top level loop",
+			input: `
+			let xs = [1, 2, 3]
+			for x <- xs {
+				x
+			}
+			"done"
+			`,
+			expected: "done",
+		},
+		{
+			label: "iterable data",
+			input: `
+			@Iterable(iter)
+			data Pair {
+				a
+				b
+			}
+			func iter(p, yield) {
+				yield(p.a)
+				yield(p.b)
+			}
+			func last(pair) {
+				for x <- pair {
+					if x > 1 {
+						return x
+					}
+				}
+				return 0
+			}
+			last(Pair(1, 2))
+			`,
+			expected: 2,
+		},
+		{
+			label: "infinite iterable",
+			input: `
+			@Iterable(iter)
+			data Naturals
+			func iter(n, yield) {
+				let i = 0
+				for yield(i) {
+					i = i + 1
+				}
+			}
+			(for x <- Naturals() {
+				if x == 3 {
+					break
+				}
+				x
+			})
+			`,
+			expected: []any{0, 1, 2},
+		},
+		{
+			label: "yield after break",
+			input: `
+			@Iterable(iter)
+			data Counter {
+				log
+			}
+			func iter(counter, yield) {
+				let i = 0
+				for yield(i) {
+					i = i + 1
+				}
+				counter.log["stopped"] = i
+			}
+			func count() {
+				let log = ["stopped": -1]
+				for x <- Counter(log) {
+					if x == 2 {
+						break
+					}
+				}
+				return log["stopped"]
+			}
+			count()
+			`,
+			expected: 2,
+		},
+		{
+			label: "yield after return",
+			input: `
+			@Iterable(iter)
+			data Counter {
+				log
+			}
+			func iter(counter, yield) {
+				let i = 0
+				for yield(i) {
+					i = i + 1
+				}
+				counter.log["stopped"] = i
+			}
+			func find(log) {
+				for x <- Counter(log) {
+					if x == 2 {
+						return x
+					}
+				}
+				return -1
+			}
+			let log = ["stopped": -1]
+			find(log)
+			log["stopped"]
+			`,
+			expected: 2,
+		},
+		{
+			label: "return from nested loops",
+			input: `
+			@Iterable(iter)
+			data Counter {
+				name
+				log
+			}
+			func iter(counter, yield) {
+				let i = 0
+				for yield(i) {
+					i = i + 1
+				}
+				counter.log[counter.name] = i
+			}
+			func find(log) {
+				for x <- Counter("outer", log) {
+					for y <- Counter("inner", log) {
+						if x + y == 3 {
+							return [x, y]
+						}
+					}
+				}
+				return []
+			}
+			let log = ["outer": -1, "inner": -1]
+			let found = find(log)
+			let results = [found, log["outer"], log["inner"]]
+			results
+			`,
+			expected: []any{[]any{0, 3}, 0, 3},
+		},
+		{
+			label: "error within loop",
+			input: `
+			@Iterable(iter)
+			data Naturals
+			func iter(n, yield) {
+				let i = 0
+				for yield(i) {
+					i = i + 1
+				}
+			}
+			for x <- Naturals() {
+				x()
+			}
+			`,
+			err: "value is not callable (runtime.Int \"0\")",
+		},
+		{
+			label: "dict in key order",
+			input: `
+			(for v <- ["b": 2, "c": 3, "a": 1] { v })
+			`,
+			expected: []any{1, 2, 3},
+		},
+		{
+			label: "break infinite loop",
+			input: `
+			func answer() {
+				for {
+					break
+				}
+				return 42
+			}
+			answer()
+			`,
+			expected: 42,
+		},
+		{
+			label: "continue skips remaining block",
+			input: `
+			func find(xs) {
+				for x <- xs {
+					if x < 3 {
+						continue
+					}
+					return x
+				}
+				return 0
+			}
+			find([1, 2, 3, 4])
+			`,
+			expected: 3,
+		},
+		{
+			label: "break collection loop",
+			input: `
+			func find(xs) {
+				for x <- xs {
+					if x == 2 {
+						break
+					}
+					if x == 3 {
+						return x
+					}
+				}
+				return 0
+			}
+			find([1, 2, 3])
+			`,
+			expected: 0,
+		},
+		{
+			label: "break inner loop only",
+			input: `
+			func find(xss) {
+				for xs <- xss {
+					for x <- xs {
+						if x == 0 {
+							break
+						}
+						if x > 2 {
+							continue
+						}
+						return x
+					}
+				}
+				return 0
+			}
+			find([[0, 1], [3, 0], [4, 2]])
+			`,
+			expected: 2,
+		},
+		{
+			label: "not iterable",
+			input: `
+			for x <- 42 {
+				x
+			}
+			`,
+			err: `value is not iterable (runtime.Int "42")`,
+		},
+		{
+			label: "function with multiple parameters",
+			input: `
+			func sub(a, b) {
+				return a - b
+			}
+			sub(5, 3)
+			`,
+			expected: 2,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestForExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			label:    "collect doubled",
+			input:    "(for item <- [1, 2, 3] { item * 2 })",
+			expected: []any{2, 4, 6},
+		},
+		{
+			label:    "empty collection",
+			input:    "(for item <- [] { item })",
+			expected: []any{},
+		},
+		{
+			label: "global",
+			input: `
+			let items = [1, 2, 3]
+			let result = for item <- items {
+				item * 2
+			}
+			result
+			`,
+			expected: []any{2, 4, 6},
+		},
+		{
+			label: "within function",
+			input: `
+			func double(items) {
+				return for item <- items {
+					let doubled = item * 2
+					doubled
+				}
+			}
+			double([1, 2])
+			`,
+			expected: []any{2, 4},
+		},
+		{
+			label: "filtering and breaking",
+			input: `
+			(for num <- [2, 3, 5, 6, 13, 4] {
+				if num % 13 == 0 {
+					break
+				} else if num % 2 == 0 && num % 3 == 0 {
+					"fizzbuzz"
+				} else if num % 2 == 0 {
+					"fizz"
+				} else if num % 3 == 0 {
+					"buzz"
+				} else {
+					continue
+				}
+			})
+			`,
+			expected: []any{"fizz", "buzz", "fizzbuzz"},
+		},
+		{
+			label: "infinite loop with break",
+			input: `
+			(for {
+				break
+			})
+			`,
+			expected: []any{},
+		},
+		{
+			label: "nested",
+			input: `
+			(for xs <- [[1, 2], [3]] {
+				(for x <- xs { x + 1 })
+			})
+			`,
+			expected: []any{[]any{2, 3}, []any{4}},
+		},
+		{
+			label: "nested statement",
+			input: `
+			(for xs <- [[1, 2], [3]] {
+				for x <- xs { x + 1 }
+			})
+			`,
+			expected: []any{2, 3, 4},
+		},
+		{
+			label: "nested statement with break and filter",
+			input: `
+			(for xs <- [[1, 2, 3], [4, 0, 5], [6]] {
+				let offset = 10
+				for x <- xs {
+					if x == 0 {
+						break
+					} else if x % 2 == 0 {
+						x + offset
+					}
+				}
+			})
+			`,
+			expected: []any{12, 14, 16},
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSwitch(t *testing.T) {
+	types := `
+	extern type Int {}
+	extern type String {}
+	annotation Countable {}
+	@Countable
+	data Bag {
+		items
+	}
+	data Point {
+		x
+		y
+	}
+	`
+	tests := []vmTestCase{
+		{
+			label: "value cases",
+			input: `
+			func name(n) {
+				switch n {
+				case 1:
+					return "one"
+				case 1 + 1:
+					return "two"
+				case _:
+					return "many"
+				}
+			}
+			[name(1), name(2), name(3)]
+			`,
+			expected: []any{"one", "two", "many"},
+		},
+		{
+			label: "no matching statement case",
+			input: `
+			let result = 0
+			switch 3 {
+			case 1:
+				result
+			}
+			result
+			`,
+			expected: 0,
+		},
+		{
+			label: "subject evaluated once",
+			input: `
+			func next(counter) { return counter + 1 }
+			let calls = for i <- [1] {
+				switch next(i) {
+				case 1: "one"
+				case 2: "two"
+				case _: "other"
+				}
+			}
+			calls
+			`,
+			expected: []any{"two"},
+		},
+		{
+			label: "type and annotation cases",
+			input: types + `
+			func kind(v) {
+				return switch v {
+				case @String: "string"
+				case @Type(Int): "int"
+				case @Has(Countable): "countable"
+				case @Point: "point"
+				case _: "unknown"
+				}
+			}
+			[kind("a"), kind(1), kind(Bag([])), kind(Point(1, 2)), kind(true)]
+			`,
+			expected: []any{"string", "int", "countable", "point", "unknown"},
+		},
+		{
+			label: "expression with locals",
+			input: `
+			let n = 2
+			let doubled = switch n {
+			case 1:
+				0
+			case _:
+				let twice = n * 2
+				twice
+			}
+			doubled
+			`,
+			expected: 4,
+		},
+		{
+			label:    "nested expression",
+			input:    `1 + switch "b" { case "a": 1 case _: switch 2 { case 2: 20 case _: 0 } }`,
+			expected: 21,
+		},
+		{
+			label: "returning case",
+			input: `
+			func check(v) {
+				let result = switch v {
+				case 0: return "zero"
+				case _: v
+				}
+				return result * 10
+			}
+			[check(0), check(2)]
+			`,
+			expected: []any{"zero", 20},
+		},
+		{
+			label: "break and continue within loop",
+			input: `
+			(for n <- [1, 2, 3, 4] {
+				switch n {
+				case 2: continue
+				case 4: break
+				case _: n
+				}
+			})
+			`,
+			expected: []any{1, 3},
+		},
+		{
+			label: "structural value cases",
+			input: types + `
+			func kind(v) {
+				return switch v {
+				case [1, 2]: "array"
+				case ["a": [1]]: "dict"
+				case Point(1, [2]): "point"
+				case _: "other"
+				}
+			}
+			[kind([1, 2]), kind([1, 3]), kind(["a": [1]]), kind(["a": [2]]), kind(Point(1, [2])), kind(Point(1, 2))]
+			`,
+			expected: []any{"array", "other", "dict", "other", "point", "other"},
+		},
+		{
+			label: "comparing functions",
+			input: `
+			func f() {}
+			switch f { case f: 1 case _: 2 }
+			`,
+			err: "values of type *runtime.CompiledFunction cannot be compared",
+		},
+		{
+			label: "comparing functions with null",
+			input: `
+			func f() {}
+			switch f { case null: 1 case _: 2 }
+			`,
+			expected: 2,
+		},
+		{
+			label: "matching against a non-type",
+			input: `switch 1 { case @Type(2): 1 }`,
+			err:   `values can only be matched against types (runtime.Int "2")`,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			label:    "global",
+			input:    "let x = 42\nx = 2\nx",
+			expected: 2,
+		},
+		{
+			label: "global within function",
+			input: `
+			let count = 0
+			func increment() {
+				count = count + 1
+			}
+			increment()
+			increment()
+			count
+			`,
+			expected: 2,
+		},
+		{
+			label: "locals and parameters",
+			input: `
+			func sum(n) {
+				let total = 0
+				for n > 0 {
+					total = total + n
+					n = n - 1
+				}
+				return total
+			}
+			sum(4)
+			`,
+			expected: 10,
+		},
+		{
+			label: "captured variable",
+			input: `
+			func counter() {
+				let n = 0
+				return { ->
+					n = n + 1
+					n
+				}
+			}
+			let next = counter()
+			let first = next()
+			let values = [first, next(), counter()()]
+			values
+			`,
+			expected: []any{1, 2, 1},
+		},
+		{
+			label: "captured variable within declaring function",
+			input: `
+			func count() {
+				let n = 0
+				func increment() {
+					n = n + 1
+				}
+				increment()
+				increment()
+				return n
+			}
+			count()
+			`,
+			expected: 2,
+		},
+		{
+			label: "captured variable shared by closures",
+			input: `
+			func counter() {
+				let n = 0
+				return [{ -> n = n + 1 }, { -> n }]
+			}
+			let fns = counter()
+			fns[0]()
+			fns[0]()
+			fns[1]()
+			`,
+			expected: 2,
+		},
+		{
+			label:    "discard",
+			input:    "let x = 1\n_ = x + 1\nx",
+			expected: 1,
+		},
+		{
+			label: "data field",
+			input: `
+			data Person {
+				name
+			}
+			let person = Person("John")
+			func rename(p) {
+				p.name = "Jane"
+			}
+			rename(person)
+			person.name
+			`,
+			expected: "Jane",
+		},
+		{
+			label: "array element",
+			input: `
+			let items = [1, 2, 3]
+			let alias = items
+			items[1] = items[0] + 10
+			alias
+			`,
+			expected: []any{1, 11, 3},
+		},
+		{
+			label: "dict entry",
+			input: `
+			let dict = ["a": 1]
+			dict["a"] = 2
+			dict["b"] = 3
+			dict
+			`,
+			expected: map[any]any{"a": 2, "b": 3},
+		},
+		{
+			label: "array index out of bounds",
+			input: "let items = [1]\nitems[1] = 2",
+			err:   "array index 1 out of bounds",
+		},
+		{
+			label: "unknown field",
+			input: "data Person { name }\nlet person = Person(\"John\")\nperson.age = 42",
+			err:   `field "age" not found in *runtime.DataValue "data #0 { map[name:0] }"`,
+		},
+		{
+			label: "field of non-data value",
+			input: "let s = \"text\"\ns.length = 1",
+			err:   `field assignment not supported on runtime.String "\"text\""`,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTypeExpressions(t *testing.T) {
+	types := `
+	extern type Int {}
+	extern type String {}
+	enum Result {
+		data Ok {
+			value
+		}
+		data Err {
+			message
+		}
+	}
+	enum Failure {
+		Err
+		enum Panic {
+			data Crash {}
+			String
+		}
+	}
+	`
+	tests := []vmTestCase{
+		{
+			label: "enum cases",
+			input: types + `
+			func unwrap(result) {
+				return type result = Result {
+					Ok: { ok -> ok.value },
+					Err: { err -> err.message },
+				}
+			}
+			[unwrap(Ok(42)), unwrap(Err("failed"))]
+			`,
+			expected: []any{42, "failed"},
+		},
+		{
+			label: "nested enum and extern cases",
+			input: types + `
+			func describe(failure) {
+				return type failure = Failure {
+					Err: { err -> "error" },
+					Panic: { panic -> type panic = Panic {
+						Crash: { crash -> "crash" },
+						String: { str -> str },
+					} },
+				}
+			}
+			[describe(Err("failed")), describe(Crash()), describe("panic")]
+			`,
+			expected: []any{"error", "crash", "panic"},
+		},
+		{
+			label: "any case",
+			input: types + `
+			func isOk(result) {
+				return type result = Result {
+					Ok: { ok -> true },
+					Any: { other -> false },
+				}
+			}
+			[isOk(Ok(1)), isOk(Err("failed")), isOk(1)]
+			`,
+			expected: []any{true, false, false},
+		},
+		{
+			label: "invocation as subject",
+			input: types + `
+			func next(n) { return Ok(n + 1) }
+			type next(1) = Result {
+				Ok: { ok -> ok.value },
+				Err: { err -> 0 },
+			}
+			`,
+			expected: 2,
+		},
+		{
+			label: "no matching case",
+			input: types + `
+			type 1 = Result {
+				Ok: { ok -> ok.value },
+				Err: { err -> err.message },
+			}
+			`,
+			err: `no case of enum Result matches (runtime.Int "1")`,
+		},
 	}
 
 	runVmTests(t, tests)
@@ -202,7 +1414,7 @@ func runVmTests(t *testing.T, tests []vmTestCase) {
 		t.Run(fmt.Sprintf("%d. %s", i, tt.label), func(t *testing.T) {
 			program := prepareSourceFileParsing(t, tt.input)
 
-			comp := compiler.New()
+			comp := compiler.New(tt.plugins...)
 			err := comp.Compile(program)
 			if err != nil {
 				t.Fatalf("compiler error: %s", err)
@@ -220,7 +1432,7 @@ func runVmTests(t *testing.T, tests []vmTestCase) {
 				}
 			}
 			if tt.expected != nil {
-				stackElem := vm.LastPoppedStackElem()
+				stackElem := vm.Result()
 
 				testExpectedValue(t, tt.expected, stackElem)
 			}
@@ -1,6 +1,7 @@
 package world
 
 import (
+	"io"
 	"os"
 )
 
@@ -14,3 +15,18 @@ func LiveOS() OS {
 func (unfilteredOS) Exit(code int) {
 	os.Exit(code)
 }
+
+// Stdout implements OS.
+func (unfilteredOS) Stdout() io.Writer {
+	return os.Stdout
+}
+
+// Stderr implements OS.
+func (unfilteredOS) Stderr() io.Writer {
+	return os.Stderr
+}
+
+// Getenv implements OS.
+func (unfilteredOS) Getenv(key string) string {
+	return os.Getenv(key)
+}
@@ -1,5 +1,11 @@
 package world
 
+import "io"
+
 type OS interface {
 	Exit(code int)
+	Stdout() io.Writer
+	Stderr() io.Writer
+	// Getenv returns the value of the environment variable or an empty string.
+	Getenv(key string) string
 }